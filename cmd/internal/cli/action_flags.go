@@ -26,12 +26,22 @@ var (
 	Hostname        string
 	Network         string
 	NetworkArgs     []string
+	PublishPorts    []string
 	DNS             string
 	Security        []string
 	CgroupsPath     string
 	VMRAM           string
 	VMCPU           string
 	ContainLibsPath []string
+	PEMPath         string
+
+	MemoryLimit     string
+	MemorySwapLimit string
+	CPUs            string
+	CPUShares       uint64
+	CpusetCpus      string
+	PidsLimit       int64
+	BlkioWeight     uint16
 
 	IsBoot          bool
 	IsFakeroot      bool
@@ -47,6 +57,8 @@ var (
 	VM              bool
 	VMErr           bool
 	IsSyOS          bool
+	IsPassphrase    bool
+	IsLazy          bool
 
 	NetNamespace  bool
 	UtsNamespace  bool
@@ -78,6 +90,7 @@ func init() {
 	initBoolVars()
 	initNamespaceVars()
 	initPrivilegeVars()
+	initResourceVars()
 	initPlatformDefaults()
 }
 
@@ -137,6 +150,11 @@ func initPathVars() {
 	actionFlags.SetAnnotation("network-args", "argtag", []string{"<name>"})
 	actionFlags.SetAnnotation("network-args", "envkey", []string{"NETWORK_ARGS"})
 
+	// --publish
+	actionFlags.StringSliceVar(&PublishPorts, "publish", []string{}, "publish container port on host for each CNI network, requires --net (eg: 8080:80/tcp)")
+	actionFlags.SetAnnotation("publish", "argtag", []string{"<hostPort:containerPort[/proto]>"})
+	actionFlags.SetAnnotation("publish", "envkey", []string{"PUBLISH"})
+
 	// --dns
 	actionFlags.StringVar(&DNS, "dns", "", "list of DNS server separated by commas to add in resolv.conf")
 	actionFlags.SetAnnotation("dns", "envkey", []string{"DNS"})
@@ -161,6 +179,11 @@ func initPathVars() {
 	actionFlags.SetAnnotation("vm-cpu", "argtag", []string{"<CPU #>"})
 	actionFlags.SetAnnotation("vm-cpu", "envkey", []string{"VM_CPU"})
 
+	// --pem-path
+	actionFlags.StringVar(&PEMPath, "pem-path", "", "path to a PEM formatted RSA key for an encrypted container")
+	actionFlags.SetAnnotation("pem-path", "argtag", []string{"<path>"})
+	actionFlags.SetAnnotation("pem-path", "envkey", []string{"ENCRYPTION_PEM_PATH"})
+
 	// hidden flag to handle SINGULARITY_CONTAINLIBS environment variable
 	actionFlags.StringSliceVar(&ContainLibsPath, "containlibs", []string{}, "")
 	actionFlags.Lookup("containlibs").Hidden = true
@@ -208,6 +231,13 @@ func initBoolVars() {
 	actionFlags.BoolVar(&Nvidia, "nv", false, "enable experimental Nvidia support")
 	actionFlags.SetAnnotation("nv", "envkey", []string{"NV"})
 
+	// --passphrase
+	actionFlags.BoolVar(&IsPassphrase, "passphrase", false, "prompt for an encryption passphrase")
+
+	// --lazy
+	actionFlags.BoolVar(&IsLazy, "lazy", false, "run an http(s) SIF image by fetching only the blocks read by the container (root user only, not available to unprivileged users)")
+	actionFlags.SetAnnotation("lazy", "envkey", []string{"LAZY"})
+
 	// -w|--writable
 	actionFlags.BoolVarP(&IsWritable, "writable", "w", false, "by default all Singularity containers are available as read only. This option makes the file system accessible as read/write.")
 	actionFlags.SetAnnotation("writable", "envkey", []string{"WRITABLE"})
@@ -298,3 +328,41 @@ func initPrivilegeVars() {
 	actionFlags.BoolVar(&AllowSUID, "allow-setuid", false, "allow setuid binaries in container (root only)")
 	actionFlags.SetAnnotation("allow-setuid", "envkey", []string{"ALLOW_SETUID"})
 }
+
+// initResourceVars initializes flags that set cgroups resource limits
+func initResourceVars() {
+	// --memory
+	actionFlags.StringVar(&MemoryLimit, "memory", "", "memory limit in bytes, accepts suffixes (b, k, m, g)")
+	actionFlags.SetAnnotation("memory", "argtag", []string{"<size>"})
+	actionFlags.SetAnnotation("memory", "envkey", []string{"MEMORY"})
+
+	// --memory-swap
+	actionFlags.StringVar(&MemorySwapLimit, "memory-swap", "", "total memory limit (memory + swap) in bytes, accepts suffixes (b, k, m, g), -1 for unlimited swap")
+	actionFlags.SetAnnotation("memory-swap", "argtag", []string{"<size>"})
+	actionFlags.SetAnnotation("memory-swap", "envkey", []string{"MEMORY_SWAP"})
+
+	// --cpus
+	actionFlags.StringVar(&CPUs, "cpus", "", "number of CPUs available to container, may be a fractional number")
+	actionFlags.SetAnnotation("cpus", "argtag", []string{"<number>"})
+	actionFlags.SetAnnotation("cpus", "envkey", []string{"CPUS"})
+
+	// --cpu-shares
+	actionFlags.Uint64Var(&CPUShares, "cpu-shares", 0, "CPU shares (relative weight) for container")
+	actionFlags.SetAnnotation("cpu-shares", "argtag", []string{"<shares>"})
+	actionFlags.SetAnnotation("cpu-shares", "envkey", []string{"CPU_SHARES"})
+
+	// --cpuset-cpus
+	actionFlags.StringVar(&CpusetCpus, "cpuset-cpus", "", "list of CPUs in which execution is allowed (eg: 0-3,5)")
+	actionFlags.SetAnnotation("cpuset-cpus", "argtag", []string{"<list>"})
+	actionFlags.SetAnnotation("cpuset-cpus", "envkey", []string{"CPUSET_CPUS"})
+
+	// --pids-limit
+	actionFlags.Int64Var(&PidsLimit, "pids-limit", 0, "maximum number of processes in container, -1 for unlimited")
+	actionFlags.SetAnnotation("pids-limit", "argtag", []string{"<number>"})
+	actionFlags.SetAnnotation("pids-limit", "envkey", []string{"PIDS_LIMIT"})
+
+	// --blkio-weight
+	actionFlags.Uint16Var(&BlkioWeight, "blkio-weight", 0, "block IO relative weight between 10 and 1000")
+	actionFlags.SetAnnotation("blkio-weight", "argtag", []string{"<weight>"})
+	actionFlags.SetAnnotation("blkio-weight", "envkey", []string{"BLKIO_WEIGHT"})
+}
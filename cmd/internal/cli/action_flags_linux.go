@@ -13,10 +13,14 @@ var platformActionFlags = []string{
 	"app",
 	"apply-cgroups",
 	"bind",
+	"blkio-weight",
 	"cleanenv",
 	"contain",
 	"containall",
 	"containlibs",
+	"cpu-shares",
+	"cpus",
+	"cpuset-cpus",
 	"dns",
 	"docker-login",
 	"docker-password",
@@ -27,6 +31,9 @@ var platformActionFlags = []string{
 	"hostname",
 	"ipc",
 	"keep-privs",
+	"lazy",
+	"memory",
+	"memory-swap",
 	"net",
 	"network",
 	"network-args",
@@ -37,7 +44,11 @@ var platformActionFlags = []string{
 	"no-privs",
 	"nv",
 	"overlay",
+	"passphrase",
+	"pem-path",
 	"pid",
+	"pids-limit",
+	"publish",
 	"pwd",
 	"scratch",
 	"security",
@@ -6,7 +6,10 @@
 package cli
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	ocitypes "github.com/containers/image/types"
@@ -21,6 +24,15 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/pkg/build/types"
 	library "github.com/sylabs/singularity/pkg/client/library"
+	net "github.com/sylabs/singularity/pkg/client/net"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+// docker or OCI source and manifest digest of the image replacing the URI
+// of an action command, checked against the execution control list
+var (
+	imageSource string
+	imageDigest string
 )
 
 func init() {
@@ -69,10 +81,17 @@ func handleOCI(cmd *cobra.Command, u string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to get SHA of %v: %v", u, err)
 	}
+	imageSource, imageDigest = u, "sha256:"+sum
 
 	name := uri.GetName(u)
 	imgabs := cache.OciTempImage(sum, name)
 
+	fd, err := cache.LockEntry(imgabs)
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %v: %v", imgabs, err)
+	}
+	defer lock.Release(fd)
+
 	if exists, err := cache.OciTempExists(sum, name); err != nil {
 		return "", fmt.Errorf("unable to check if %v exists: %v", imgabs, err)
 	} else if !exists {
@@ -87,6 +106,65 @@ func handleOCI(cmd *cobra.Command, u string) (string, error) {
 		}
 
 		sylog.Infof("Image cached as SIF at %s", imgabs)
+		evictCache()
+	}
+
+	return imgabs, nil
+}
+
+// handleOCISandbox unpacks images from local OCI layouts and Docker archives
+// into a sandbox cached by image digest, a reference is added on the cached
+// sandbox so it's not removed while the container is running
+func handleOCISandbox(u string) (string, error) {
+	sum, err := ociclient.ImageSHA(u, &ocitypes.SystemContext{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get SHA of %v: %v", u, err)
+	}
+	imageSource, imageDigest = u, "sha256:"+sum
+
+	name := strings.TrimSuffix(uri.GetName(u), ".sif")
+	imgabs := cache.OciSandboxImage(sum, name)
+
+	fd, err := cache.LockOciSandbox(sum)
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %v: %v", imgabs, err)
+	}
+	defer lock.Release(fd)
+
+	if exists, err := cache.OciSandboxExists(sum, name); err != nil {
+		return "", fmt.Errorf("unable to check if %v exists: %v", imgabs, err)
+	} else if !exists {
+		sylog.Infof("Unpacking OCI layers to sandbox")
+		// unpack to a temporary location first to not leave an incomplete
+		// sandbox in cache if interrupted
+		tmpabs := filepath.Join(filepath.Dir(imgabs), "."+name+".tmp")
+		if err := os.RemoveAll(tmpabs); err != nil {
+			return "", fmt.Errorf("unable to remove %v: %v", tmpabs, err)
+		}
+
+		b, err := build.NewBuild(u, tmpabs, "sandbox", "", "", types.Options{TmpDir: tmpDir, NoTest: true})
+		if err != nil {
+			return "", fmt.Errorf("unable to create new build: %v", err)
+		}
+
+		if err := b.Full(); err != nil {
+			os.RemoveAll(tmpabs)
+			return "", fmt.Errorf("unable to unpack: %v", err)
+		}
+
+		if err := os.Rename(tmpabs, imgabs); err != nil {
+			os.RemoveAll(tmpabs)
+			return "", fmt.Errorf("unable to move sandbox to %v: %v", imgabs, err)
+		}
+
+		sylog.Infof("Image cached as sandbox at %s", imgabs)
+		evictCache()
+	} else {
+		sylog.Verbosef("Use sandbox from cache")
+	}
+
+	if err := cache.AddReference(sum); err != nil {
+		return "", fmt.Errorf("unable to reference %v: %v", imgabs, err)
 	}
 
 	return imgabs, nil
@@ -101,19 +179,20 @@ func handleLibrary(u string) (string, error) {
 	imageName := uri.GetName(u)
 	imagePath := cache.LibraryImage(libraryImage.Hash, imageName)
 
+	fd, err := cache.LockEntry(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %v: %v", imagePath, err)
+	}
+	defer lock.Release(fd)
+
 	if exists, err := cache.LibraryImageExists(libraryImage.Hash, imageName); err != nil {
 		return "", fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
 	} else if !exists {
 		sylog.Infof("Downloading library image")
-		if err = library.DownloadImage(imagePath, u, "https://library.sylabs.io", true, authToken); err != nil {
+		if err = library.DownloadImageHash(imagePath, u, "https://library.sylabs.io", true, authToken, libraryImage.Hash); err != nil {
 			return "", fmt.Errorf("unable to Download Image: %v", err)
 		}
-
-		if cacheFileHash, err := library.ImageHash(imagePath); err != nil {
-			return "", fmt.Errorf("Error getting ImageHash: %v", err)
-		} else if cacheFileHash != libraryImage.Hash {
-			return "", fmt.Errorf("Cached File Hash(%s) and Expected Hash(%s) does not match", cacheFileHash, libraryImage.Hash)
-		}
+		evictCache()
 	}
 
 	return imagePath, nil
@@ -123,6 +202,12 @@ func handleShub(u string) (string, error) {
 	imageName := uri.GetName(u)
 	imagePath := cache.ShubImage("hash", imageName)
 
+	fd, err := cache.LockEntry(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %v: %v", imagePath, err)
+	}
+	defer lock.Release(fd)
+
 	exists, err := cache.ShubImageExists("hash", imageName)
 	if err != nil {
 		return "", fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
@@ -130,6 +215,7 @@ func handleShub(u string) (string, error) {
 	if !exists {
 		sylog.Infof("Downloading shub image")
 		libexec.PullShubImage(imagePath, u, true, noHTTPS)
+		evictCache()
 	} else {
 		sylog.Verbosef("Use image from cache")
 	}
@@ -140,15 +226,36 @@ func handleShub(u string) (string, error) {
 func handleNet(u string) (string, error) {
 	refParts := strings.Split(u, "/")
 	imageName := refParts[len(refParts)-1]
-	imagePath := cache.NetImage("hash", imageName)
 
-	exists, err := cache.NetImageExists("hash", imageName)
+	// the cache entry is identified by the url and the ETag or Last-Modified
+	// value of the remote file, a changed remote file gets a new entry
+	validator, err := net.Validator(u)
+	if err != nil {
+		return "", fmt.Errorf("unable to check %v: %v", u, err)
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(u+"\n"+validator)))
+	imagePath := cache.NetImage(sum, imageName)
+
+	fd, err := cache.LockEntry(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to lock %v: %v", imagePath, err)
+	}
+	defer lock.Release(fd)
+
+	exists, err := cache.NetImageExists(sum, imageName)
 	if err != nil {
 		return "", fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
 	}
+	if exists && validator == "" {
+		sylog.Verbosef("No ETag or Last-Modified header returned for %s, cached image can't be reused", u)
+		exists = false
+	}
 	if !exists {
 		sylog.Infof("Downloading network image")
-		libexec.PullNetImage(imagePath, u, true)
+		if err := net.DownloadValidatedImage(imagePath, u, validator); err != nil {
+			return "", fmt.Errorf("unable to download %v: %v", u, err)
+		}
+		evictCache()
 	} else {
 		sylog.Infof("Use image from cache")
 	}
@@ -173,12 +280,16 @@ func replaceURIWithImage(cmd *cobra.Command, args []string) {
 		image, err = handleLibrary(args[0])
 	case uri.Shub:
 		image, err = handleShub(args[0])
+	case "oci", "docker-archive":
+		image, err = handleOCISandbox(args[0])
 	case ociclient.IsSupported(t):
 		image, err = handleOCI(cmd, args[0])
-	case uri.HTTP:
-		image, err = handleNet(args[0])
-	case uri.HTTPS:
-		image, err = handleNet(args[0])
+	case uri.HTTP, uri.HTTPS:
+		if IsLazy {
+			image, err = handleLazy(args[0])
+		} else {
+			image, err = handleNet(args[0])
+		}
 	default:
 		sylog.Fatalf("Unsupported transport type: %s", t)
 	}
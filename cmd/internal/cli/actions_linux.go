@@ -21,10 +21,13 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/plugin"
 	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/image/unpacker"
+	"github.com/sylabs/singularity/pkg/network"
+	"github.com/sylabs/singularity/pkg/util/crypt"
 	"github.com/sylabs/singularity/pkg/util/nvidia"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/cgroups"
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
@@ -186,6 +189,22 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			sylog.Fatalf("Failed to determine image absolute path for %s: %s", image, err)
 		}
 		engineConfig.SetImage(abspath)
+		if imageSource != "" {
+			engineConfig.SetImageSource(imageSource)
+			engineConfig.SetImageDigest(imageDigest)
+		}
+
+		keyInfo, err := getEncryptionMaterial(cobraCmd, false)
+		if err != nil {
+			sylog.Fatalf("Unable to get encryption key material: %s", err)
+		}
+		if keyInfo != nil {
+			key, err := crypt.PlaintextKey(*keyInfo, abspath)
+			if err != nil {
+				sylog.Fatalf("Unable to get encryption key of %s: %s", image, err)
+			}
+			engineConfig.SetEncryptionKey(key)
+		}
 	}
 
 	if !NoNvidia && (Nvidia || engineConfig.File.AlwaysUseNv) {
@@ -225,6 +244,7 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	engineConfig.SetNetwork(Network)
 	engineConfig.SetDNS(DNS)
 	engineConfig.SetNetworkArgs(NetworkArgs)
+	engineConfig.SetNetworkPorts(PublishPorts)
 	engineConfig.SetOverlayImage(OverlayPath)
 	engineConfig.SetWritableImage(IsWritable)
 	engineConfig.SetNoHome(NoHome)
@@ -249,9 +269,39 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		generator.AddProcessEnv("SINGULARITY_SHELL", ShellPath)
 	}
 
-	checkPrivileges(CgroupsPath != "", "--apply-cgroups", func() {
+	limits := cgroups.Limits{
+		Memory:      MemoryLimit,
+		MemorySwap:  MemorySwapLimit,
+		CPUs:        CPUs,
+		CPUShares:   CPUShares,
+		CpusetCpus:  CpusetCpus,
+		PidsLimit:   PidsLimit,
+		BlkioWeight: BlkioWeight,
+	}
+
+	if CgroupsPath != "" && limits.Empty() && isPrivileged {
 		engineConfig.SetCgroupsPath(CgroupsPath)
-	})
+	} else if CgroupsPath != "" || !limits.Empty() {
+		// for unprivileged users, --apply-cgroups file is passed as
+		// resource limits checked against the configuration
+		cgroupsConfig := cgroups.Config{}
+		// resource limits from command line override those from --apply-cgroups file
+		if CgroupsPath != "" {
+			c, err := cgroups.LoadConfig(CgroupsPath)
+			if err != nil {
+				sylog.Fatalf("Failed to load cgroups configuration file %s: %s", CgroupsPath, err)
+			}
+			cgroupsConfig = c
+		}
+		if err := limits.Apply(&cgroupsConfig); err != nil {
+			sylog.Fatalf("Invalid resource limits: %s", err)
+		}
+		resources, err := cgroupsConfig.Spec()
+		if err != nil {
+			sylog.Fatalf("Failed to convert resource limits: %s", err)
+		}
+		engineConfig.SetResources(&resources)
+	}
 
 	if IsWritable && IsWritableTmpfs {
 		sylog.Warningf("Disabling --writable-tmpfs flag, mutually exclusive with --writable")
@@ -352,6 +402,17 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		procname = "Singularity runtime parent"
 	}
 
+	if len(PublishPorts) > 0 {
+		if !NetNamespace || Network == "none" {
+			sylog.Fatalf("--publish requires a network namespace with --net and a CNI network")
+		}
+		for _, p := range PublishPorts {
+			if _, err := network.ParsePortMap(p); err != nil {
+				sylog.Fatalf("Invalid --publish argument: %s", err)
+			}
+		}
+	}
+
 	if NetNamespace {
 		generator.AddOrReplaceLinuxNamespace("network", "")
 	}
@@ -415,6 +476,9 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	// convert image file to sandbox if image contains
 	// a squashfs filesystem
 	if UserNamespace && fs.IsFile(image) {
+		if len(engineConfig.GetEncryptionKey()) > 0 {
+			sylog.Fatalf("Encrypted container requires setuid mode or root privileges")
+		}
 		unsquashfsPath := ""
 		if engineConfig.File.MksquashfsPath != "" {
 			d := filepath.Dir(engineConfig.File.MksquashfsPath)
@@ -443,6 +507,11 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		sylog.Fatalf("CLI Failed to marshal CommonEngineConfig: %s\n", err)
 	}
 
+	if lazyDevice != nil && engineConfig.GetInstance() {
+		lazyDevice.Detach()
+		sylog.Fatalf("--lazy is not supported with instances")
+	}
+
 	if engineConfig.GetInstance() {
 		stdout, stderr, err := instance.SetLogFile(name, int(uid))
 		if err != nil {
@@ -487,6 +556,8 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			sylog.Verbosef("you will find instance error here: %s", stderr.Name())
 			sylog.Infof("instance started successfully")
 		}
+	} else if lazyDevice != nil {
+		runLazy(starter, procname, Env, configData)
 	} else {
 		if err := exec.Pipe(starter, []string{procname}, Env, configData); err != nil {
 			sylog.Fatalf("%s", err)
@@ -8,9 +8,15 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 )
 
+func handleLazy(u string) (string, error) {
+	return "", fmt.Errorf("--lazy is unsupported on this platform")
+}
+
 // TODO: Let's stick this in another file so that that CLI is just CLI
 func execStarter(cobraCmd *cobra.Command, image string, args []string, name string) {
 	panic("starter is unsupported on this platform")
@@ -38,6 +38,7 @@ var (
 	dockerPassword string
 	dockerLogin    bool
 	noCleanUp      bool
+	encrypt        bool
 )
 
 func init() {
@@ -82,6 +83,12 @@ func init() {
 	BuildCmd.Flags().BoolVar(&noCleanUp, "no-cleanup", false, "do NOT clean up bundle after failed build, can be helpul for debugging")
 	BuildCmd.Flags().SetAnnotation("no-cleanup", "envkey", []string{"NO_CLEANUP"})
 
+	BuildCmd.Flags().BoolVarP(&encrypt, "encrypt", "e", false, "build an image with an encrypted root filesystem, requires --passphrase, --pem-path or SINGULARITY_ENCRYPTION_PASSPHRASE")
+	BuildCmd.Flags().SetAnnotation("encrypt", "envkey", []string{"ENCRYPT"})
+
+	BuildCmd.Flags().AddFlag(actionFlags.Lookup("passphrase"))
+	BuildCmd.Flags().AddFlag(actionFlags.Lookup("pem-path"))
+
 	BuildCmd.Flags().AddFlag(actionFlags.Lookup("docker-username"))
 	BuildCmd.Flags().AddFlag(actionFlags.Lookup("docker-password"))
 	BuildCmd.Flags().AddFlag(actionFlags.Lookup("docker-login"))
@@ -34,6 +34,23 @@ func run(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	keyInfo, err := getEncryptionMaterial(cmd, true)
+	if err != nil {
+		sylog.Fatalf("Unable to get encryption key material: %v", err)
+	}
+	if encrypt || keyInfo != nil {
+		switch {
+		case keyInfo == nil:
+			sylog.Fatalf("--encrypt requires --passphrase, --pem-path or SINGULARITY_ENCRYPTION_PASSPHRASE")
+		case remote:
+			sylog.Fatalf("Encrypted root filesystem is not supported with remote builds")
+		case sandbox:
+			sylog.Fatalf("Encrypted root filesystem requires a SIF image")
+		case os.Getuid() != 0:
+			sylog.Fatalf("Building an encrypted root filesystem requires root privileges")
+		}
+	}
+
 	if remote {
 		handleRemoteBuildFlags(cmd)
 
@@ -84,14 +101,15 @@ func run(cmd *cobra.Command, args []string) {
 			libraryURL,
 			authToken,
 			types.Options{
-				TmpDir:           tmpDir,
-				Update:           update,
-				Force:            force,
-				Sections:         sections,
-				NoTest:           noTest,
-				NoHTTPS:          noHTTPS,
-				NoCleanUp:        noCleanUp,
-				DockerAuthConfig: authConf,
+				TmpDir:            tmpDir,
+				Update:            update,
+				Force:             force,
+				Sections:          sections,
+				NoTest:            noTest,
+				NoHTTPS:           noHTTPS,
+				NoCleanUp:         noCleanUp,
+				DockerAuthConfig:  authConf,
+				EncryptionKeyInfo: keyInfo,
 			})
 		if err != nil {
 			sylog.Fatalf("Unable to create build: %v", err)
@@ -18,6 +18,8 @@ var (
 	cleanAll        bool
 	cacheCleanTypes []string
 	cacheName       string
+	cleanDays       int
+	cleanSize       string
 )
 
 func init() {
@@ -26,11 +28,19 @@ func init() {
 	CacheCleanCmd.Flags().BoolVarP(&cleanAll, "all", "a", false, "clean all cache (will overide all other options)")
 	CacheCleanCmd.Flags().SetAnnotation("all", "envkey", []string{"ALL"})
 
-	CacheCleanCmd.Flags().StringSliceVarP(&cacheCleanTypes, "type", "T", []string{"blob"}, "clean cache type, choose between: library, oci, and blob")
+	CacheCleanCmd.Flags().StringSliceVarP(&cacheCleanTypes, "type", "T", []string{"blob"}, "clean cache type, choose between: library, oci, sandbox, and blob")
 	CacheCleanCmd.Flags().SetAnnotation("type", "envkey", []string{"TYPE"})
 
 	CacheCleanCmd.Flags().StringVarP(&cacheName, "name", "N", "", "specify a container cache to clean (will clear all cache with the same name)")
 	CacheCleanCmd.Flags().SetAnnotation("name", "envkey", []string{"NAME"})
+
+	CacheCleanCmd.Flags().IntVar(&cleanDays, "days", 0, "remove cache entries unused for more than <days> days")
+	CacheCleanCmd.Flags().SetAnnotation("days", "argtag", []string{"<days>"})
+	CacheCleanCmd.Flags().SetAnnotation("days", "envkey", []string{"DAYS"})
+
+	CacheCleanCmd.Flags().StringVar(&cleanSize, "size", "", "remove least recently used cache entries until the cache is not larger than <size> (e.g. 10G)")
+	CacheCleanCmd.Flags().SetAnnotation("size", "argtag", []string{"<size>"})
+	CacheCleanCmd.Flags().SetAnnotation("size", "envkey", []string{"SIZE"})
 }
 
 // CacheCleanCmd : is `singularity cache clean' and will clear your local singularity cache
@@ -50,7 +60,12 @@ var CacheCleanCmd = &cobra.Command{
 }
 
 func cacheCleanCmd() error {
-	err := singularity.CleanSingularityCache(cleanAll, cacheCleanTypes, cacheName)
+	var err error
+	if cleanDays > 0 || cleanSize != "" {
+		err = singularity.EvictSingularityCache(cleanDays, cleanSize)
+	} else {
+		err = singularity.CleanSingularityCache(cleanAll, cacheCleanTypes, cacheName)
+	}
 	if err != nil {
 		sylog.Fatalf("Failed while clean cache: %v", err)
 		os.Exit(255)
@@ -0,0 +1,61 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// cacheLimits returns the maximum size and age of the cache set in
+// singularity.conf, or by the environment
+func cacheLimits() (int64, time.Duration, error) {
+	fileConfig := &singularityConfig.FileConfig{}
+
+	configurationFile := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+	if err := config.Parser(configurationFile, fileConfig); err != nil {
+		return 0, 0, fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+
+	maxSize := fileConfig.CacheMaxSize
+	if s := os.Getenv(cache.MaxSizeEnv); s != "" {
+		maxSize = s
+	}
+	size, err := cache.ParseSize(maxSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad cache max size: %s", err)
+	}
+
+	days := int(fileConfig.CacheMaxAge)
+	if s := os.Getenv(cache.MaxAgeEnv); s != "" {
+		days, err = strconv.Atoi(s)
+		if err != nil || days < 0 {
+			return 0, 0, fmt.Errorf("bad cache max age %q", s)
+		}
+	}
+
+	return size, time.Duration(days) * 24 * time.Hour, nil
+}
+
+// evictCache removes cache entries exceeding the cache limits
+func evictCache() {
+	size, age, err := cacheLimits()
+	if err != nil {
+		sylog.Warningf("Not enforcing cache limits: %s", err)
+		return
+	}
+	if err := cache.Evict(size, age); err != nil {
+		sylog.Warningf("Unable to enforce cache limits: %s", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/syecl"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/openpgp"
+)
+
+func init() {
+	EclCheckCmd.Flags().SetInterspersed(false)
+}
+
+// EclCheckCmd is `singularity ecl check <image>' and explains the ECL
+// decision for an image
+var EclCheckCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		allowed, err := doEclCheckCmd(buildcfg.ECL_FILE, args[0])
+		if err != nil {
+			sylog.Errorf("ecl check command failed: %s", err)
+			os.Exit(2)
+		}
+		if !allowed {
+			os.Exit(1)
+		}
+	},
+
+	Use:     docs.EclCheckUse,
+	Short:   docs.EclCheckShort,
+	Long:    docs.EclCheckLong,
+	Example: docs.EclCheckExample,
+}
+
+func doEclCheckCmd(eclPath, path string) (bool, error) {
+	ecl, err := syecl.LoadConfig(eclPath)
+	if err != nil {
+		return false, fmt.Errorf("could not load %s: %s", eclPath, err)
+	}
+	if err := ecl.ValidateConfig(); err != nil {
+		return false, fmt.Errorf("invalid %s: %s", eclPath, err)
+	}
+
+	img, err := image.Init(path, false)
+	if err != nil {
+		return false, err
+	}
+	defer img.File.Close()
+
+	if !ecl.Activated {
+		fmt.Printf("ECL rules of %s are not activated, they are checked but not enforced\n\n", eclPath)
+	}
+
+	// signatures are only verified with the global keyring
+	var kr openpgp.EntityList
+	if img.Type == image.SIF {
+		kr, err = sypgp.LoadGlobalPubKeyring()
+		if err != nil {
+			return false, fmt.Errorf("could not load global keyring: %s", err)
+		}
+	}
+	d, err := ecl.CheckImage(img, "", "", kr)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Container:  %s\n", d.Path)
+	if d.Mode != "" {
+		fmt.Printf("Execgroup:  %s (%s)\n", d.TagName, d.Mode)
+	}
+	if d.Match != "" {
+		fmt.Printf("Matched:    %s\n", d.Match)
+	}
+	if len(d.Shadowed) > 0 {
+		fmt.Printf("Shadowed:   %s\n", strings.Join(d.Shadowed, ", "))
+	}
+	if !d.Allowed {
+		fmt.Printf("Result:     not allowed to run: %s\n", d.Err)
+		return !ecl.Activated, nil
+	}
+	fmt.Printf("Result:     allowed to run\n")
+	return true, nil
+}
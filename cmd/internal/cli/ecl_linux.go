@@ -0,0 +1,32 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+)
+
+func init() {
+	SingularityCmd.AddCommand(EclCmd)
+	EclCmd.AddCommand(EclCheckCmd)
+}
+
+// EclCmd is the 'ecl' command that inspects the execution control list
+var EclCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("Invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.EclUse,
+	Short:         docs.EclShort,
+	Long:          docs.EclLong,
+	Example:       docs.EclExample,
+	SilenceErrors: true,
+}
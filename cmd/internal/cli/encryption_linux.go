@@ -0,0 +1,64 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"github.com/sylabs/singularity/pkg/util/crypt"
+)
+
+// getEncryptionMaterial returns the encryption key material set with
+// --passphrase, --pem-path or SINGULARITY_ENCRYPTION_PASSPHRASE, or nil
+// if none is set. If confirm is true, the passphrase is asked twice
+func getEncryptionMaterial(cmd *cobra.Command, confirm bool) (*crypt.KeyInfo, error) {
+	pemPathSet := false
+	if f := cmd.Flags().Lookup("pem-path"); f != nil {
+		pemPathSet = f.Changed
+	}
+	passphraseSet := false
+	if f := cmd.Flags().Lookup("passphrase"); f != nil {
+		passphraseSet = IsPassphrase
+	}
+	passphraseEnv, passphraseEnvOK := os.LookupEnv(envPrefix + "ENCRYPTION_PASSPHRASE")
+
+	if passphraseSet && pemPathSet {
+		return nil, fmt.Errorf("only one of --passphrase and --pem-path can be used")
+	}
+
+	switch {
+	case pemPathSet:
+		if _, err := os.Stat(PEMPath); err != nil {
+			return nil, fmt.Errorf("while checking PEM file: %s", err)
+		}
+		return &crypt.KeyInfo{Format: crypt.PEM, Material: PEMPath}, nil
+	case passphraseSet:
+		passphrase, err := sypgp.AskQuestionNoEcho("Enter encryption passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if confirm {
+			again, err := sypgp.AskQuestionNoEcho("Retype encryption passphrase: ")
+			if err != nil {
+				return nil, err
+			}
+			if passphrase != again {
+				return nil, fmt.Errorf("passphrases do not match")
+			}
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("empty encryption passphrase")
+		}
+		return &crypt.KeyInfo{Format: crypt.Passphrase, Material: passphrase}, nil
+	case passphraseEnvOK:
+		return &crypt.KeyInfo{Format: crypt.Passphrase, Material: passphraseEnv}, nil
+	}
+
+	return nil, nil
+}
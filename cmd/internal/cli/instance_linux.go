@@ -16,15 +16,18 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/signal"
+	"github.com/sylabs/singularity/pkg/network"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
 )
 
-// instance list/stop options
+// instance list/stats/stop options
 var username string
 
-// instance list options
+// instance list/stats options
 var jsonFormat bool
 
 // instance stop options
@@ -38,6 +41,7 @@ func init() {
 	InstanceCmd.AddCommand(InstanceStartCmd)
 	InstanceCmd.AddCommand(InstanceStopCmd)
 	InstanceCmd.AddCommand(InstanceListCmd)
+	InstanceCmd.AddCommand(InstanceStatsCmd)
 }
 
 // InstanceCmd singularity instance
@@ -76,6 +80,7 @@ func listInstance() {
 			output["instances"][i].Image = files[i].Image
 			output["instances"][i].Pid = files[i].Pid
 			output["instances"][i].Instance = files[i].Name
+			output["instances"][i].Ports = instancePorts(files[i])
 		}
 
 		c, err := json.MarshalIndent(output, "", "\t")
@@ -86,6 +91,28 @@ func listInstance() {
 	}
 }
 
+// instancePorts returns ports published by an instance
+func instancePorts(file *instance.File) []*network.PortMapEntry {
+	engineConfig := singularityConfig.NewConfig()
+	instanceConfig := &config.Common{
+		EngineConfig: engineConfig,
+	}
+	if err := json.Unmarshal(file.Config, instanceConfig); err != nil {
+		sylog.Debugf("could not read %s instance configuration: %s", file.Name, err)
+		return nil
+	}
+
+	ports := make([]*network.PortMapEntry, 0)
+	for _, p := range engineConfig.GetNetworkPorts() {
+		pm, err := network.ParsePortMap(p)
+		if err != nil {
+			continue
+		}
+		ports = append(ports, pm)
+	}
+	return ports
+}
+
 func killInstance(file *instance.File, sig syscall.Signal, fileChan chan *instance.File) {
 	syscall.Kill(file.Pid, sig)
 
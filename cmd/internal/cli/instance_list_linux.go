@@ -8,12 +8,14 @@ package cli
 import (
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/pkg/network"
 )
 
 type jsonList struct {
-	Instance string `json:"instance"`
-	Pid      int    `json:"pid"`
-	Image    string `json:"img"`
+	Instance string                  `json:"instance"`
+	Pid      int                     `json:"pid"`
+	Image    string                  `json:"img"`
+	Ports    []*network.PortMapEntry `json:"ports,omitempty"`
 }
 
 func init() {
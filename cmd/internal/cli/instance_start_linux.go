@@ -16,11 +16,15 @@ func init() {
 		"allow-setuid",
 		"apply-cgroups",
 		"bind",
+		"blkio-weight",
 		"boot",
 		"contain",
 		"containall",
 		"containlibs",
 		"cleanenv",
+		"cpu-shares",
+		"cpus",
+		"cpuset-cpus",
 		"docker-login",
 		"docker-username",
 		"docker-password",
@@ -30,6 +34,8 @@ func init() {
 		"home",
 		"hostname",
 		"keep-privs",
+		"memory",
+		"memory-swap",
 		"net",
 		"network",
 		"network-args",
@@ -38,6 +44,10 @@ func init() {
 		"no-privs",
 		"nv",
 		"overlay",
+		"passphrase",
+		"pem-path",
+		"pids-limit",
+		"publish",
 		"scratch",
 		"security",
 		"userns",
@@ -0,0 +1,118 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+type jsonStats struct {
+	Instance string `json:"instance"`
+	Pid      int    `json:"pid"`
+	*cgroups.Stats
+}
+
+func init() {
+	InstanceStatsCmd.Flags().SetInterspersed(false)
+
+	// -u|--user
+	InstanceStatsCmd.Flags().StringVarP(&username, "user", "u", "", `If running as root, display stats of instances from "<username>"`)
+	InstanceStatsCmd.Flags().SetAnnotation("user", "argtag", []string{"<username>"})
+	InstanceStatsCmd.Flags().SetAnnotation("user", "envkey", []string{"USER"})
+
+	// -j|--json
+	InstanceStatsCmd.Flags().BoolVarP(&jsonFormat, "json", "j", false, "Print structured json instead of list")
+	InstanceStatsCmd.Flags().SetAnnotation("json", "envkey", []string{"JSON"})
+}
+
+// InstanceStatsCmd singularity instance stats
+var InstanceStatsCmd = &cobra.Command{
+	Args: cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := "*"
+		if len(args) > 0 {
+			name = args[0]
+		}
+		statsInstance(name)
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.InstanceStatsUse,
+	Short:   docs.InstanceStatsShort,
+	Long:    docs.InstanceStatsLong,
+	Example: docs.InstanceStatsExample,
+}
+
+func formatLimit(limit uint64, format func(uint64) string) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return format(limit)
+}
+
+func statsInstance(name string) {
+	if username != "" && os.Getuid() != 0 {
+		sylog.Fatalf("only root user can display stats of user's instances")
+	}
+	files, err := instance.List(username, name)
+	if err != nil {
+		sylog.Fatalf("failed to retrieve instance list: %s", err)
+	}
+	if len(files) == 0 {
+		sylog.Fatalf("no instance found")
+	}
+
+	output := make(map[string][]jsonStats)
+	output["instances"] = make([]jsonStats, 0, len(files))
+
+	for _, file := range files {
+		manager := &cgroups.Manager{Pid: file.Pid}
+		stats, err := manager.GetStats()
+		if err != nil {
+			sylog.Warningf("could not get stats of instance %s: %s", file.Name, err)
+			continue
+		}
+		output["instances"] = append(output["instances"], jsonStats{
+			Instance: file.Name,
+			Pid:      file.Pid,
+			Stats:    stats,
+		})
+	}
+
+	if jsonFormat {
+		c, err := json.MarshalIndent(output, "", "\t")
+		if err != nil {
+			sylog.Fatalf("error while printing structured JSON: %s", err)
+		}
+		fmt.Println(string(c))
+		return
+	}
+
+	bytes := func(v uint64) string { return units.BytesSize(float64(v)) }
+	count := func(v uint64) string { return strconv.FormatUint(v, 10) }
+
+	fmt.Printf("%-16s %-8s %-24s %-11s %s\n", "INSTANCE NAME", "PID", "MEM USAGE / LIMIT", "CPU TIME", "PIDS / LIMIT")
+	for _, s := range output["instances"] {
+		fmt.Printf("%-16s %-8d %-24s %-11s %s\n",
+			s.Instance,
+			s.Pid,
+			bytes(s.MemoryUsage)+" / "+formatLimit(s.MemoryLimit, bytes),
+			(time.Duration(s.CPUUsage) * time.Nanosecond).Round(100*time.Millisecond),
+			count(s.Pids)+" / "+formatLimit(s.PidsLimit, count),
+		)
+	}
+}
@@ -31,6 +31,9 @@ func init() {
 	KeyCmd.AddCommand(KeyPullCmd)
 	KeyCmd.AddCommand(KeyPushCmd)
 	KeyCmd.AddCommand(KeyImportCmd)
+	KeyCmd.AddCommand(KeyRemoveCmd)
+	KeyCmd.AddCommand(KeyExportCmd)
+	KeyCmd.AddCommand(KeyRevokeCmd)
 
 	// keys commands
 	KeysCmd.AddCommand(KeyNewPairCmd)
@@ -39,6 +42,9 @@ func init() {
 	KeysCmd.AddCommand(KeyPullCmd)
 	KeysCmd.AddCommand(KeyPushCmd)
 	KeysCmd.AddCommand(KeyImportCmd)
+	KeysCmd.AddCommand(KeyRemoveCmd)
+	KeysCmd.AddCommand(KeyExportCmd)
+	KeysCmd.AddCommand(KeyRevokeCmd)
 }
 
 // KeysCmd is the 'keys' command that allows management of key stores
@@ -0,0 +1,44 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+)
+
+var keyArmor bool
+
+func init() {
+	KeyExportCmd.Flags().SetInterspersed(false)
+
+	KeyExportCmd.Flags().BoolVarP(&secret, "secret", "s", false, "export a private key instead of a public key")
+	KeyExportCmd.Flags().SetAnnotation("secret", "envkey", []string{"SECRET"})
+	KeyExportCmd.Flags().BoolVarP(&keyArmor, "armor", "a", false, "export the key ASCII armored instead of binary")
+}
+
+// KeyExportCmd is `singularity key export <fingerprint> <file>' and exports a key from the local key store
+var KeyExportCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := sypgp.ExportKey(args[0], args[1], secret, keyArmor); err != nil {
+			sylog.Errorf("key export command failed: %s", err)
+			os.Exit(2)
+		}
+		fmt.Printf("Key with fingerprint %s exported to %s\n", args[0], args[1])
+	},
+
+	Use:     docs.KeyExportUse,
+	Short:   docs.KeyExportShort,
+	Long:    docs.KeyExportLong,
+	Example: docs.KeyExportExample,
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/sypgp"
 )
 
@@ -21,6 +22,8 @@ func init() {
 
 	KeyListCmd.Flags().BoolVarP(&secret, "secret", "s", false, "list private keys instead of the default which displays public ones")
 	KeyListCmd.Flags().SetAnnotation("secret", "envkey", []string{"SECRET"})
+
+	KeyListCmd.Flags().BoolVarP(&keyGlobal, "global", "g", false, "list public keys of the global keyring")
 }
 
 // KeyListCmd is `singularity key list' and lists local store OpenPGP keys
@@ -28,7 +31,8 @@ var KeyListCmd = &cobra.Command{
 	Args:                  cobra.ExactArgs(0),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := doKeyListCmd(secret); err != nil {
+		if err := doKeyListCmd(secret, keyGlobal); err != nil {
+			sylog.Errorf("key list command failed: %s", err)
 			os.Exit(2)
 		}
 	},
@@ -39,7 +43,15 @@ var KeyListCmd = &cobra.Command{
 	Example: docs.KeyListExample,
 }
 
-func doKeyListCmd(secret bool) error {
+func doKeyListCmd(secret, global bool) error {
+	if global {
+		if secret {
+			return fmt.Errorf("global keyring only holds public keys")
+		}
+		fmt.Printf("Global public key listing (%s):\n\n", sypgp.GlobalPublicPath())
+		return sypgp.PrintGlobalPubKeyring()
+	}
+
 	if secret == false {
 		fmt.Printf("Public key listing (%s):\n\n", sypgp.PublicPath())
 		sypgp.PrintPubKeyring()
@@ -6,22 +6,49 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	keyNewPairOpts sypgp.GenKeyPairOptions
+	keyNewPairPush bool
 )
 
 func init() {
 	KeyNewPairCmd.Flags().SetInterspersed(false)
+
+	KeyNewPairCmd.Flags().StringVarP(&keyNewPairOpts.Name, "name", "N", "", "key owner name, the identity is asked interactively if not set")
+	KeyNewPairCmd.Flags().StringVarP(&keyNewPairOpts.Email, "email", "E", "", "key owner email address")
+	KeyNewPairCmd.Flags().StringVarP(&keyNewPairOpts.Comment, "comment", "C", "", "key comment")
+	KeyNewPairCmd.Flags().IntVarP(&keyNewPairOpts.KeyLength, "bit-length", "b", sypgp.DefaultKeyLength, "RSA key length in bits")
+	KeyNewPairCmd.Flags().BoolVarP(&keyNewPairPush, "push", "P", true, "push the public key to the key server")
+	KeyNewPairCmd.Flags().StringVarP(&keyServerURL, "url", "u", defaultKeyServer, "key server URL")
+	KeyNewPairCmd.Flags().SetAnnotation("url", "envkey", []string{"URL"})
+	addPassphraseFlags(KeyNewPairCmd.Flags())
+}
+
+// addPassphraseFlags adds the flags setting the passphrase sources of
+// private keys to flags
+func addPassphraseFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&sypgp.PassphraseFile, "password-file", "", "read the key passphrase from the first line of a file")
+	flags.IntVar(&sypgp.PassphraseFd, "password-fd", -1, "read the key passphrase from a file descriptor")
 }
 
 // KeyNewPairCmd is `singularity key newpair' and generate a new OpenPGP key pair
 var KeyNewPairCmd = &cobra.Command{
 	Args:                  cobra.ExactArgs(0),
 	DisableFlagsInUseLine: true,
+	PreRun:                sylabsToken,
 	Run: func(cmd *cobra.Command, args []string) {
-		if _, err := sypgp.GenKeyPair(); err != nil {
+		if err := doKeyNewPairCmd(cmd.Flags().Changed("push")); err != nil {
 			sylog.Fatalf("creating newpair failed: %v", err)
 		}
 	},
@@ -31,3 +58,29 @@ var KeyNewPairCmd = &cobra.Command{
 	Long:    docs.KeyNewPairLong,
 	Example: docs.KeyNewPairExample,
 }
+
+func doKeyNewPairCmd(pushSet bool) error {
+	entity, err := sypgp.GenKeyPair(keyNewPairOpts)
+	if err != nil {
+		return err
+	}
+
+	push := keyNewPairPush
+	// ask when run interactively without --push
+	if !pushSet && terminal.IsTerminal(int(os.Stdin.Fd())) {
+		resp, err := sypgp.AskQuestion("Upload public key %X to %s? [Y/n] ", entity.PrimaryKey.Fingerprint, keyServerURL)
+		if err != nil {
+			return err
+		}
+		push = resp == "" || resp == "y" || resp == "Y"
+	}
+	if !push {
+		return nil
+	}
+
+	if err := sypgp.PushPubkey(entity, keyServerURL, authToken); err != nil {
+		return fmt.Errorf("key pair stored but failed to push public key: %s", err)
+	}
+	fmt.Printf("Uploaded key successfully!\n")
+	return nil
+}
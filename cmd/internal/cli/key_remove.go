@@ -0,0 +1,68 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+)
+
+func init() {
+	KeyRemoveCmd.Flags().SetInterspersed(false)
+
+	KeyRemoveCmd.Flags().BoolVarP(&keyGlobal, "global", "g", false, "remove a public key from the global keyring (root user only)")
+	KeyRemoveCmd.Flags().BoolVarP(&secret, "secret", "s", false, "remove a private key instead of a public key")
+	KeyRemoveCmd.Flags().SetAnnotation("secret", "envkey", []string{"SECRET"})
+}
+
+// KeyRemoveCmd is `singularity key remove <fingerprint>' and removes a key from a key store
+var KeyRemoveCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := doKeyRemoveCmd(args[0], keyGlobal, secret); err != nil {
+			sylog.Errorf("key remove command failed: %s", err)
+			os.Exit(2)
+		}
+	},
+
+	Use:     docs.KeyRemoveUse,
+	Short:   docs.KeyRemoveShort,
+	Long:    docs.KeyRemoveLong,
+	Example: docs.KeyRemoveExample,
+}
+
+func doKeyRemoveCmd(fingerprint string, global, secret bool) error {
+	if global && secret {
+		return fmt.Errorf("global keyring only holds public keys")
+	}
+	if secret {
+		return sypgp.RemovePrivKey(fingerprint)
+	}
+	if global {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("only root user can remove keys from the global keyring")
+		}
+		return sypgp.RemoveGlobalPubKey(fingerprint)
+	}
+
+	// local keyring fingerprints are compared in upper case
+	fingerprint = strings.ToUpper(fingerprint)
+	found, err := sypgp.CheckLocalPubKey(fingerprint)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no key matching %s in local keyring", fingerprint)
+	}
+	return sypgp.RemovePubKey(fingerprint)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	keyRevokeOutput string
+	keyRevokePush   bool
+)
+
+func init() {
+	KeyRevokeCmd.Flags().SetInterspersed(false)
+
+	KeyRevokeCmd.Flags().StringVarP(&keyRevokeOutput, "output", "o", "", "also write the revocation certificate to a new file")
+	KeyRevokeCmd.Flags().BoolVarP(&keyRevokePush, "push", "P", true, "push the revoked public key to the key server")
+	KeyRevokeCmd.Flags().StringVarP(&keyServerURL, "url", "u", defaultKeyServer, "key server URL")
+	KeyRevokeCmd.Flags().SetAnnotation("url", "envkey", []string{"URL"})
+	addPassphraseFlags(KeyRevokeCmd.Flags())
+}
+
+// KeyRevokeCmd is `singularity key revoke <fingerprint>' and revokes a key of the local key store
+var KeyRevokeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                sylabsToken,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := doKeyRevokeCmd(args[0], cmd.Flags().Changed("push")); err != nil {
+			sylog.Errorf("key revoke command failed: %s", err)
+			os.Exit(2)
+		}
+	},
+
+	Use:     docs.KeyRevokeUse,
+	Short:   docs.KeyRevokeShort,
+	Long:    docs.KeyRevokeLong,
+	Example: docs.KeyRevokeExample,
+}
+
+func doKeyRevokeCmd(fingerprint string, pushSet bool) error {
+	cert, err := sypgp.RevokeKey(fingerprint)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Key with fingerprint %s revoked in the local keystore\n", fingerprint)
+
+	if keyRevokeOutput != "" {
+		f, err := os.OpenFile(keyRevokeOutput, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not create revocation certificate: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(cert); err != nil {
+			return fmt.Errorf("could not write revocation certificate: %s", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Revocation certificate written to %s\n", keyRevokeOutput)
+	}
+
+	push := keyRevokePush
+	// ask when run interactively without --push
+	if !pushSet && terminal.IsTerminal(int(os.Stdin.Fd())) {
+		resp, err := sypgp.AskQuestion("Upload revoked public key %s to %s? [Y/n] ", fingerprint, keyServerURL)
+		if err != nil {
+			return err
+		}
+		push = resp == "" || resp == "y" || resp == "Y"
+	}
+	if !push {
+		return nil
+	}
+
+	if err := sypgp.PushArmoredPubkey(cert, keyServerURL, authToken); err != nil {
+		return fmt.Errorf("key revoked locally but failed to push revoked key: %s", err)
+	}
+	fmt.Printf("Uploaded revoked key successfully!\n")
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/exec"
+	net "github.com/sylabs/singularity/pkg/client/net"
+	"github.com/sylabs/singularity/pkg/util/nbd"
+)
+
+// lazyDevice is the network block device serving the root filesystem
+// of a lazily loaded image
+var lazyDevice *nbd.Device
+
+// handleLazy exposes the squashfs root filesystem of the SIF image at u
+// as a network block device, image blocks are fetched with HTTP range
+// requests when read and cached in the chunk cache. Blocks are served by
+// this process, for unprivileged users they could change after the image
+// checks done by the starter, so the device is never attached in setuid
+// mode and --lazy is restricted to root
+func handleLazy(u string) (string, error) {
+	if os.Getuid() != 0 {
+		return "", fmt.Errorf("--lazy can only be used by root user, pull the image before running it instead")
+	}
+
+	r, err := net.NewRangeReader(u)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := cache.NewChunkCache(u, r.Validator, r.Size, r)
+	if err != nil {
+		return "", err
+	}
+
+	// SIF header and descriptors are stored before data objects
+	b := make([]byte, sif.DataStartOffset)
+	n, err := c.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("while reading SIF header: %s", err)
+	}
+	fimg, err := sif.LoadContainerReader(bytes.NewReader(b[:n]))
+	if err != nil {
+		return "", fmt.Errorf("while loading SIF header: %s", err)
+	}
+	if fimg.DescrArr == nil {
+		return "", fmt.Errorf("no SIF descriptors found in %s", u)
+	}
+
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return "", fmt.Errorf("while looking for primary partition: %s", err)
+	}
+	if fstype, err := part.GetFsType(); err != nil || fstype != sif.FsSquash {
+		return "", fmt.Errorf("only squashfs root filesystems can be lazily loaded")
+	}
+
+	sylog.Verbosef("Lazily loading %d bytes root filesystem of %s", part.Filelen, u)
+
+	lazyDevice, err = nbd.Attach(io.NewSectionReader(c, part.Fileoff, part.Filelen), part.Filelen)
+	if err != nil {
+		return "", err
+	}
+
+	return lazyDevice.Path, nil
+}
+
+// runLazy runs the starter as a child process while the lazily loaded
+// image is served, it exits with the container exit status
+func runLazy(starter string, procname string, env []string, configData []byte) {
+	cmd, err := exec.PipeCommand(starter, []string{procname}, env, configData)
+	if err != nil {
+		sylog.Fatalf("failed to prepare command: %s", err)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// terminal signals are received by the container directly
+	signal.Ignore(syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	if err := cmd.Start(); err != nil {
+		lazyDevice.Detach()
+		sylog.Fatalf("failed to start container: %s", err)
+	}
+
+	go func() {
+		for s := range signals {
+			cmd.Process.Signal(s)
+		}
+	}()
+
+	err = cmd.Wait()
+	signal.Stop(signals)
+
+	if derr := lazyDevice.Detach(); derr != nil {
+		sylog.Warningf("failed to detach %s: %s", lazyDevice.Path, derr)
+	}
+
+	status := 0
+	if err != nil {
+		status = 255
+		if cmd.ProcessState == nil {
+			sylog.Errorf("%s", err)
+		} else if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			if ws.Signaled() {
+				status = 128 + int(ws.Signal())
+			} else {
+				status = ws.ExitStatus()
+			}
+		}
+	}
+	os.Exit(status)
+}
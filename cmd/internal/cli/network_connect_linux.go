@@ -0,0 +1,44 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+func init() {
+	// -u|--user
+	NetworkConnectCmd.Flags().StringVarP(&NetworkUser, "user", "u", "", "connect an instance of the given user")
+	NetworkConnectCmd.Flags().SetAnnotation("user", "argtag", []string{"<username>"})
+	NetworkConnectCmd.Flags().SetAnnotation("user", "envkey", []string{"USER"})
+
+	NetworkConnectCmd.Flags().SetInterspersed(false)
+}
+
+// NetworkConnectCmd singularity network connect
+var NetworkConnectCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := singularity.NetworkConnectConfig{
+			Network:  args[0],
+			Instance: args[1],
+			User:     NetworkUser,
+		}
+
+		if err := singularity.NetworkConnect(getCNIPath(), c); err != nil {
+			sylog.Fatalf("Unable to connect instance: %s", err)
+		}
+	},
+
+	Use:     docs.NetworkConnectUse,
+	Short:   docs.NetworkConnectShort,
+	Long:    docs.NetworkConnectLong,
+	Example: docs.NetworkConnectExample,
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+func init() {
+	// -t|--type
+	NetworkCreateCmd.Flags().StringVarP(&NetworkType, "type", "t", "bridge", "network type: bridge, ptp or macvlan")
+	NetworkCreateCmd.Flags().SetAnnotation("type", "argtag", []string{"<type>"})
+	NetworkCreateCmd.Flags().SetAnnotation("type", "envkey", []string{"NETWORK_TYPE"})
+
+	// --subnet
+	NetworkCreateCmd.Flags().StringVar(&NetworkSubnet, "subnet", "", "subnet allocated to containers in CIDR notation (required)")
+	NetworkCreateCmd.Flags().SetAnnotation("subnet", "argtag", []string{"<cidr>"})
+	NetworkCreateCmd.Flags().SetAnnotation("subnet", "envkey", []string{"NETWORK_SUBNET"})
+
+	// --gateway
+	NetworkCreateCmd.Flags().StringVar(&NetworkGateway, "gateway", "", "gateway IP address in subnet")
+	NetworkCreateCmd.Flags().SetAnnotation("gateway", "argtag", []string{"<ip>"})
+	NetworkCreateCmd.Flags().SetAnnotation("gateway", "envkey", []string{"NETWORK_GATEWAY"})
+
+	// --bridge
+	NetworkCreateCmd.Flags().StringVar(&NetworkBridge, "bridge", "", "bridge interface name for bridge network")
+	NetworkCreateCmd.Flags().SetAnnotation("bridge", "argtag", []string{"<name>"})
+	NetworkCreateCmd.Flags().SetAnnotation("bridge", "envkey", []string{"NETWORK_BRIDGE"})
+
+	// --master
+	NetworkCreateCmd.Flags().StringVar(&NetworkMaster, "master", "", "host interface for macvlan network")
+	NetworkCreateCmd.Flags().SetAnnotation("master", "argtag", []string{"<interface>"})
+	NetworkCreateCmd.Flags().SetAnnotation("master", "envkey", []string{"NETWORK_MASTER"})
+
+	NetworkCreateCmd.Flags().SetInterspersed(false)
+}
+
+// NetworkCreateCmd singularity network create
+var NetworkCreateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := network.ConfListConfig{
+			Name:    args[0],
+			Type:    NetworkType,
+			Subnet:  NetworkSubnet,
+			Gateway: NetworkGateway,
+			Bridge:  NetworkBridge,
+			Master:  NetworkMaster,
+		}
+
+		if err := singularity.NetworkCreate(getCNIPath(), c); err != nil {
+			sylog.Fatalf("Unable to create network: %s", err)
+		}
+	},
+
+	Use:     docs.NetworkCreateUse,
+	Short:   docs.NetworkCreateShort,
+	Long:    docs.NetworkCreateLong,
+	Example: docs.NetworkCreateExample,
+}
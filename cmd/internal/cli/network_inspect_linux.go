@@ -0,0 +1,29 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// NetworkInspectCmd singularity network inspect
+var NetworkInspectCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkInspect(getCNIPath(), args); err != nil {
+			sylog.Fatalf("Unable to inspect network: %s", err)
+		}
+	},
+
+	Use:     docs.NetworkInspectUse,
+	Short:   docs.NetworkInspectShort,
+	Long:    docs.NetworkInspectLong,
+	Example: docs.NetworkInspectExample,
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+// contains flag variables for network commands
+var (
+	NetworkType    string
+	NetworkSubnet  string
+	NetworkGateway string
+	NetworkBridge  string
+	NetworkMaster  string
+	NetworkUser    string
+)
+
+func init() {
+	SingularityCmd.AddCommand(NetworkCmd)
+	NetworkCmd.AddCommand(NetworkListCmd)
+	NetworkCmd.AddCommand(NetworkInspectCmd)
+	NetworkCmd.AddCommand(NetworkCreateCmd)
+	NetworkCmd.AddCommand(NetworkRemoveCmd)
+	NetworkCmd.AddCommand(NetworkConnectCmd)
+	NetworkCmd.AddCommand(NetworkDisconnectCmd)
+}
+
+// NetworkCmd is the network command
+var NetworkCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("Invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.NetworkUse,
+	Short:         docs.NetworkShort,
+	Long:          docs.NetworkLong,
+	Example:       docs.NetworkExample,
+	SilenceErrors: true,
+}
+
+// getCNIPath returns CNI configuration and plugins paths
+// set in singularity.conf
+func getCNIPath() *network.CNIPath {
+	file := &singularityConfig.FileConfig{}
+
+	configurationFile := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+	if err := config.Parser(configurationFile, file); err != nil {
+		sylog.Fatalf("Unable to parse singularity.conf file: %s", err)
+	}
+
+	cniPath := &network.CNIPath{
+		Conf:   filepath.Join(buildcfg.SYSCONFDIR, "singularity", "network"),
+		Plugin: filepath.Join(buildcfg.LIBEXECDIR, "singularity", "cni"),
+	}
+	if file.CniConfPath != "" {
+		cniPath.Conf = file.CniConfPath
+	}
+	if file.CniPluginPath != "" {
+		cniPath.Plugin = file.CniPluginPath
+	}
+	return cniPath
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// NetworkListCmd singularity network list
+var NetworkListCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkList(getCNIPath()); err != nil {
+			sylog.Fatalf("Unable to list networks: %s", err)
+		}
+	},
+
+	Use:     docs.NetworkListUse,
+	Short:   docs.NetworkListShort,
+	Long:    docs.NetworkListLong,
+	Example: docs.NetworkListExample,
+}
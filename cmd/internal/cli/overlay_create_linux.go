@@ -0,0 +1,48 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+func init() {
+	// -s|--size
+	OverlayCreateCmd.Flags().Int64VarP(&OverlaySize, "size", "s", 0, "size of the overlay in MiB (required)")
+	OverlayCreateCmd.Flags().SetAnnotation("size", "argtag", []string{"<MiB>"})
+	OverlayCreateCmd.Flags().SetAnnotation("size", "envkey", []string{"OVERLAY_SIZE"})
+
+	// --sparse
+	OverlayCreateCmd.Flags().BoolVar(&OverlaySparse, "sparse", false, "create a sparse overlay image file")
+	OverlayCreateCmd.Flags().SetAnnotation("sparse", "envkey", []string{"OVERLAY_SPARSE"})
+
+	OverlayCreateCmd.Flags().SetInterspersed(false)
+}
+
+// OverlayCreateCmd singularity overlay create
+var OverlayCreateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := singularity.OverlayConfig{
+			Path:   args[0],
+			Size:   OverlaySize,
+			Sparse: OverlaySparse,
+		}
+
+		if err := singularity.OverlayCreate(c); err != nil {
+			sylog.Fatalf("Unable to create overlay: %s", err)
+		}
+	},
+
+	Use:     docs.OverlayCreateUse,
+	Short:   docs.OverlayCreateShort,
+	Long:    docs.OverlayCreateLong,
+	Example: docs.OverlayCreateExample,
+}
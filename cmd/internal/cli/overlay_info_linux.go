@@ -0,0 +1,33 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// OverlayInfoCmd singularity overlay info
+var OverlayInfoCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := singularity.OverlayConfig{
+			Path: args[0],
+		}
+
+		if err := singularity.OverlayInfo(c); err != nil {
+			sylog.Fatalf("Unable to display overlay information: %s", err)
+		}
+	},
+
+	Use:     docs.OverlayInfoUse,
+	Short:   docs.OverlayInfoShort,
+	Long:    docs.OverlayInfoLong,
+	Example: docs.OverlayInfoExample,
+}
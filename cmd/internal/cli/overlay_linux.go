@@ -0,0 +1,41 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+)
+
+// contains flag variables for overlay commands
+var (
+	OverlaySize   int64
+	OverlaySparse bool
+)
+
+func init() {
+	SingularityCmd.AddCommand(OverlayCmd)
+	OverlayCmd.AddCommand(OverlayCreateCmd)
+	OverlayCmd.AddCommand(OverlayResizeCmd)
+	OverlayCmd.AddCommand(OverlayRemoveCmd)
+	OverlayCmd.AddCommand(OverlayInfoCmd)
+}
+
+// OverlayCmd is the overlay command
+var OverlayCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("Invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.OverlayUse,
+	Short:         docs.OverlayShort,
+	Long:          docs.OverlayLong,
+	Example:       docs.OverlayExample,
+	SilenceErrors: true,
+}
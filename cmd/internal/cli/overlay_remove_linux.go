@@ -0,0 +1,33 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// OverlayRemoveCmd singularity overlay remove
+var OverlayRemoveCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := singularity.OverlayConfig{
+			Path: args[0],
+		}
+
+		if err := singularity.OverlayRemove(c); err != nil {
+			sylog.Fatalf("Unable to remove overlay: %s", err)
+		}
+	},
+
+	Use:     docs.OverlayRemoveUse,
+	Short:   docs.OverlayRemoveShort,
+	Long:    docs.OverlayRemoveLong,
+	Example: docs.OverlayRemoveExample,
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+func init() {
+	// -s|--size
+	OverlayResizeCmd.Flags().Int64VarP(&OverlaySize, "size", "s", 0, "new size of the overlay in MiB (required)")
+	OverlayResizeCmd.Flags().SetAnnotation("size", "argtag", []string{"<MiB>"})
+	OverlayResizeCmd.Flags().SetAnnotation("size", "envkey", []string{"OVERLAY_SIZE"})
+
+	OverlayResizeCmd.Flags().SetInterspersed(false)
+}
+
+// OverlayResizeCmd singularity overlay resize
+var OverlayResizeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		c := singularity.OverlayConfig{
+			Path: args[0],
+			Size: OverlaySize,
+		}
+
+		if err := singularity.OverlayResize(c); err != nil {
+			sylog.Fatalf("Unable to resize overlay: %s", err)
+		}
+	},
+
+	Use:     docs.OverlayResizeUse,
+	Short:   docs.OverlayResizeShort,
+	Long:    docs.OverlayResizeLong,
+	Example: docs.OverlayResizeExample,
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/pkg/build/types"
 	client "github.com/sylabs/singularity/pkg/client/library"
+	net "github.com/sylabs/singularity/pkg/client/net"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
 )
 
 const (
@@ -59,6 +61,10 @@ func init() {
 	PullCmd.Flags().Lookup("tmpdir").Hidden = true
 	PullCmd.Flags().SetAnnotation("tmpdir", "envkey", []string{"TMPDIR"})
 
+	PullCmd.Flags().IntVar(&net.Parallel, "parallel", net.DefaultParallel, "number of concurrent requests used to download an image")
+	PullCmd.Flags().SetAnnotation("parallel", "argtag", []string{"<n>"})
+	PullCmd.Flags().SetAnnotation("parallel", "envkey", []string{"PARALLEL"})
+
 	PullCmd.Flags().BoolVar(&noHTTPS, "nohttps", false, "do NOT use HTTPS, for communicating with local docker registry")
 	PullCmd.Flags().SetAnnotation("nohttps", "envkey", []string{"NOHTTPS"})
 
@@ -136,43 +142,45 @@ func pullRun(cmd *cobra.Command, args []string) {
 			imageName = uri.GetName(args[i])
 		}
 		imagePath := cache.LibraryImage(libraryImage.Hash, imageName)
+
+		fd, err := cache.LockEntry(imagePath)
+		if err != nil {
+			sylog.Fatalf("unable to lock %v: %v", imagePath, err)
+		}
+		defer lock.Release(fd)
+
 		if exists, err := cache.LibraryImageExists(libraryImage.Hash, imageName); err != nil {
 			sylog.Fatalf("unable to check if %v exists: %v", imagePath, err)
 		} else if !exists {
 			sylog.Infof("Downloading library image")
-			if err = client.DownloadImage(imagePath, args[i], PullLibraryURI, true, authToken); err != nil {
+			if err = client.DownloadImageHash(imagePath, args[i], PullLibraryURI, true, authToken, libraryImage.Hash); err != nil {
 				sylog.Fatalf("unable to Download Image: %v", err)
 			}
-
-			if cacheFileHash, err := client.ImageHash(imagePath); err != nil {
-				sylog.Fatalf("Error getting ImageHash: %v", err)
-			} else if cacheFileHash != libraryImage.Hash {
-				sylog.Fatalf("Cached File Hash(%s) and Expected Hash(%s) does not match", cacheFileHash, libraryImage.Hash)
-			}
 		}
 
-		// Perms are 777 *prior* to umask
-		dstFile, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
-		if err != nil {
+		// Copy SIF from cache
+		if err := copyImage(name, imagePath); err != nil {
 			sylog.Fatalf("%v\n", err)
 		}
-		defer dstFile.Close()
+	case ShubProtocol:
+		libexec.PullShubImage(name, args[i], force, noHTTPS)
+	case HTTPProtocol, HTTPSProtocol:
+		if !force {
+			if _, err := os.Stat(name); err == nil {
+				sylog.Fatalf("image file already exists - will not overwrite")
+			}
+		}
 
-		srcFile, err := os.OpenFile(imagePath, os.O_RDONLY, 0444)
+		// partial downloads are kept in cache to be resumed
+		imagePath, err := handleNet(args[i])
 		if err != nil {
-			sylog.Fatalf("%v\n", err)
+			sylog.Fatalf("While pulling image: %v", err)
 		}
-		defer srcFile.Close()
 
-		// Copy SIF from cache
-		_, err = io.Copy(dstFile, srcFile)
-		if err != nil {
+		// Copy image from cache
+		if err := copyImage(name, imagePath); err != nil {
 			sylog.Fatalf("%v\n", err)
 		}
-	case ShubProtocol:
-		libexec.PullShubImage(name, args[i], force, noHTTPS)
-	case HTTPProtocol, HTTPSProtocol:
-		libexec.PullNetImage(name, args[i], force)
 	default:
 		authConf, err := makeDockerCredentials(cmd)
 		if err != nil {
@@ -187,6 +195,27 @@ func pullRun(cmd *cobra.Command, args []string) {
 			NoCleanUp:        noCleanUp,
 		})
 	}
+
+	evictCache()
+}
+
+// copyImage copies the cached image src to dst
+func copyImage(dst, src string) error {
+	// Perms are 777 *prior* to umask
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	srcFile, err := os.OpenFile(src, os.O_RDONLY, 0444)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
 }
 
 func handlePullFlags(cmd *cobra.Command) {
@@ -0,0 +1,182 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+var (
+	sifJSON      bool
+	sifAddConfig singularity.SifAddConfig
+)
+
+func init() {
+	// -j|--json
+	SifListCmd.Flags().BoolVarP(&sifJSON, "json", "j", false, "print descriptors in JSON format")
+	SifInfoCmd.Flags().BoolVarP(&sifJSON, "json", "j", false, "print descriptor in JSON format")
+
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Datatype, "datatype", "", "data object type: deffile, envvar, labels, partition, signature, generic-json or cryptomessage (required)")
+	SifAddCmd.Flags().SetAnnotation("datatype", "argtag", []string{"<type>"})
+	SifAddCmd.Flags().Uint32Var(&sifAddConfig.Group, "groupid", 0, "group number of the data object, 0 for no group")
+	SifAddCmd.Flags().SetAnnotation("groupid", "argtag", []string{"<number>"})
+	SifAddCmd.Flags().Uint32Var(&sifAddConfig.Link, "link", 0, "ID of the data object linked to, 0 for no link")
+	SifAddCmd.Flags().SetAnnotation("link", "argtag", []string{"<id>"})
+	SifAddCmd.Flags().BoolVar(&sifAddConfig.LinkGroup, "link-group", false, "interpret --link as a group number")
+	SifAddCmd.Flags().IntVar(&sifAddConfig.Alignment, "alignment", 0, "alignment of the data object, defaults to page size")
+	SifAddCmd.Flags().SetAnnotation("alignment", "argtag", []string{"<bytes>"})
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Fstype, "partfs", "", "partition filesystem type: squashfs, ext3, immuobj, raw or encryptedsquashfs")
+	SifAddCmd.Flags().SetAnnotation("partfs", "argtag", []string{"<fstype>"})
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Parttype, "parttype", "", "partition type: system, primsys, data or overlay")
+	SifAddCmd.Flags().SetAnnotation("parttype", "argtag", []string{"<type>"})
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Arch, "partarch", "", "partition architecture, defaults to host architecture")
+	SifAddCmd.Flags().SetAnnotation("partarch", "argtag", []string{"<arch>"})
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Hashtype, "signhash", "", "signature hash type: sha256, sha384, sha512, blake2s, blake2b or x509-sha384")
+	SifAddCmd.Flags().SetAnnotation("signhash", "argtag", []string{"<hash>"})
+	SifAddCmd.Flags().StringVar(&sifAddConfig.Entity, "signentity", "", "signing key fingerprint")
+	SifAddCmd.Flags().SetAnnotation("signentity", "argtag", []string{"<fingerprint>"})
+	SifAddCmd.Flags().SetInterspersed(false)
+
+	SingularityCmd.AddCommand(SifCmd)
+	SifCmd.AddCommand(SifListCmd)
+	SifCmd.AddCommand(SifInfoCmd)
+	SifCmd.AddCommand(SifDumpCmd)
+	SifCmd.AddCommand(SifAddCmd)
+	SifCmd.AddCommand(SifDelCmd)
+	SifCmd.AddCommand(SifSetPrimCmd)
+	SifCmd.AddCommand(SifHeaderCmd)
+}
+
+// parseSifID returns the descriptor ID passed as argument
+func parseSifID(arg string) uint32 {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || id == 0 {
+		sylog.Fatalf("Invalid data object ID %q", arg)
+	}
+	return uint32(id)
+}
+
+// SifCmd singularity sif ...
+var SifCmd = &cobra.Command{
+	Run: nil,
+
+	Use:     docs.SifUse,
+	Short:   docs.SifShort,
+	Long:    docs.SifLong,
+	Example: docs.SifExample,
+}
+
+// SifListCmd singularity sif list <file>
+var SifListCmd = &cobra.Command{
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifList(args[0], sifJSON); err != nil {
+			sylog.Fatalf("Unable to list descriptors: %s", err)
+		}
+	},
+
+	Use:     docs.SifListUse,
+	Short:   docs.SifListShort,
+	Long:    docs.SifListLong,
+	Example: docs.SifListExample,
+}
+
+// SifInfoCmd singularity sif info <id> <file>
+var SifInfoCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifInfo(args[1], parseSifID(args[0]), sifJSON); err != nil {
+			sylog.Fatalf("Unable to display descriptor: %s", err)
+		}
+	},
+
+	Use:     docs.SifInfoUse,
+	Short:   docs.SifInfoShort,
+	Long:    docs.SifInfoLong,
+	Example: docs.SifInfoExample,
+}
+
+// SifDumpCmd singularity sif dump <id> <file>
+var SifDumpCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifDump(args[1], parseSifID(args[0]), os.Stdout); err != nil {
+			sylog.Fatalf("Unable to dump data object: %s", err)
+		}
+	},
+
+	Use:     docs.SifDumpUse,
+	Short:   docs.SifDumpShort,
+	Long:    docs.SifDumpLong,
+	Example: docs.SifDumpExample,
+}
+
+// SifAddCmd singularity sif add [options] <file> <object>
+var SifAddCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifAdd(args[0], args[1], sifAddConfig); err != nil {
+			sylog.Fatalf("Unable to add data object: %s", err)
+		}
+	},
+
+	Use:     docs.SifAddUse,
+	Short:   docs.SifAddShort,
+	Long:    docs.SifAddLong,
+	Example: docs.SifAddExample,
+}
+
+// SifDelCmd singularity sif del <id> <file>
+var SifDelCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifDel(args[1], parseSifID(args[0])); err != nil {
+			sylog.Fatalf("Unable to delete data object: %s", err)
+		}
+	},
+
+	Use:     docs.SifDelUse,
+	Short:   docs.SifDelShort,
+	Long:    docs.SifDelLong,
+	Example: docs.SifDelExample,
+}
+
+// SifSetPrimCmd singularity sif setprim <id> <file>
+var SifSetPrimCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifSetPrim(args[1], parseSifID(args[0])); err != nil {
+			sylog.Fatalf("Unable to set primary partition: %s", err)
+		}
+	},
+
+	Use:     docs.SifSetPrimUse,
+	Short:   docs.SifSetPrimShort,
+	Long:    docs.SifSetPrimLong,
+	Example: docs.SifSetPrimExample,
+}
+
+// SifHeaderCmd singularity sif header <file>
+var SifHeaderCmd = &cobra.Command{
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SifHeader(args[0]); err != nil {
+			sylog.Fatalf("Unable to display header: %s", err)
+		}
+	},
+
+	Use:     docs.SifHeaderUse,
+	Short:   docs.SifHeaderShort,
+	Long:    docs.SifHeaderLong,
+	Example: docs.SifHeaderExample,
+}
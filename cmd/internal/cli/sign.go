@@ -16,7 +16,11 @@ import (
 )
 
 var (
-	privKey int // -k encryption key (index from 'keys list') specification
+	privKey      int    // -k encryption key (index from 'keys list') specification
+	signCert     string // --cert X.509 signing certificate
+	signKey      string // --key X.509 signing private key
+	signAll      bool   // --all data objects
+	signDetached string // --detached signature file
 )
 
 func init() {
@@ -27,6 +31,11 @@ func init() {
 	SignCmd.Flags().Uint32VarP(&sifGroupID, "groupid", "g", 0, "group ID to be signed")
 	SignCmd.Flags().Uint32VarP(&sifDescID, "id", "i", 0, "descriptor ID to be signed")
 	SignCmd.Flags().IntVarP(&privKey, "keyidx", "k", -1, "private key to use (index from 'keys list')")
+	SignCmd.Flags().BoolVarP(&signAll, "all", "a", false, "sign each data object of the image")
+	SignCmd.Flags().StringVar(&signDetached, "detached", "", "write signatures to a detached signature file instead of the image")
+	SignCmd.Flags().StringVar(&signCert, "cert", "", "sign with an X.509 certificate instead of an OpenPGP key, PEM file with the signing certificate first")
+	SignCmd.Flags().StringVar(&signKey, "key", "", "PEM file of the private key of the X.509 certificate set with --cert")
+	addPassphraseFlags(SignCmd.Flags())
 
 	SingularityCmd.AddCommand(SignCmd)
 }
@@ -44,7 +53,11 @@ var SignCmd = &cobra.Command{
 			sylog.Errorf("signing container failed: %s", err)
 			os.Exit(2)
 		}
-		fmt.Printf("Signature created and applied to %v\n", args[0])
+		if signDetached != "" {
+			fmt.Printf("Signature created and written to %v\n", signDetached)
+		} else {
+			fmt.Printf("Signature created and applied to %v\n", args[0])
+		}
 	},
 
 	Use:     docs.SignUse,
@@ -57,15 +70,30 @@ func doSignCmd(cpath, url string) error {
 	if sifGroupID != 0 && sifDescID != 0 {
 		return fmt.Errorf("only one of -i or -g may be set")
 	}
+	if signAll && (sifGroupID != 0 || sifDescID != 0) {
+		return fmt.Errorf("--all can't be used with -i or -g")
+	}
 
-	var isGroup bool
-	var id uint32
+	opts := signing.SignOptions{
+		All:      signAll,
+		Detached: signDetached,
+	}
 	if sifGroupID != 0 {
-		isGroup = true
-		id = sifGroupID
+		opts.IsGroup = true
+		opts.ID = sifGroupID
 	} else {
-		id = sifDescID
+		opts.ID = sifDescID
+	}
+
+	if signCert != "" || signKey != "" {
+		if signCert == "" || signKey == "" {
+			return fmt.Errorf("--cert and --key must be set together")
+		}
+		if privKey != -1 {
+			return fmt.Errorf("--keyidx can't be used with --cert")
+		}
+		return signing.SignX509(cpath, signCert, signKey, opts)
 	}
 
-	return signing.Sign(cpath, url, id, isGroup, privKey, authToken)
+	return signing.Sign(cpath, url, privKey, authToken, opts)
 }
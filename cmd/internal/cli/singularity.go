@@ -288,11 +288,20 @@ var flagEnvFuncs = map[string]envHandle{
 	"hostname":      envStringNSlice,
 	"network":       envStringNSlice,
 	"network-args":  envStringNSlice,
+	"publish":       envStringNSlice,
 	"dns":           envStringNSlice,
 	"containlibs":   envStringNSlice,
 	"security":      envStringNSlice,
 	"apply-cgroups": envStringNSlice,
 	"app":           envStringNSlice,
+	"memory":        envStringNSlice,
+	"memory-swap":   envStringNSlice,
+	"cpus":          envStringNSlice,
+	"cpu-shares":    envStringNSlice,
+	"cpuset-cpus":   envStringNSlice,
+	"pids-limit":    envStringNSlice,
+	"blkio-weight":  envStringNSlice,
+	"pem-path":      envStringNSlice,
 
 	"boot":           envBool,
 	"fakeroot":       envBool,
@@ -306,6 +315,7 @@ var flagEnvFuncs = map[string]envHandle{
 	"writable-tmpfs": envBool,
 	"no-home":        envBool,
 	"no-init":        envBool,
+	"lazy":           envBool,
 
 	"pid":    envBool,
 	"ipc":    envBool,
@@ -333,7 +343,9 @@ var flagEnvFuncs = map[string]envHandle{
 	"builder":         envStringNSlice,
 	"library":         envStringNSlice,
 	"nohttps":         envBool,
+	"parallel":        envStringNSlice,
 	"no-cleanup":      envBool,
+	"encrypt":         envBool,
 	"tmpdir":          envStringNSlice,
 	"docker-username": envStringNSlice,
 	"docker-password": envStringNSlice,
@@ -349,8 +361,10 @@ var flagEnvFuncs = map[string]envHandle{
 	"signal": envStringNSlice,
 
 	// keys flags
-	"secret": envBool,
-	"url":    envStringNSlice,
+	"secret":     envBool,
+	"url":        envStringNSlice,
+	"key-policy": envStringNSlice,
+	"ca-bundle":  envStringNSlice,
 
 	// inspect flags
 	"labels":      envBool,
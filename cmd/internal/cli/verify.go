@@ -6,6 +6,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -18,6 +19,12 @@ import (
 var (
 	sifGroupID uint32 // -g groupid specification
 	sifDescID  uint32 // -i id specification
+	verifyJSON bool   // --json output
+	verifyAll  bool   // --all signed objects
+
+	verifyKeyPolicy string // --key-policy on expired or revoked keys
+	verifyCABundle  string // --ca-bundle to verify X.509 signatures
+	verifySignature string // --signature detached signature file
 )
 
 func init() {
@@ -27,6 +34,15 @@ func init() {
 	VerifyCmd.Flags().SetAnnotation("url", "envkey", []string{"URL"})
 	VerifyCmd.Flags().Uint32VarP(&sifGroupID, "groupid", "g", 0, "group ID to be verified")
 	VerifyCmd.Flags().Uint32VarP(&sifDescID, "id", "i", 0, "descriptor ID to be verified")
+	VerifyCmd.Flags().BoolVarP(&verifyJSON, "json", "j", false, "output a JSON report of the verification of each signature")
+	VerifyCmd.Flags().SetAnnotation("json", "envkey", []string{"JSON"})
+	VerifyCmd.Flags().BoolVarP(&verifyAll, "all", "a", false, "verify all signed data objects")
+	VerifyCmd.Flags().SetAnnotation("all", "envkey", []string{"ALL"})
+	VerifyCmd.Flags().StringVar(&verifyKeyPolicy, "key-policy", string(signing.KeyPolicyFail), "action on signatures made with expired or revoked keys: fail or warn")
+	VerifyCmd.Flags().SetAnnotation("key-policy", "envkey", []string{"KEY_POLICY"})
+	VerifyCmd.Flags().StringVar(&verifyCABundle, "ca-bundle", "", "PEM file of the CA certificates trusted to verify X.509 signatures")
+	VerifyCmd.Flags().SetAnnotation("ca-bundle", "envkey", []string{"CA_BUNDLE"})
+	VerifyCmd.Flags().StringVar(&verifySignature, "signature", "", "verify the signatures of a detached signature file instead of the image")
 	SingularityCmd.AddCommand(VerifyCmd)
 }
 
@@ -38,7 +54,9 @@ var VerifyCmd = &cobra.Command{
 
 	Run: func(cmd *cobra.Command, args []string) {
 		// args[0] contains image path
-		fmt.Printf("Verifying image: %s\n", args[0])
+		if !verifyJSON {
+			fmt.Printf("Verifying image: %s\n", args[0])
+		}
 		if err := doVerifyCmd(args[0], keyServerURL); err != nil {
 			sylog.Errorf("verification failed: %s", err)
 			os.Exit(2)
@@ -51,19 +69,71 @@ var VerifyCmd = &cobra.Command{
 	Example: docs.VerifyExample,
 }
 
+// verifyReport is the JSON report of verify --json
+type verifyReport struct {
+	Image      string                    `json:"image"`
+	Verified   bool                      `json:"verified"`
+	Error      string                    `json:"error,omitempty"`
+	Signatures []signing.SignatureResult `json:"signatures"`
+}
+
 func doVerifyCmd(cpath, url string) error {
 	if sifGroupID != 0 && sifDescID != 0 {
 		return fmt.Errorf("only one of -i or -g may be set")
 	}
+	if verifyAll && (sifGroupID != 0 || sifDescID != 0) {
+		return fmt.Errorf("--all can't be used with -i or -g")
+	}
 
-	var isGroup bool
-	var id uint32
+	policy, err := signing.ParseKeyPolicy(verifyKeyPolicy)
+	if err != nil {
+		return err
+	}
+
+	opts := signing.VerifyOptions{
+		KeyServerURL: url,
+		AuthToken:    authToken,
+		All:          verifyAll,
+		KeyPolicy:    policy,
+		Signature:    verifySignature,
+	}
+	if verifyCABundle != "" {
+		if opts.Roots, err = signing.LoadCABundle(verifyCABundle); err != nil {
+			return fmt.Errorf("could not load CA bundle: %s", err)
+		}
+	}
 	if sifGroupID != 0 {
-		isGroup = true
-		id = sifGroupID
+		opts.IsGroup = true
+		opts.ID = sifGroupID
 	} else {
-		id = sifDescID
+		opts.ID = sifDescID
+	}
+
+	if !verifyJSON {
+		return signing.Verify(cpath, opts)
+	}
+
+	report := verifyReport{Image: cpath, Signatures: []signing.SignatureResult{}}
+	results, err := signing.VerifyResults(cpath, opts)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Signatures = results
+		report.Verified = len(results) > 0
+		for _, res := range results {
+			if !res.Verified {
+				report.Verified = false
+				err = fmt.Errorf("signature %d of %s not verified: %s", res.SignatureID, cpath, res.Error)
+			}
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	if jerr := enc.Encode(report); jerr != nil {
+		return fmt.Errorf("could not format report: %s", jerr)
 	}
 
-	return signing.Verify(cpath, url, id, isGroup, authToken, false)
+	return err
 }
@@ -154,7 +154,13 @@ Enterprise Performance Computing (EPC)`
       Build a base sandbox from DockerHub, make changes to it, then build sif
           $ singularity build --sandbox /tmp/debian docker://debian:latest
           $ singularity exec --writable /tmp/debian apt-get install python
-          $ singularity build /tmp/debian2.sif /tmp/debian`
+          $ singularity build /tmp/debian2.sif /tmp/debian
+
+      Build a sif image with an encrypted root filesystem, the key is either a
+      passphrase or a random key wrapped with a PEM formatted RSA public key:
+          $ sudo singularity build --encrypt --passphrase /tmp/debian3.sif docker://debian:latest
+          $ sudo singularity build --encrypt --pem-path public.pem /tmp/debian4.sif docker://debian:latest
+          $ sudo singularity run --pem-path private.pem /tmp/debian4.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Cache
@@ -162,8 +168,8 @@ Enterprise Performance Computing (EPC)`
 	CacheUse   string = `cache <subcommand>`
 	CacheShort string = `Manage your local singularity cache`
 	CacheLong  string = `
-  Manage your local singularity cache. There are 3 types of cache; library, oci, and blob.
-  You can list/clean using the spicific types.`
+  Manage your local singularity cache. There are 4 types of cache; library, oci, sandbox,
+  and blob. You can list/clean using the spicific types.`
 	CacheExample string = `
   All group commands have their own help output:
 
@@ -177,13 +183,22 @@ Enterprise Performance Computing (EPC)`
 	CacheCleanShort string = `Clean your local Singularity cache`
 	CacheCleanLong  string = `
   This will clean you local cache: "${HOME}/.singularity/cache". The available cache
-  types are: library, oci, and blob. By default cache clean will only clean blob cache,
-  use: '--all' to clean all cache.`
+  types are: library, oci, sandbox, and blob. By default cache clean will only clean blob
+  cache, use: '--all' to clean all cache. Sandboxes unpacked from oci: and docker-archive:
+  images are not removed while containers are running from them, nor are images being
+  downloaded by another process.
+
+  '--days' removes cache entries unused for more than the given number of days, and
+  '--size' removes least recently used entries until the cache is not larger than the
+  given size. The same limits are applied after each pull when set with 'cache max size'
+  and 'cache max age' in singularity.conf, or with the SINGULARITY_CACHE_MAXSIZE and
+  SINGULARITY_CACHE_MAXAGE environment variables.`
 	CacheCleanExample string = `
   All group commands have their own help output:
 
   $ singularity help cache clean --name cache_name.sif
   $ singularity help cache clean --type=library,oci
+  $ singularity cache clean --days 30 --size 10G
   $ singularity cache clean --help`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -193,7 +208,7 @@ Enterprise Performance Computing (EPC)`
 	CacheListShort string = `List your local Singularity cache`
 	CacheListLong  string = `
   This will list you local cache: "${HOME}/.singularity/cache". The available cache
-  types are: library, oci, and blob.`
+  types are: library, oci, sandbox, and blob.`
 	CacheListExample string = `
   All group commands have their own help output:
 
@@ -226,23 +241,53 @@ Enterprise Performance Computing (EPC)`
 	KeyImportUse   string = `import [import options...] <full-path-to-local-key>`
 	KeyImportShort string = `Import a local key into the local Singularity key store`
 	KeyImportLong  string = `
-  The 'key import' command allows you to add to your local key store, keys from a specific local folder`
+  The 'key import' command allows you to add to your local key store, keys from a specific local folder.
+  Keys may be binary or ASCII armored, private keys are added to the private
+  key store and public keys to the public key store.
+  With --global, the root user adds public keys to the system global keyring,
+  trusted by all users to verify containers and used by the execution control
+  list.`
 	KeyImportExample string = `
   $ singularity key import $HOME/key.asc
   $ singularity keys import $HOME/key.asc
+  $ sudo singularity key import --global $HOME/key.asc
   `
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key export
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeyExportUse   string = `export [export options...] <fingerprint> <output-file>`
+	KeyExportShort string = `Export a key from the local Singularity key store`
+	KeyExportLong  string = `
+  The 'key export' command allows you to write a public key, or a private key
+  with --secret, from your local key store to a new file. Keys are exported
+  binary, or ASCII armored with --armor, and can be added to another key store
+  with 'key import'. Private keys are exported encrypted with their
+  passphrase.`
+	KeyExportExample string = `
+  $ singularity key export D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934 key.pub
+  $ singularity key export --secret --armor D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934 key.asc`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// key newpair
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
-	KeyNewPairUse   string = `newpair`
+	KeyNewPairUse   string = `newpair [newpair options...]`
 	KeyNewPairShort string = `Create a new OpenPGP key pair`
 	KeyNewPairLong  string = `
   The 'key newpair' command allows you to create a new key or public/private
   keys to be stored in the default user local key store location (e.g., 
-  $HOME/.singularity/sypgp).`
+  $HOME/.singularity/sypgp). The identity of the key is asked interactively
+  unless --name is set, and the public key is pushed to the key server unless
+  --push=false is set.
+
+  The passphrase of the private key is read from the file descriptor set by
+  --password-fd, the first line of the file set by --password-file or the
+  SINGULARITY_KEY_PASSPHRASE environment variable, otherwise it is asked
+  interactively. The sign command reads the passphrase the same way.`
 	KeyNewPairExample string = `
-  $ singularity key newpair`
+  $ singularity key newpair
+  $ singularity key newpair --name "John Doe" --email john.doe@example.com \
+      --password-file passphrase.txt --push=false`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// key list
@@ -251,9 +296,45 @@ Enterprise Performance Computing (EPC)`
 	KeyListShort string = `List keys from the default key store`
 	KeyListLong  string = `
   The 'key list' command allows you to list public/private key pairs from the 
-  default user local key store location (e.g., $HOME/.singularity/sypgp).`
+  default user local key store location (e.g., $HOME/.singularity/sypgp).
+  With --global, the public keys of the system global keyring are listed.`
 	KeyListExample string = `
-  $ singularity key list`
+  $ singularity key list
+  $ singularity key list --global`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key remove
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeyRemoveUse   string = `remove [remove options...] <fingerprint>`
+	KeyRemoveShort string = `Remove a key from a key store`
+	KeyRemoveLong  string = `
+  The 'key remove' command allows you to remove a public key, or a private key
+  with --secret, from the default user local key store. With --global, the
+  root user removes a public key from the system global keyring.`
+	KeyRemoveExample string = `
+  $ singularity key remove D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934
+  $ singularity key remove --secret D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934
+  $ sudo singularity key remove --global D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key revoke
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeyRevokeUse   string = `revoke [revoke options...] <fingerprint>`
+	KeyRevokeShort string = `Revoke a key of the local Singularity key store`
+	KeyRevokeLong  string = `
+  The 'key revoke' command allows you to revoke one of your keys with its
+  private key from the local key store, e.g. when it is compromised or
+  superseded. The revoked public key is stored in the local key store and
+  pushed to the key server unless --push=false is set. With --output, the
+  revocation certificate is also written to a new file which can be added to
+  other key stores with 'key import'. Signatures made with a revoked key are
+  rejected by 'verify' unless --key-policy=warn is set.
+
+  The passphrase of the private key is read the same way as 'key newpair'.`
+	KeyRevokeExample string = `
+  $ singularity key revoke D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934
+  $ singularity key revoke --push=false --output revoke.asc \
+      D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// key search
@@ -432,6 +513,52 @@ Enterprise Performance Computing (EPC)`
   $ singularity capability list --group nobody
   $ singularity capability list --all`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// ecl
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EclUse   string = `ecl <subcommand>`
+	EclShort string = `Inspect the Execution Control List`
+	EclLong  string = `
+  The Execution Control List (ECL) of the Singularity configuration directory
+  (ecl.toml) defines execution groups restricting which signed containers may
+  run, depending on where they are stored and which user runs them. It may
+  also refuse images which can't be signed and restrict images run from
+  docker and OCI sources to a list of allowed digests.`
+	EclExample string = `
+  All group commands have their own help output:
+
+  $ singularity help ecl check
+  $ singularity ecl check --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// ecl check
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EclCheckUse   string = `check <image path>`
+	EclCheckShort string = `Explain whether an image is allowed to run by the ECL`
+	EclCheckLong  string = `
+  The 'ecl check' command explains whether the current user would be allowed
+  to run an image according to the ECL rules, even if they are not activated.
+  It shows the image path with symlinks resolved, the execution group applying
+  to the image and why, the other matching groups of lower precedence and why
+  the image would or would not run.
+
+  An execution group matches an image by its dirpath, compared exactly, as a
+  prefix or as a shell pattern to the directory of the image, and by its
+  optional users and groups. When several groups match, the one with the
+  highest priority applies, then exact dirpaths come first, then the longest
+  prefixes, patterns and groups without dirpath, then groups restricted to
+  users or groups, then the first group of the configuration file.
+
+  Images of other formats (sandbox, squashfs, ext3) can't be signed, they are
+  refused if the ECL sets denyunsigned and allowed otherwise. When the ECL sets
+  alloweddigests, images are reported as refused as they are checked without
+  a docker or OCI source.
+
+  The command exits with status 1 if the image would not be allowed to run by
+  activated ECL rules.`
+	EclCheckExample string = `
+  $ singularity ecl check /var/cache/containers/container.sif`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// exec
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -528,6 +655,24 @@ Enterprise Performance Computing (EPC)`
   $ singularity instance stop /tmp/my-sql.sif mysql
   Stopping /tmp/my-sql.sif mysql`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance stats
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceStatsUse   string = `stats [stats options...] [instance]`
+	InstanceStatsShort string = `Display resource usage of named instances`
+	InstanceStatsLong  string = `
+  The instance stats command displays memory, CPU and process usage of running
+  instances started with resource limits (--apply-cgroups, --memory, --cpus...).
+  Unprivileged users can use it for instances started within the cgroups
+  subtree delegated to them.`
+	InstanceStatsExample string = `
+  $ singularity instance start --memory 512m --pids-limit 100 my-sql.sif mysql
+  $ singularity instance stats mysql
+  INSTANCE NAME    PID      MEM USAGE / LIMIT        CPU TIME    PIDS / LIMIT
+  mysql            23845    36.4MiB / 512MiB         1.2s        18 / 100
+
+  $ singularity instance stats --json mysql`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance stop
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -553,6 +698,266 @@ Enterprise Performance Computing (EPC)`
   $ singularity instance stop -s TERM mysql1
   $ singularity instance stop -s 15 mysql1`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkUse   string = `network <subcommand>`
+	NetworkShort string = `Manage CNI networks used by containers`
+	NetworkLong  string = `
+  The network command group allows you to list and inspect CNI networks
+  available to containers started with --net, and for root user to create
+  and remove networks or to connect and disconnect running instances.`
+	NetworkExample string = `
+  All group commands have their own help output:
+
+  $ singularity help network create
+  $ singularity network create --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network list
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkListUse   string = `list`
+	NetworkListShort string = `List available networks`
+	NetworkListLong  string = `
+  The network list command lists networks defined in the CNI configuration
+  path with the plugins they use.`
+	NetworkListExample string = `
+  $ singularity network list
+  NAME     CNI VERSION  PLUGINS
+  bridge   0.3.1        bridge,portmap
+  ptp      0.3.1        ptp`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network inspect
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkInspectUse   string = `inspect <network> [<network>...]`
+	NetworkInspectShort string = `Display network configuration`
+	NetworkInspectLong  string = `
+  The network inspect command displays the CNI configuration of networks.`
+	NetworkInspectExample string = `
+  $ singularity network inspect bridge`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network create
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkCreateUse   string = `create [create options...] <network>`
+	NetworkCreateShort string = `Create a network (root user only)`
+	NetworkCreateLong  string = `
+  The network create command generates a CNI configuration file for a bridge,
+  ptp or macvlan network in the CNI configuration path. IP addresses are
+  allocated to containers from the subnet passed with --subnet. Bridge and ptp
+  networks support port publishing with --publish.`
+	NetworkCreateExample string = `
+  $ sudo singularity network create --subnet 10.30.0.0/16 mynet
+  $ sudo singularity network create --type macvlan --master eth0 \
+      --subnet 192.168.1.0/24 --gateway 192.168.1.254 lan`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network remove
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkRemoveUse   string = `remove <network>`
+	NetworkRemoveShort string = `Remove a network (root user only)`
+	NetworkRemoveLong  string = `
+  The network remove command deletes the CNI configuration file of a network.
+  A network used by running instances can't be removed.`
+	NetworkRemoveExample string = `
+  $ sudo singularity network remove mynet`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network connect
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkConnectUse   string = `connect [connect options...] <network> <instance>`
+	NetworkConnectShort string = `Connect a running instance to a network (root user only)`
+	NetworkConnectLong  string = `
+  The network connect command attaches an additional network interface to a
+  running instance started with --net. The network is torn down when the
+  instance stops or with network disconnect.`
+	NetworkConnectExample string = `
+  $ sudo singularity instance start --net my-sql.sif mysql
+  $ sudo singularity network connect mynet mysql
+  Connected instance mysql to network mynet (eth1, 10.30.0.2)`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network disconnect
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkDisconnectUse   string = `disconnect [disconnect options...] <network> <instance>`
+	NetworkDisconnectShort string = `Disconnect a running instance from a network (root user only)`
+	NetworkDisconnectLong  string = `
+  The network disconnect command detaches a running instance from a network
+  previously connected with network connect.`
+	NetworkDisconnectExample string = `
+  $ sudo singularity network disconnect mynet mysql`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayUse   string = `overlay <subcommand>`
+	OverlayShort string = `Manage persistent overlays`
+	OverlayLong  string = `
+  The overlay command group allows you to create, resize, remove and inspect
+  ext3 overlay images or ext3 overlay partitions embedded in SIF images, used
+  with the --overlay option of action commands to keep container changes.`
+	OverlayExample string = `
+  All group commands have their own help output:
+
+  $ singularity help overlay create
+  $ singularity overlay create --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay create
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayCreateUse   string = `create [create options...] <image>`
+	OverlayCreateShort string = `Create an overlay image or add an overlay partition to a SIF image`
+	OverlayCreateLong  string = `
+  The overlay create command creates an ext3 overlay image of the size given
+  with --size. If the image path points to an existing SIF image, an ext3
+  overlay partition is appended to the SIF image instead. Overlay upper and
+  work directories are owned by the calling user.`
+	OverlayCreateExample string = `
+  $ singularity overlay create --size 1024 overlay.img
+  $ singularity overlay create --size 1024 --sparse overlay.img
+  $ singularity overlay create --size 1024 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay resize
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayResizeUse   string = `resize [resize options...] <image>`
+	OverlayResizeShort string = `Resize an overlay image or a SIF overlay partition`
+	OverlayResizeLong  string = `
+  The overlay resize command grows or shrinks the ext3 filesystem of an
+  overlay image to the size given with --size. A SIF overlay partition can
+  only be resized if it is the last data object of the SIF image.`
+	OverlayResizeExample string = `
+  $ singularity overlay resize --size 2048 overlay.img
+  $ singularity overlay resize --size 2048 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay remove
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayRemoveUse   string = `remove <image>`
+	OverlayRemoveShort string = `Remove an overlay image or a SIF overlay partition`
+	OverlayRemoveLong  string = `
+  The overlay remove command deletes an ext3 overlay image or removes the
+  ext3 overlay partition from a SIF image.`
+	OverlayRemoveExample string = `
+  $ singularity overlay remove overlay.img
+  $ singularity overlay remove container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay info
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayInfoUse   string = `info <image>`
+	OverlayInfoShort string = `Display size and usage of an overlay`
+	OverlayInfoLong  string = `
+  The overlay info command displays the size and the used and free space of
+  an ext3 overlay image or of the ext3 overlay partition of a SIF image.`
+	OverlayInfoExample string = `
+  $ singularity overlay info overlay.img
+  Path:             overlay.img
+  Type:             ext3 image
+  Size:             1GiB
+  Filesystem size:  1GiB
+  Used:             33.55MiB
+  Free:             990.4MiB`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifUse   string = `sif <subcommand>`
+	SifShort string = `Inspect and modify SIF images`
+	SifLong  string = `
+  The sif command group allows you to list, display, extract, add and delete
+  data objects of a SIF image, to change its primary partition and to display
+  its global header.`
+	SifExample string = `
+  All group commands have their own help output:
+
+  $ singularity help sif list
+  $ singularity sif list --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif list
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifListUse   string = `list [list options...] <image>`
+	SifListShort string = `List data object descriptors of a SIF image`
+	SifListLong  string = `
+  The sif list command displays the descriptors of all data objects present
+  in a SIF image.`
+	SifListExample string = `
+  $ singularity sif list container.sif
+  $ singularity sif list --json container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif info
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifInfoUse   string = `info [info options...] <id> <image>`
+	SifInfoShort string = `Display detailed information of a SIF data object`
+	SifInfoLong  string = `
+  The sif info command displays all the fields of the descriptor of the data
+  object identified by <id> in a SIF image.`
+	SifInfoExample string = `
+  $ singularity sif info 1 container.sif
+  $ singularity sif info --json 1 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif dump
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifDumpUse   string = `dump <id> <image>`
+	SifDumpShort string = `Extract the content of a SIF data object`
+	SifDumpLong  string = `
+  The sif dump command writes the raw content of the data object identified
+  by <id> in a SIF image to the standard output.`
+	SifDumpExample string = `
+  $ singularity sif dump 1 container.sif > container.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif add
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifAddUse   string = `add [add options...] <image> <object>`
+	SifAddShort string = `Add a data object to a SIF image`
+	SifAddLong  string = `
+  The sif add command appends the file <object> as a new data object to a SIF
+  image. The data object type must be set with --datatype, partition objects
+  also require --partfs and --parttype and signature objects require --signhash
+  and --signentity.`
+	SifAddExample string = `
+  $ singularity sif add --datatype deffile container.sif container.def
+  $ singularity sif add --datatype partition --partfs ext3 --parttype overlay container.sif overlay.img`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif del
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifDelUse   string = `del <id> <image>`
+	SifDelShort string = `Delete a data object from a SIF image`
+	SifDelLong  string = `
+  The sif del command deletes the data object identified by <id> from a SIF
+  image. The image is truncated if the data object is the last one, otherwise
+  its content is zeroed.`
+	SifDelExample string = `
+  $ singularity sif del 3 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif setprim
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifSetPrimUse   string = `setprim <id> <image>`
+	SifSetPrimShort string = `Set the primary system partition of a SIF image`
+	SifSetPrimLong  string = `
+  The sif setprim command sets the system partition identified by <id> as the
+  primary system partition of a SIF image, the previous primary partition
+  becomes a regular system partition.`
+	SifSetPrimExample string = `
+  $ singularity sif setprim 4 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif header
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifHeaderUse   string = `header <image>`
+	SifHeaderShort string = `Display the global header of a SIF image`
+	SifHeaderLong  string = `
+  The sif header command displays the global header of a SIF image.`
+	SifHeaderExample string = `
+  $ singularity sif header container.sif`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// pull
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -569,7 +974,14 @@ Enterprise Performance Computing (EPC)`
       docker://user/image:tag
     
   shub: Pull an image from Singularity Hub to CWD
-      shub://user/image:tag`
+      shub://user/image:tag
+
+  http, https: Pull an image using the http(s?) protocol
+      https://library.sylabs.io/v1/imagefile/library/default/alpine:latest
+
+  Library and http(s) downloads are resumed when interrupted, and images are
+  downloaded with concurrent range requests when the server supports them
+  (see --parallel).`
 	PullExample string = `
   From Sylabs cloud library
   $ singularity pull alpine.sif library://alpine:latest
@@ -672,9 +1084,25 @@ Enterprise Performance Computing (EPC)`
   The sign command allows a user to create a cryptographic signature on either a 
   single data object or a list of data objects within the same SIF group. By 
   default without parameters, the command searches for the primary partition and 
-  creates a verification block that is then added to the SIF container file.`
+  creates a verification block that is then added to the SIF container file.
+  The passphrase of the private key is read from --password-fd, --password-file
+  or the SINGULARITY_KEY_PASSPHRASE environment variable if set.
+
+  With --cert and --key, the data objects are signed with an X.509 certificate
+  and its RSA or ECDSA private key instead of an OpenPGP key. Intermediate CA
+  certificates following the signing certificate in the --cert PEM file are
+  stored with the signature.
+
+  With --all, each data object of the container is signed with its own
+  verification block. With --detached, verification blocks are written to a
+  detached signature file instead of the container, which is not modified and
+  may be read-only. Signatures are added to the detached signature file if it
+  exists, it can then be verified with verify --signature.`
 	SignExample string = `
-  $ singularity sign container.sif`
+  $ singularity sign container.sif
+  $ SINGULARITY_KEY_PASSPHRASE=secret singularity sign -k 0 container.sif
+  $ singularity sign --cert cert.pem --key key.pem container.sif
+  $ singularity sign --all --detached container.sig container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// verify
@@ -687,9 +1115,34 @@ Enterprise Performance Computing (EPC)`
   multiple data objects signed. By default the command searches for the primary 
   partition signature. If found, a list of all verification blocks applied on 
   the primary partition is gathered so that data integrity (hashing) and 
-  signature verification is done for all those blocks.`
+  signature verification is done for all those blocks. Signing keys are
+  searched in the local key store, then in the system global keyring, and
+  finally fetched from the key server.
+
+  The signing key must have been valid when the signature was made, and must
+  be neither expired nor revoked. Revocations are read from the local key
+  store and from the key server. Signatures failing these checks are rejected,
+  or accepted with a warning with --key-policy=warn.
+
+  X.509 signatures are verified with the CA certificates of the PEM file set
+  with --ca-bundle, their signing certificate must be valid and, if it has an
+  extended key usage, allow code signing.
+
+  With --all, the signatures of all signed data objects are verified. With
+  --json, a report of each signature block is printed as JSON: the data objects
+  covered, whether their hash matches, the signing key fingerprint and
+  identity, where the key was found (local, global or keyserver) and whether
+  the key is expired or revoked.
+
+  With --signature, the signatures of a detached signature file created by
+  sign --detached for the container are verified instead of the signatures
+  embedded in the container.`
 	VerifyExample string = `
-  $ singularity verify container.sif`
+  $ singularity verify container.sif
+  $ singularity verify --all --json container.sif
+  $ singularity verify --key-policy=warn container.sif
+  $ singularity verify --ca-bundle ca.pem container.sif
+  $ singularity verify --all --signature container.sig container.sif`
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Run-help
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
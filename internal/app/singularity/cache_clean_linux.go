@@ -10,16 +10,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/client/cache"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 func cleanLibraryCache() error {
-	sylog.Debugf("Removing: %v", cache.Library())
-
-	err := os.RemoveAll(cache.Library())
-	if err != nil {
+	// entries in use are kept
+	if err := cache.CleanType(cache.LibraryDir); err != nil {
 		return fmt.Errorf("unable to clean library cache: %v", err)
 	}
 
@@ -27,26 +26,30 @@ func cleanLibraryCache() error {
 }
 
 func cleanOciCache() error {
-	sylog.Debugf("Removing: %v", cache.OciTemp())
-
-	err := os.RemoveAll(cache.OciTemp())
-	if err != nil {
+	// entries in use are kept
+	if err := cache.CleanType(cache.OciTempDir); err != nil {
 		return fmt.Errorf("unable to clean oci-tmp cache: %v", err)
 	}
 
 	return nil
 }
 
-func cleanBlobCache() error {
-	sylog.Debugf("Removing: %v", cache.OciBlob())
+func cleanOciSandboxCache() error {
+	// sandboxes used by running containers are kept
+	if err := cache.CleanOciSandbox(); err != nil {
+		return fmt.Errorf("unable to clean oci-sandbox cache: %v", err)
+	}
 
-	err := os.RemoveAll(cache.OciBlob())
-	if err != nil {
+	return nil
+}
+
+func cleanBlobCache() error {
+	// entries in use are kept
+	if err := cache.CleanType(cache.OciBlobDir); err != nil {
 		return fmt.Errorf("unable to clean oci-blob cache: %v", err)
 	}
 
 	return nil
-
 }
 
 // CleanCache : clean a type of cache (cacheType string). will return a error if one occurs.
@@ -58,6 +61,9 @@ func CleanCache(cacheType string) error {
 	case "oci":
 		err := cleanOciCache()
 		return err
+	case "sandbox":
+		err := cleanOciSandboxCache()
+		return err
 	case "blob", "blobs":
 		err := cleanBlobCache()
 		return err
@@ -163,6 +169,7 @@ func CleanCacheName(cacheName string, libraryCache, ociCache bool) (bool, error)
 func CleanSingularityCache(cleanAll bool, cacheCleanTypes []string, cacheName string) error {
 	libraryClean := false
 	ociClean := false
+	sandboxClean := false
 	blobClean := false
 
 	for _, t := range cacheCleanTypes {
@@ -171,6 +178,8 @@ func CleanSingularityCache(cleanAll bool, cacheCleanTypes []string, cacheName st
 			libraryClean = true
 		case "oci":
 			ociClean = true
+		case "sandbox":
+			sandboxClean = true
 		case "blob", "blobs":
 			blobClean = true
 		case "all":
@@ -209,6 +218,11 @@ func CleanSingularityCache(cleanAll bool, cacheCleanTypes []string, cacheName st
 			return err
 		}
 	}
+	if sandboxClean {
+		if err := CleanCache("sandbox"); err != nil {
+			return err
+		}
+	}
 	if blobClean {
 		if err := CleanCache("blob"); err != nil {
 			return err
@@ -216,3 +230,22 @@ func CleanSingularityCache(cleanAll bool, cacheCleanTypes []string, cacheName st
 	}
 	return nil
 }
+
+// EvictSingularityCache : removes cache entries unused for more than days days, then least recently used
+// entries until the cache is not larger than size. Entries in use are kept.
+func EvictSingularityCache(days int, size string) error {
+	var maxSize int64
+	if size != "" {
+		var err error
+		maxSize, err = cache.ParseSize(size)
+		if err != nil {
+			return err
+		}
+		if maxSize == 0 {
+			// a zero size disables the size limit of cache.Evict
+			return cache.Clean()
+		}
+	}
+
+	return cache.Evict(maxSize, time.Duration(days)*24*time.Hour)
+}
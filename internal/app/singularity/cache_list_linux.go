@@ -85,6 +85,43 @@ func listOciCache() error {
 	return nil
 }
 
+func listOciSandboxCache() error {
+	// loop through oci-sandbox cache
+	entries, err := ioutil.ReadDir(cache.OciSandbox())
+	if err != nil {
+		return fmt.Errorf("unable to open oci-sandbox folder: %v", err)
+	}
+	for _, e := range entries {
+		sandboxes, err := ioutil.ReadDir(filepath.Join(cache.OciSandbox(), e.Name()))
+		if err != nil {
+			return fmt.Errorf("unable to look in oci-sandbox cache: %v", err)
+		}
+		for _, s := range sandboxes {
+			// skip references and incomplete sandboxes
+			if strings.HasPrefix(s.Name(), ".") {
+				continue
+			}
+			var size int64
+			err := filepath.Walk(filepath.Join(cache.OciSandbox(), e.Name(), s.Name()), func(path string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() {
+					size += info.Size()
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("unable to get size of oci-sandbox cache: %v", err)
+			}
+			printFileSize, err := findSize(size)
+			if err != nil {
+				// no need to describe the error, since it is already
+				sylog.Warningf("%v", err)
+			}
+			fmt.Printf("%-22s %-22s %-16s %s\n", s.Name(), s.ModTime().Format("2006-01-02 15:04:05"), printFileSize, "sandbox")
+		}
+	}
+	return nil
+}
+
 func listBlobCache(printList bool) error {
 	// loop through ociBlob cache
 	count := 0
@@ -132,10 +169,11 @@ func listBlobCache(printList bool) error {
 }
 
 // ListSingularityCache : list local singularity cache, typeNameList : is a string of what cache
-// to list (seprate each type with a comma; like this: library,oci,blob) allList : force list all cache.
+// to list (seprate each type with a comma; like this: library,oci,sandbox,blob) allList : force list all cache.
 func ListSingularityCache(cacheListTypes []string, listAll bool) error {
 	libraryList := false
 	ociList := false
+	sandboxList := false
 	blobList := false
 	listBlobSum := false
 
@@ -145,6 +183,8 @@ func ListSingularityCache(cacheListTypes []string, listAll bool) error {
 			libraryList = true
 		case "oci":
 			ociList = true
+		case "sandbox":
+			sandboxList = true
 		case "blob", "blobs":
 			blobList = true
 		case "blobSum":
@@ -169,6 +209,11 @@ func ListSingularityCache(cacheListTypes []string, listAll bool) error {
 			return err
 		}
 	}
+	if sandboxList || listAll {
+		if err := listOciSandboxCache(); err != nil {
+			return err
+		}
+	}
 	if blobList || listAll {
 		if err := listBlobCache(true); err != nil {
 			return err
@@ -0,0 +1,245 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+// NetworkConnectConfig instructs NetworkConnect and NetworkDisconnect
+// on which instance and network to use
+type NetworkConnectConfig struct {
+	Instance string
+	User     string
+	Network  string
+}
+
+// NetworkConnect attaches a running instance to an additional network
+func NetworkConnect(cniPath *network.CNIPath, c NetworkConnectConfig) error {
+	if os.Getuid() != 0 {
+		return fmt.Errorf("while connecting instance: only root user can connect instances to networks")
+	}
+
+	file, err := getNetworkInstance(c)
+	if err != nil {
+		return err
+	}
+
+	engineConfig, common, err := readInstanceConfig(file)
+	if err != nil {
+		return fmt.Errorf("while reading instance %s configuration: %s", file.Name, err)
+	}
+
+	interfaces := instanceInterfaces(engineConfig)
+	if _, ok := interfaces[c.Network]; ok {
+		return fmt.Errorf("while connecting instance: instance %s is already connected to network %s", file.Name, c.Network)
+	}
+
+	// pick the first interface name not used by instance networks
+	ifname := ""
+	for i := 0; ifname == ""; i++ {
+		ifname = fmt.Sprintf("eth%d", i)
+		for _, used := range interfaces {
+			if used == ifname {
+				ifname = ""
+				break
+			}
+		}
+	}
+
+	setup, err := newInstanceSetup(cniPath, file, c.Network, ifname)
+	if err != nil {
+		return err
+	}
+	if err := setup.AddNetworks(); err != nil {
+		return fmt.Errorf("while connecting instance to network %s: %s", c.Network, err)
+	}
+
+	connected := engineConfig.GetConnectedNetworks()
+	if connected == nil {
+		connected = make(map[string]string)
+	}
+	connected[c.Network] = ifname
+	engineConfig.SetConnectedNetworks(connected)
+
+	if err := writeInstanceConfig(file, common); err != nil {
+		if err := setup.DelNetworks(); err != nil {
+			sylog.Errorf("while disconnecting instance from network %s: %s", c.Network, err)
+		}
+		return fmt.Errorf("while updating instance %s configuration: %s", file.Name, err)
+	}
+
+	ip, err := setup.GetNetworkIP(c.Network, "4")
+	if err != nil {
+		sylog.Debugf("No IPv4 address: %s", err)
+		fmt.Printf("Connected instance %s to network %s (%s)\n", file.Name, c.Network, ifname)
+		return nil
+	}
+	fmt.Printf("Connected instance %s to network %s (%s, %s)\n", file.Name, c.Network, ifname, ip)
+	return nil
+}
+
+// NetworkDisconnect detaches a running instance from a network previously
+// connected with NetworkConnect
+func NetworkDisconnect(cniPath *network.CNIPath, c NetworkConnectConfig) error {
+	if os.Getuid() != 0 {
+		return fmt.Errorf("while disconnecting instance: only root user can disconnect instances from networks")
+	}
+
+	file, err := getNetworkInstance(c)
+	if err != nil {
+		return err
+	}
+
+	engineConfig, common, err := readInstanceConfig(file)
+	if err != nil {
+		return fmt.Errorf("while reading instance %s configuration: %s", file.Name, err)
+	}
+
+	connected := engineConfig.GetConnectedNetworks()
+	ifname, ok := connected[c.Network]
+	if !ok {
+		return fmt.Errorf("while disconnecting instance: network %s was not connected to instance %s with network connect", c.Network, file.Name)
+	}
+
+	setup, err := newInstanceSetup(cniPath, file, c.Network, ifname)
+	if err != nil {
+		return err
+	}
+	if err := setup.DelNetworks(); err != nil {
+		return fmt.Errorf("while disconnecting instance from network %s: %s", c.Network, err)
+	}
+
+	delete(connected, c.Network)
+	engineConfig.SetConnectedNetworks(connected)
+
+	if err := writeInstanceConfig(file, common); err != nil {
+		return fmt.Errorf("while updating instance %s configuration: %s", file.Name, err)
+	}
+
+	fmt.Printf("Disconnected instance %s from network %s\n", file.Name, c.Network)
+	return nil
+}
+
+// getNetworkInstance returns the file of a privileged instance
+// running in its own network namespace
+func getNetworkInstance(c NetworkConnectConfig) (*instance.File, error) {
+	if err := instance.CheckName(c.Instance); err != nil {
+		return nil, err
+	}
+	files, err := instance.List(c.User, c.Instance)
+	if err != nil {
+		return nil, fmt.Errorf("while retrieving instance %s: %s", c.Instance, err)
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("no instance found with name %s", c.Instance)
+	}
+	file := files[0]
+	if !file.PrivilegedPath() {
+		return nil, fmt.Errorf("instance %s is not a privileged instance", file.Name)
+	}
+
+	engineConfig, _, err := readInstanceConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("while reading instance %s configuration: %s", file.Name, err)
+	}
+	if !hasNetNamespace(engineConfig) {
+		return nil, fmt.Errorf("instance %s doesn't run in a network namespace, it must be started with --net", file.Name)
+	}
+
+	return file, nil
+}
+
+// newInstanceSetup returns a network setup for network name
+// with ifname interface in instance network namespace
+func newInstanceSetup(cniPath *network.CNIPath, file *instance.File, name string, ifname string) (*network.Setup, error) {
+	nspath, err := file.NamespacePath(specs.NetworkNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("while getting instance %s network namespace: %s", file.Name, err)
+	}
+
+	// use instance PID as container ID like the runtime does
+	// to allow the runtime to release connected networks
+	setup, err := network.NewSetup([]string{name}, strconv.Itoa(file.Pid), nspath, cniPath)
+	if err != nil {
+		return nil, fmt.Errorf("while loading network %s: %s", name, err)
+	}
+	if err := setup.SetInterfaceName(name, ifname); err != nil {
+		return nil, err
+	}
+	setup.SetEnvPath("/bin:/sbin:/usr/bin:/usr/sbin")
+
+	return setup, nil
+}
+
+// readInstanceConfig returns engine configuration stored in instance file
+func readInstanceConfig(file *instance.File) (*singularityConfig.EngineConfig, *config.Common, error) {
+	engineConfig := singularityConfig.NewConfig()
+	common := &config.Common{
+		EngineConfig: engineConfig,
+	}
+	if err := json.Unmarshal(file.Config, common); err != nil {
+		return nil, nil, err
+	}
+	return engineConfig, common, nil
+}
+
+// writeInstanceConfig stores engine configuration in instance file
+func writeInstanceConfig(file *instance.File, common *config.Common) error {
+	var err error
+
+	file.Config, err = json.Marshal(common)
+	if err != nil {
+		return err
+	}
+	return file.Update()
+}
+
+// hasNetNamespace returns if instance runs in its own network namespace
+func hasNetNamespace(engineConfig *singularityConfig.EngineConfig) bool {
+	if engineConfig.OciConfig.Linux == nil {
+		return false
+	}
+	for _, ns := range engineConfig.OciConfig.Linux.Namespaces {
+		if ns.Type == specs.NetworkNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceInterfaces returns networks used by an instance
+// with their interface name in the container
+func instanceInterfaces(engineConfig *singularityConfig.EngineConfig) map[string]string {
+	interfaces := make(map[string]string)
+
+	if !hasNetNamespace(engineConfig) {
+		return interfaces
+	}
+
+	// networks configured at startup are named in order
+	if n := engineConfig.GetNetwork(); n != "" && n != "none" {
+		for i, name := range strings.Split(n, ",") {
+			interfaces[name] = fmt.Sprintf("eth%d", i)
+		}
+	}
+	for name, ifname := range engineConfig.GetConnectedNetworks() {
+		interfaces[name] = ifname
+	}
+
+	return interfaces
+}
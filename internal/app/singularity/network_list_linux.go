@@ -0,0 +1,64 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+const networkListLine = "%s\t%s\t%s\n"
+
+// NetworkList prints networks available in CNI configuration path
+func NetworkList(cniPath *network.CNIPath) error {
+	networks, err := network.GetAllNetworkConfigList(cniPath)
+	if err != nil {
+		return fmt.Errorf("while listing networks: %s", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, networkListLine, "NAME", "CNI VERSION", "PLUGINS")
+	for _, n := range networks {
+		plugins := make([]string, len(n.Plugins))
+		for i, p := range n.Plugins {
+			plugins[i] = p.Network.Type
+		}
+		fmt.Fprintf(tw, networkListLine, n.Name, n.CNIVersion, strings.Join(plugins, ","))
+	}
+	tw.Flush()
+	return nil
+}
+
+// NetworkInspect prints configuration of networks
+func NetworkInspect(cniPath *network.CNIPath, names []string) error {
+	for _, name := range names {
+		conf, err := findNetwork(cniPath, name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(string(conf.Bytes)))
+	}
+	return nil
+}
+
+// findNetwork returns configuration list of network name
+func findNetwork(cniPath *network.CNIPath, name string) (*libcni.NetworkConfigList, error) {
+	networks, err := network.GetAllNetworkConfigList(cniPath)
+	if err != nil {
+		return nil, fmt.Errorf("while listing networks: %s", err)
+	}
+	for _, n := range networks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("network %s not found in %s", name, cniPath.Conf)
+}
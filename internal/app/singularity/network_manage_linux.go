@@ -0,0 +1,122 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+// NetworkCreate generates a network configuration list file
+// in CNI configuration path
+func NetworkCreate(cniPath *network.CNIPath, c network.ConfListConfig) error {
+	if os.Getuid() != 0 {
+		return fmt.Errorf("while creating network: only root user can create networks")
+	}
+
+	if path, err := networkFile(cniPath, c.Name); err != nil {
+		return fmt.Errorf("while looking for existing networks: %s", err)
+	} else if path != "" {
+		return fmt.Errorf("while creating network: network %s already exists in %s", c.Name, path)
+	}
+
+	data, err := network.NewConfList(c)
+	if err != nil {
+		return fmt.Errorf("while generating network configuration: %s", err)
+	}
+
+	if err := os.MkdirAll(cniPath.Conf, 0755); err != nil {
+		return fmt.Errorf("while creating %s: %s", cniPath.Conf, err)
+	}
+
+	path := filepath.Join(cniPath.Conf, c.Name+".conflist")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating network configuration file: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("while writing network configuration file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// NetworkRemove deletes the configuration file of network name,
+// the network must not be used by running instances
+func NetworkRemove(cniPath *network.CNIPath, name string) error {
+	if os.Getuid() != 0 {
+		return fmt.Errorf("while removing network: only root user can remove networks")
+	}
+
+	path, err := networkFile(cniPath, name)
+	if err != nil {
+		return fmt.Errorf("while looking for network %s: %s", name, err)
+	} else if path == "" {
+		return fmt.Errorf("while removing network: network %s not found in %s", name, cniPath.Conf)
+	}
+
+	// only privileged instances can use networks
+	files, err := instance.ListPrivileged("*")
+	if err != nil {
+		return fmt.Errorf("while listing instances: %s", err)
+	}
+	for _, file := range files {
+		engineConfig, _, err := readInstanceConfig(file)
+		if err != nil {
+			return fmt.Errorf("while reading instance %s configuration: %s", file.Name, err)
+		}
+		if _, ok := instanceInterfaces(engineConfig)[name]; ok {
+			return fmt.Errorf("while removing network: network %s is used by instance %s of user %s", name, file.Name, file.User)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("while removing network configuration file: %s", err)
+	}
+
+	return nil
+}
+
+// networkFile returns the path of the configuration file of network
+// name or an empty path if no file defines this network
+func networkFile(cniPath *network.CNIPath, name string) (string, error) {
+	files, err := libcni.ConfFiles(cniPath.Conf, []string{".conf", ".json", ".conflist"})
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		var networkName string
+
+		if strings.HasSuffix(file, ".conflist") {
+			conf, err := libcni.ConfListFromFile(file)
+			if err != nil {
+				return "", err
+			}
+			networkName = conf.Name
+		} else {
+			conf, err := libcni.ConfFromFile(file)
+			if err != nil {
+				return "", err
+			}
+			networkName = conf.Network.Name
+		}
+
+		if networkName == name {
+			return file, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+const overlayInfoLine = "%s:\t%s\n"
+
+// OverlayInfo prints size and usage of an ext3 overlay image
+// or of the ext3 overlay partition of a SIF image
+func OverlayInfo(c OverlayConfig) error {
+	img, err := image.Init(c.Path, false)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", c.Path, err)
+	}
+	defer img.File.Close()
+
+	var overlay *image.Section
+	kind := ""
+
+	switch img.Type {
+	case image.EXT3:
+		overlay = &img.Partitions[0]
+		kind = "ext3 image"
+	case image.SIF:
+		for i, p := range img.Partitions[1:] {
+			if p.Type == image.EXT3 {
+				overlay = &img.Partitions[i+1]
+				kind = "SIF ext3 overlay partition"
+				break
+			}
+		}
+		if overlay == nil {
+			return fmt.Errorf("%s doesn't contain an overlay partition", c.Path)
+		}
+	default:
+		return fmt.Errorf("%s is not an ext3 overlay image or a SIF image", c.Path)
+	}
+
+	size, free, err := image.GetExt3Usage(img.File, overlay.Offset)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, overlayInfoLine, "Path", img.Path)
+	fmt.Fprintf(tw, overlayInfoLine, "Type", kind)
+	fmt.Fprintf(tw, overlayInfoLine, "Size", units.BytesSize(float64(overlay.Size)))
+	fmt.Fprintf(tw, overlayInfoLine, "Filesystem size", units.BytesSize(float64(size)))
+	fmt.Fprintf(tw, overlayInfoLine, "Used", units.BytesSize(float64(size-free)))
+	fmt.Fprintf(tw, overlayInfoLine, "Free", units.BytesSize(float64(free)))
+	tw.Flush()
+
+	return nil
+}
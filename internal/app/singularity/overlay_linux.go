@@ -0,0 +1,253 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+// OverlayConfig instructs overlay commands on which image to
+// use and how to create or resize the overlay
+type OverlayConfig struct {
+	// Path of the overlay image or of the SIF image
+	Path string
+	// Size of the overlay in MiB
+	Size int64
+	// Sparse creates a sparse overlay image
+	Sparse bool
+}
+
+// sbinPaths are looked up for e2fsprogs commands which are often
+// not in unprivileged users PATH
+var sbinPaths = []string{"/sbin", "/usr/sbin", "/usr/local/sbin"}
+
+// findCommand returns the path of command name
+func findCommand(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	for _, dir := range sbinPaths {
+		path := filepath.Join(dir, name)
+		if _, err := exec.LookPath(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found, please install e2fsprogs", name)
+}
+
+// runCommand executes command name with args and reports
+// command output on failure
+func runCommand(name string, args ...string) error {
+	path, err := findCommand(name)
+	if err != nil {
+		return err
+	}
+	sylog.Debugf("Running %s %v", path, args)
+	out, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %s", name, err, out)
+	}
+	return nil
+}
+
+// isSIF returns if the file at path is a SIF image
+func isSIF(path string) (bool, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return false, err
+	}
+	defer img.File.Close()
+
+	return img.Type == image.SIF, nil
+}
+
+// createExt3 creates an ext3 overlay image file of size MiB, the
+// overlay upper and work directories are owned by the caller
+func createExt3(path string, size int64, sparse bool) error {
+	if size <= 0 {
+		return fmt.Errorf("overlay size must be greater than zero")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating overlay image: %s", err)
+	}
+
+	length := size * 1024 * 1024
+	if sparse {
+		err = f.Truncate(length)
+	} else {
+		err = syscall.Fallocate(int(f.Fd()), 0, 0, length)
+	}
+	f.Close()
+
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("while allocating %d MiB for overlay image: %s", size, err)
+	}
+
+	// populate the image with upper and work directories
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"upper", "work"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+
+	owner := fmt.Sprintf("root_owner=%d:%d", os.Getuid(), os.Getgid())
+	if err := runCommand("mkfs.ext3", "-q", "-F", "-E", owner, "-d", dir, path); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("while creating ext3 filesystem: %s", err)
+	}
+
+	return nil
+}
+
+// resizeExt3 resizes the ext3 overlay image file at path to size MiB
+func resizeExt3(path string, size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("overlay size must be greater than zero")
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	length := size * 1024 * 1024
+
+	if err := runCommand("e2fsck", "-f", "-p", path); err != nil {
+		return fmt.Errorf("while checking overlay filesystem: %s", err)
+	}
+
+	if length > fi.Size() {
+		if err := os.Truncate(path, length); err != nil {
+			return err
+		}
+		if err := runCommand("resize2fs", path); err != nil {
+			return fmt.Errorf("while resizing overlay filesystem: %s", err)
+		}
+	} else if length < fi.Size() {
+		if err := runCommand("resize2fs", path, strconv.FormatInt(size, 10)+"M"); err != nil {
+			return fmt.Errorf("while resizing overlay filesystem: %s", err)
+		}
+		if err := os.Truncate(path, length); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sifOverlays returns descriptors of ext3 overlay partitions
+// in the SIF image primary partition group
+func sifOverlays(fimg *sif.FileImage) ([]*sif.Descriptor, error) {
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := make([]*sif.Descriptor, 0)
+	for i, desc := range fimg.DescrArr {
+		if !desc.Used || desc.Datatype != sif.DataPartition || desc.Groupid != part.Groupid {
+			continue
+		}
+		ptype, err := desc.GetPartType()
+		if err != nil || ptype != sif.PartOverlay {
+			continue
+		}
+		if fstype, err := desc.GetFsType(); err == nil && fstype == sif.FsExt3 {
+			overlays = append(overlays, &fimg.DescrArr[i])
+		}
+	}
+	return overlays, nil
+}
+
+// addSIFOverlay appends the ext3 image file at path as an
+// overlay partition of the SIF image primary partition group
+func addSIFOverlay(fimg *sif.FileImage, path string) error {
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return err
+	}
+	arch, err := part.GetArch()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  part.Groupid,
+		Link:     sif.DescrUnusedLink,
+		Fname:    path,
+		Fp:       f,
+		Size:     fi.Size(),
+	}
+	if err := input.SetPartExtra(sif.FsExt3, sif.PartOverlay, string(arch[:sif.HdrArchLen-1])); err != nil {
+		return err
+	}
+
+	return fimg.AddObject(input)
+}
+
+// extractSIFOverlay copies the overlay partition desc to the file at path
+func extractSIFOverlay(fimg *sif.FileImage, desc *sif.Descriptor, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := io.NewSectionReader(fimg.Fp, desc.Fileoff, desc.Filelen)
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("while extracting overlay partition: %s", err)
+	}
+	return nil
+}
+
+// deleteSIFOverlay deletes the overlay partition desc from the SIF image
+func deleteSIFOverlay(fimg *sif.FileImage, desc *sif.Descriptor, flags int) error {
+	if err := fimg.DeleteObject(desc.ID, flags); err != nil {
+		return err
+	}
+	// DeleteObject only resets the descriptor on disk, reset the
+	// in-memory copy too so it isn't written back by AddObject
+	*desc = sif.Descriptor{}
+	return nil
+}
+
+// isLastObject returns if the data object desc is at the end of the SIF file
+func isLastObject(fimg *sif.FileImage, desc *sif.Descriptor) bool {
+	return fimg.Filesize == desc.Fileoff+desc.Filelen
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+// OverlayCreate creates an ext3 overlay image, or if the path
+// points to an existing SIF image, appends an ext3 overlay
+// partition to it
+func OverlayCreate(c OverlayConfig) error {
+	if _, err := os.Stat(c.Path); os.IsNotExist(err) {
+		return createExt3(c.Path, c.Size, c.Sparse)
+	}
+
+	isSif, err := isSIF(c.Path)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", c.Path, err)
+	}
+	if !isSif {
+		return fmt.Errorf("%s already exists and is not a SIF image", c.Path)
+	}
+	if c.Sparse {
+		sylog.Warningf("SIF overlay partitions can't be sparse, ignoring --sparse")
+	}
+
+	fimg, err := sif.LoadContainer(c.Path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF image %s: %s", c.Path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	overlays, err := sifOverlays(&fimg)
+	if err != nil {
+		return fmt.Errorf("while looking for overlay partitions: %s", err)
+	}
+	if len(overlays) > 0 {
+		return fmt.Errorf("%s already contains an overlay partition", c.Path)
+	}
+
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	overlay := filepath.Join(dir, "overlay.img")
+	if err := createExt3(overlay, c.Size, false); err != nil {
+		return err
+	}
+
+	if err := addSIFOverlay(&fimg, overlay); err != nil {
+		return fmt.Errorf("while adding overlay partition to %s: %s", c.Path, err)
+	}
+
+	return nil
+}
+
+// OverlayResize resizes an ext3 overlay image or the ext3
+// overlay partition of a SIF image
+func OverlayResize(c OverlayConfig) error {
+	isSif, err := isSIF(c.Path)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", c.Path, err)
+	}
+	if !isSif {
+		if err := checkExt3(c.Path); err != nil {
+			return err
+		}
+		return resizeExt3(c.Path, c.Size)
+	}
+
+	fimg, err := sif.LoadContainer(c.Path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF image %s: %s", c.Path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	desc, err := sifOverlay(&fimg, c.Path)
+	if err != nil {
+		return err
+	}
+	if !isLastObject(&fimg, desc) {
+		return fmt.Errorf("overlay partition of %s is not the last data object and can't be resized", c.Path)
+	}
+
+	// partition is extracted, resized and put back at the end of the image
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		return err
+	}
+	// the resized overlay is kept if the image lost its overlay partition
+	keep := false
+	defer func() {
+		if !keep {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	overlay := filepath.Join(dir, "overlay.img")
+	if err := extractSIFOverlay(&fimg, desc, overlay); err != nil {
+		return err
+	}
+	if err := resizeExt3(overlay, c.Size); err != nil {
+		return err
+	}
+	if err := deleteSIFOverlay(&fimg, desc, sif.DelCompact); err != nil {
+		keep = true
+		return fmt.Errorf("while removing overlay partition from %s, overlay data are kept in %s: %s", c.Path, overlay, err)
+	}
+	if err := addSIFOverlay(&fimg, overlay); err != nil {
+		keep = true
+		return fmt.Errorf("while adding resized overlay partition to %s, overlay data are kept in %s: %s", c.Path, overlay, err)
+	}
+
+	return nil
+}
+
+// OverlayRemove deletes an ext3 overlay image or removes the
+// ext3 overlay partition of a SIF image
+func OverlayRemove(c OverlayConfig) error {
+	isSif, err := isSIF(c.Path)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", c.Path, err)
+	}
+	if !isSif {
+		if err := checkExt3(c.Path); err != nil {
+			return err
+		}
+		return os.Remove(c.Path)
+	}
+
+	fimg, err := sif.LoadContainer(c.Path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF image %s: %s", c.Path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	desc, err := sifOverlay(&fimg, c.Path)
+	if err != nil {
+		return err
+	}
+
+	// compaction only applies to the last data object
+	flags := sif.DelZero
+	if isLastObject(&fimg, desc) {
+		flags = sif.DelCompact
+	}
+	if err := deleteSIFOverlay(&fimg, desc, flags); err != nil {
+		return fmt.Errorf("while removing overlay partition from %s: %s", c.Path, err)
+	}
+
+	return nil
+}
+
+// checkExt3 returns an error if path is not an ext3 image
+func checkExt3(path string) error {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", path, err)
+	}
+	img.File.Close()
+
+	if img.Type != image.EXT3 {
+		return fmt.Errorf("%s is not an ext3 overlay image", path)
+	}
+	return nil
+}
+
+// sifOverlay returns the ext3 overlay partition of a SIF image
+func sifOverlay(fimg *sif.FileImage, path string) (*sif.Descriptor, error) {
+	overlays, err := sifOverlays(fimg)
+	if err != nil {
+		return nil, fmt.Errorf("while looking for overlay partitions: %s", err)
+	}
+	if len(overlays) == 0 {
+		return nil, fmt.Errorf("%s doesn't contain an overlay partition", path)
+	}
+	return overlays[0], nil
+}
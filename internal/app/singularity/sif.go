@@ -0,0 +1,482 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/signing"
+)
+
+// SifDescriptor is the JSON representation of a SIF data object descriptor
+type SifDescriptor struct {
+	Slot      int       `json:"slot"`
+	ID        uint32    `json:"id"`
+	Datatype  string    `json:"datatype"`
+	Group     uint32    `json:"group,omitempty"`
+	Link      uint32    `json:"link,omitempty"`
+	LinkGroup bool      `json:"linkGroup,omitempty"`
+	Offset    int64     `json:"offset"`
+	Size      int64     `json:"size"`
+	Ctime     time.Time `json:"ctime"`
+	Mtime     time.Time `json:"mtime"`
+	UID       int64     `json:"uid"`
+	GID       int64     `json:"gid"`
+	Name      string    `json:"name"`
+	Fstype    string    `json:"fstype,omitempty"`
+	Parttype  string    `json:"parttype,omitempty"`
+	Arch      string    `json:"arch,omitempty"`
+	Hashtype  string    `json:"hashtype,omitempty"`
+	Entity    string    `json:"entity,omitempty"`
+}
+
+// SifAddConfig describes the data object added by SifAdd
+type SifAddConfig struct {
+	// Datatype is the data object type (see SifDatatypes)
+	Datatype string
+	// Group is the group number of the data object, 0 for none
+	Group uint32
+	// Link is the ID or the group number the data object is linked to
+	Link uint32
+	// LinkGroup indicates that Link is a group number
+	LinkGroup bool
+	// Alignment of the data object in the SIF file
+	Alignment int
+	// Fstype, Parttype and Arch describe a partition data object
+	Fstype   string
+	Parttype string
+	Arch     string
+	// Hashtype and Entity describe a signature data object
+	Hashtype string
+	Entity   string
+}
+
+// SifDatatypes maps data object type names to SIF data types
+var SifDatatypes = map[string]sif.Datatype{
+	"deffile":       sif.DataDeffile,
+	"envvar":        sif.DataEnvVar,
+	"labels":        sif.DataLabels,
+	"partition":     sif.DataPartition,
+	"signature":     sif.DataSignature,
+	"generic-json":  sif.DataGenericJSON,
+	"cryptomessage": image.SIFDataCryptoMessage,
+}
+
+// SifFstypes maps filesystem names to SIF partition filesystem types
+var SifFstypes = map[string]sif.Fstype{
+	"squashfs":          sif.FsSquash,
+	"ext3":              sif.FsExt3,
+	"immuobj":           sif.FsImmuObj,
+	"raw":               sif.FsRaw,
+	"encryptedsquashfs": image.SIFFsEncryptedSquashfs,
+}
+
+// SifParttypes maps partition type names to SIF partition types
+var SifParttypes = map[string]sif.Parttype{
+	"system":  sif.PartSystem,
+	"primsys": sif.PartPrimSys,
+	"data":    sif.PartData,
+	"overlay": sif.PartOverlay,
+}
+
+// SifHashtypes maps hash names to SIF signature hash types
+var SifHashtypes = map[string]sif.Hashtype{
+	"sha256":  sif.HashSHA256,
+	"sha384":  sif.HashSHA384,
+	"sha512":  sif.HashSHA512,
+	"blake2s": sif.HashBLAKE2S,
+	"blake2b": sif.HashBLAKE2B,
+	// X.509 signatures made by the sign command
+	"x509-sha384": signing.HashX509SHA384,
+}
+
+// SifList prints the list of data object descriptors of a SIF file
+func SifList(path string, jsonOutput bool) error {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if jsonOutput {
+		return printJSON(SifDescriptors(&fimg))
+	}
+	fmt.Printf("Container id: %s\n", fimg.Header.ID)
+	fmt.Printf("Created on:   %s\n", time.Unix(fimg.Header.Ctime, 0))
+	fmt.Printf("Modified on:  %s\n", time.Unix(fimg.Header.Mtime, 0))
+	fmt.Printf("----------------------------------------------------\n\n")
+	fmt.Printf("Descriptor list:\n")
+	fmt.Print(fimg.FmtDescrList())
+	return nil
+}
+
+// SifInfo prints detailed information about data object id of a SIF file
+func SifInfo(path string, id uint32, jsonOutput bool) error {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if _, _, err := fimg.GetFromDescrID(id); err != nil {
+		return fmt.Errorf("while looking for data object %d: %s", id, err)
+	}
+
+	if jsonOutput {
+		for _, d := range SifDescriptors(&fimg) {
+			if d.ID == id {
+				return printJSON(d)
+			}
+		}
+	}
+	fmt.Print(fimg.FmtDescrInfo(id))
+	return nil
+}
+
+// SifDump writes the content of data object id of a SIF file to w
+func SifDump(path string, id uint32, w io.Writer) error {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for data object %d: %s", id, err)
+	}
+
+	r := io.NewSectionReader(fimg.Fp, descr.Fileoff, descr.Filelen)
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("while dumping data object %d: %s", id, err)
+	}
+	return nil
+}
+
+// SifHeader prints the global header of a SIF file
+func SifHeader(path string) error {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	fmt.Print(fimg.FmtHeader())
+	return nil
+}
+
+// SifAdd adds the content of file object as a new data object of a SIF file
+func SifAdd(path string, object string, c SifAddConfig) error {
+	input, err := sifDescriptorInput(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(object)
+	if err != nil {
+		return fmt.Errorf("while opening data object file: %s", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("while getting data object file size: %s", err)
+	}
+	input.Fname = object
+	input.Fp = f
+	input.Size = fi.Size()
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("while adding data object: %s", err)
+	}
+	return nil
+}
+
+// SifDel deletes data object id from a SIF file, space used by
+// the data object is reclaimed if it's the last one of the file
+func SifDel(path string, id uint32) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for data object %d: %s", id, err)
+	}
+
+	flags := sif.DelZero
+	if fimg.Filesize == descr.Fileoff+descr.Filelen {
+		flags = sif.DelCompact
+	}
+	if err := fimg.DeleteObject(id, flags); err != nil {
+		return fmt.Errorf("while deleting data object %d: %s", id, err)
+	}
+	return nil
+}
+
+// SifSetPrim sets partition id as the primary system partition
+// of a SIF file, the previous primary partition becomes a system
+// partition
+func SifSetPrim(path string, id uint32) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	descr, index, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for data object %d: %s", id, err)
+	}
+	if descr.Datatype != sif.DataPartition {
+		return fmt.Errorf("data object %d is not a partition", id)
+	}
+	ptype, err := descr.GetPartType()
+	if err != nil {
+		return err
+	}
+	switch ptype {
+	case sif.PartPrimSys:
+		return nil
+	case sif.PartSystem:
+	default:
+		return fmt.Errorf("data object %d is not a system partition", id)
+	}
+
+	if prim, primIndex, err := fimg.GetPartPrimSys(); err == nil {
+		if err := setPartType(&fimg, prim, primIndex, sif.PartSystem); err != nil {
+			return err
+		}
+	}
+	if err := setPartType(&fimg, descr, index, sif.PartPrimSys); err != nil {
+		return err
+	}
+
+	arch, err := descr.GetArch()
+	if err != nil {
+		return err
+	}
+	copy(fimg.Header.Arch[:], arch[:])
+	fimg.Header.Mtime = time.Now().Unix()
+	fimg.PrimPartID = id
+
+	if _, err := fimg.Fp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(fimg.Fp, binary.LittleEndian, fimg.Header); err != nil {
+		return fmt.Errorf("while writing SIF header: %s", err)
+	}
+	return fimg.Fp.Sync()
+}
+
+// setPartType changes the partition type of descriptor descr
+// located at index in the descriptor table and writes it
+func setPartType(fimg *sif.FileImage, descr *sif.Descriptor, index int, ptype sif.Parttype) error {
+	fstype, err := descr.GetFsType()
+	if err != nil {
+		return err
+	}
+	arch, err := descr.GetArch()
+	if err != nil {
+		return err
+	}
+
+	input := sif.DescriptorInput{}
+	if err := input.SetPartExtra(fstype, ptype, string(arch[:sif.HdrArchLen-1])); err != nil {
+		return err
+	}
+	descr.SetExtra(input.Extra.Bytes())
+	descr.Mtime = time.Now().Unix()
+
+	offset := fimg.Header.Descroff + int64(index)*int64(binary.Size(*descr))
+	if _, err := fimg.Fp.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(fimg.Fp, binary.LittleEndian, descr); err != nil {
+		return fmt.Errorf("while writing descriptor %d: %s", descr.ID, err)
+	}
+	return nil
+}
+
+// sifDescriptorInput returns descriptor input corresponding
+// to the data object configuration
+func sifDescriptorInput(c SifAddConfig) (sif.DescriptorInput, error) {
+	input := sif.DescriptorInput{
+		Groupid:   sif.DescrUnusedGroup,
+		Link:      sif.DescrUnusedLink,
+		Alignment: c.Alignment,
+	}
+
+	datatype, ok := SifDatatypes[c.Datatype]
+	if !ok {
+		return input, fmt.Errorf("unknown data object type %q", c.Datatype)
+	}
+	input.Datatype = datatype
+
+	if c.Group != 0 {
+		input.Groupid = sif.DescrGroupMask | c.Group
+	}
+	if c.Link != 0 {
+		input.Link = c.Link
+		if c.LinkGroup {
+			input.Link |= sif.DescrGroupMask
+		}
+	}
+
+	switch datatype {
+	case sif.DataPartition:
+		fstype, ok := SifFstypes[c.Fstype]
+		if !ok {
+			return input, fmt.Errorf("unknown partition filesystem type %q", c.Fstype)
+		}
+		parttype, ok := SifParttypes[c.Parttype]
+		if !ok {
+			return input, fmt.Errorf("unknown partition type %q", c.Parttype)
+		}
+		goarch := c.Arch
+		if goarch == "" {
+			goarch = runtime.GOARCH
+		}
+		arch := sif.GetSIFArch(goarch)
+		if arch == sif.HdrArchUnknown {
+			return input, fmt.Errorf("unknown partition architecture %q", goarch)
+		}
+		if err := input.SetPartExtra(fstype, parttype, arch); err != nil {
+			return input, err
+		}
+	case sif.DataSignature:
+		hashtype, ok := SifHashtypes[c.Hashtype]
+		if !ok {
+			return input, fmt.Errorf("unknown signature hash type %q", c.Hashtype)
+		}
+		if c.Entity == "" {
+			return input, fmt.Errorf("signature entity fingerprint is required")
+		}
+		if err := input.SetSignExtra(hashtype, c.Entity); err != nil {
+			return input, fmt.Errorf("while setting signature entity: %s", err)
+		}
+	}
+
+	return input, nil
+}
+
+// SifDescriptors returns the JSON representation of used data
+// object descriptors of a SIF file
+func SifDescriptors(fimg *sif.FileImage) []SifDescriptor {
+	descrs := make([]SifDescriptor, 0)
+
+	for i, v := range fimg.DescrArr {
+		if !v.Used {
+			continue
+		}
+
+		d := SifDescriptor{
+			Slot:     i,
+			ID:       v.ID,
+			Datatype: datatypeName(v.Datatype),
+			Offset:   v.Fileoff,
+			Size:     v.Filelen,
+			Ctime:    time.Unix(v.Ctime, 0),
+			Mtime:    time.Unix(v.Mtime, 0),
+			UID:      v.UID,
+			GID:      v.Gid,
+			Name:     v.GetName(),
+		}
+		if v.Groupid != sif.DescrUnusedGroup {
+			d.Group = v.Groupid &^ sif.DescrGroupMask
+		}
+		if v.Link != sif.DescrUnusedLink {
+			d.Link = v.Link &^ sif.DescrGroupMask
+			d.LinkGroup = v.Link&sif.DescrGroupMask == sif.DescrGroupMask
+		}
+
+		switch v.Datatype {
+		case sif.DataPartition:
+			if f, err := v.GetFsType(); err == nil {
+				d.Fstype = fstypeName(f)
+			}
+			if p, err := v.GetPartType(); err == nil {
+				d.Parttype = parttypeName(p)
+			}
+			if a, err := v.GetArch(); err == nil {
+				d.Arch = sif.GetGoArch(string(a[:sif.HdrArchLen-1]))
+			}
+		case sif.DataSignature:
+			if h, err := v.GetHashType(); err == nil {
+				d.Hashtype = hashtypeName(h)
+			}
+			if e, err := v.GetEntityString(); err == nil {
+				d.Entity = e
+			}
+		}
+
+		descrs = append(descrs, d)
+	}
+
+	return descrs
+}
+
+func datatypeName(t sif.Datatype) string {
+	for k, v := range SifDatatypes {
+		if v == t {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+func fstypeName(t sif.Fstype) string {
+	for k, v := range SifFstypes {
+		if v == t {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+func parttypeName(t sif.Parttype) string {
+	for k, v := range SifParttypes {
+		if v == t {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+func hashtypeName(t sif.Hashtype) string {
+	for k, v := range SifHashtypes {
+		if v == t {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+// printJSON prints v as indented JSON on standard output
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("while encoding JSON output: %s", err)
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+func createTestSIF(t *testing.T, dir string) string {
+	part := filepath.Join(dir, "part.img")
+	if err := ioutil.WriteFile(part, bytes.Repeat([]byte{'p'}, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    part,
+		Fp:       f,
+		Size:     4096,
+	}
+	if err := input.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "test.sif")
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{input},
+	}
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadTestDescriptors(t *testing.T, path string) []SifDescriptor {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fimg.UnloadContainer()
+	return SifDescriptors(&fimg)
+}
+
+func TestSif(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sif-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := createTestSIF(t, dir)
+
+	deffile := filepath.Join(dir, "container.def")
+	if err := ioutil.WriteFile(deffile, []byte("Bootstrap: scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	system := filepath.Join(dir, "system.img")
+	if err := ioutil.WriteFile(system, bytes.Repeat([]byte{'s'}, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	badConfigs := []SifAddConfig{
+		{Datatype: "unknown"},
+		{Datatype: "partition", Fstype: "unknown", Parttype: "system"},
+		{Datatype: "partition", Fstype: "squashfs", Parttype: "unknown"},
+		{Datatype: "partition", Fstype: "squashfs", Parttype: "system", Arch: "unknown"},
+		{Datatype: "signature", Hashtype: "sha256"},
+		{Datatype: "partition", Fstype: "squashfs", Parttype: "primsys"},
+	}
+	for _, c := range badConfigs {
+		if err := SifAdd(path, system, c); err == nil {
+			t.Errorf("unexpected success while adding data object with %+v", c)
+		}
+	}
+
+	if err := SifAdd(path, deffile, SifAddConfig{Datatype: "deffile", Group: 1}); err != nil {
+		t.Fatalf("unexpected error while adding definition file: %s", err)
+	}
+	if err := SifAdd(path, system, SifAddConfig{Datatype: "partition", Fstype: "squashfs", Parttype: "system"}); err != nil {
+		t.Fatalf("unexpected error while adding system partition: %s", err)
+	}
+
+	descrs := loadTestDescriptors(t, path)
+	if len(descrs) != 3 {
+		t.Fatalf("unexpected number of descriptors: %d", len(descrs))
+	}
+	if descrs[0].Parttype != "primsys" || descrs[0].Fstype != "squashfs" || descrs[0].Arch != runtime.GOARCH {
+		t.Errorf("unexpected first descriptor: %+v", descrs[0])
+	}
+	if descrs[1].Datatype != "deffile" || descrs[1].Group != 1 || descrs[1].Name != "container.def" {
+		t.Errorf("unexpected second descriptor: %+v", descrs[1])
+	}
+
+	var b bytes.Buffer
+	if err := SifDump(path, descrs[1].ID, &b); err != nil {
+		t.Errorf("unexpected error while dumping data object: %s", err)
+	} else if b.String() != "Bootstrap: scratch\n" {
+		t.Errorf("unexpected dump content: %q", b.String())
+	}
+
+	if err := SifSetPrim(path, descrs[1].ID); err == nil {
+		t.Errorf("unexpected success while setting definition file as primary partition")
+	}
+	if err := SifSetPrim(path, descrs[2].ID); err != nil {
+		t.Fatalf("unexpected error while setting primary partition: %s", err)
+	}
+	descrs = loadTestDescriptors(t, path)
+	if descrs[0].Parttype != "system" || descrs[2].Parttype != "primsys" {
+		t.Errorf("unexpected partition types after setprim: %s, %s", descrs[0].Parttype, descrs[2].Parttype)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := fi.Size()
+
+	if err := SifDel(path, descrs[2].ID); err != nil {
+		t.Fatalf("unexpected error while deleting data object: %s", err)
+	}
+	if err := SifDel(path, descrs[2].ID); err == nil {
+		t.Errorf("unexpected success while deleting deleted data object")
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Size() >= size {
+		t.Errorf("SIF file not truncated after deletion of last data object")
+	}
+	if descrs = loadTestDescriptors(t, path); len(descrs) != 2 {
+		t.Errorf("unexpected number of descriptors after deletion: %d", len(descrs))
+	}
+}
@@ -25,13 +25,15 @@ import (
 	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/util/crypt"
 )
 
 // SIFAssembler doesnt store anything
 type SIFAssembler struct {
 }
 
-func createSIF(path string, definition, ociConf []byte, squashfile string) (err error) {
+func createSIF(path string, definition, ociConf []byte, squashfile string, fstype sif.Fstype, wrappedKey []byte) (err error) {
 	// general info for the new SIF file creation
 	cinfo := sif.CreateInfo{
 		Pathname:   path,
@@ -85,7 +87,7 @@ func createSIF(path string, definition, ociConf []byte, squashfile string) (err
 	}
 	parinput.Size = fi.Size()
 
-	err = parinput.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH))
+	err = parinput.SetPartExtra(fstype, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH))
 	if err != nil {
 		return
 	}
@@ -93,6 +95,20 @@ func createSIF(path string, definition, ociConf []byte, squashfile string) (err
 	// add this descriptor input element to the list
 	cinfo.InputDescr = append(cinfo.InputDescr, parinput)
 
+	if len(wrappedKey) > 0 {
+		// data we need to create an encrypted key descriptor linked
+		// to the system partition, descriptor IDs start at 1
+		keyInput := sif.DescriptorInput{
+			Datatype: image.SIFDataCryptoMessage,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     uint32(len(cinfo.InputDescr)),
+			Data:     wrappedKey,
+		}
+		keyInput.Size = int64(binary.Size(keyInput.Data))
+
+		cinfo.InputDescr = append(cinfo.InputDescr, keyInput)
+	}
+
 	// remove anything that may exist at the build destination at last moment
 	os.RemoveAll(path)
 
@@ -111,11 +127,19 @@ func createSIF(path string, definition, ociConf []byte, squashfile string) (err
 	return nil
 }
 
-func getMksquashfsPath() (string, error) {
+func getFileConfig() (*singularityConfig.FileConfig, error) {
 	// Parse singularity configuration file
 	c := &singularityConfig.FileConfig{}
 	if err := config.Parser(buildcfg.SYSCONFDIR+"/singularity/singularity.conf", c); err != nil {
-		return "", fmt.Errorf("Unable to parse singularity.conf file: %s", err)
+		return nil, fmt.Errorf("Unable to parse singularity.conf file: %s", err)
+	}
+	return c, nil
+}
+
+func getMksquashfsPath() (string, error) {
+	c, err := getFileConfig()
+	if err != nil {
+		return "", err
 	}
 
 	// p is either "" or the string value in the conf file
@@ -130,6 +154,38 @@ func getMksquashfsPath() (string, error) {
 	return exec.LookPath(p)
 }
 
+// encryptSquashfs encrypts the squashfs image at path and returns the
+// path of the encrypted image along with the encryption key wrapped
+// with the PEM public key if any
+func encryptSquashfs(path string, k crypt.KeyInfo) (string, []byte, error) {
+	c, err := getFileConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	cryptsetup, err := crypt.FindCryptsetup(c.CryptsetupPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("while searching for cryptsetup: %s", err)
+	}
+
+	key, err := crypt.NewPlaintextKey(k)
+	if err != nil {
+		return "", nil, err
+	}
+	var wrappedKey []byte
+	if k.Format == crypt.PEM {
+		if wrappedKey, err = crypt.EncryptKey(k, key); err != nil {
+			return "", nil, err
+		}
+	}
+
+	dev := &crypt.Device{Cryptsetup: cryptsetup}
+	encrypted, err := dev.EncryptFilesystem(path, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("while encrypting filesystem: %s", err)
+	}
+	return encrypted, wrappedKey, nil
+}
+
 // Assemble creates a SIF image from a Bundle
 func (a *SIFAssembler) Assemble(b *types.Bundle, path string) (err error) {
 	sylog.Infof("Creating SIF file...")
@@ -172,7 +228,22 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) (err error) {
 		return fmt.Errorf("While running mksquashfs: %v: %s", err, strings.Replace(string(errOut), "\n", " ", -1))
 	}
 
-	err = createSIF(path, b.Recipe.Raw, b.JSONObjects["oci-config"], squashfsPath)
+	partPath := squashfsPath
+	fstype := sif.FsSquash
+	var wrappedKey []byte
+
+	if b.Opts.EncryptionKeyInfo != nil {
+		sylog.Infof("Encrypting root filesystem...")
+
+		partPath, wrappedKey, err = encryptSquashfs(squashfsPath, *b.Opts.EncryptionKeyInfo)
+		if err != nil {
+			return fmt.Errorf("While encrypting root filesystem: %v", err)
+		}
+		defer os.Remove(partPath)
+		fstype = image.SIFFsEncryptedSquashfs
+	}
+
+	err = createSIF(path, b.Recipe.Raw, b.JSONObjects["oci-config"], partPath, fstype, wrappedKey)
 	if err != nil {
 		return fmt.Errorf("While creating SIF: %v", err)
 	}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -14,6 +14,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/pkg/build/types"
 	client "github.com/sylabs/singularity/pkg/client/library"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
 )
 
 // LibraryConveyorPacker only needs to hold a packer to pack the image it pulls
@@ -54,19 +55,19 @@ func (cp *LibraryConveyorPacker) Get(b *types.Bundle) (err error) {
 	imageName := uri.GetName(libURI)
 	imagePath := cache.LibraryImage(libraryImage.Hash, imageName)
 
+	fd, err := cache.LockEntry(imagePath)
+	if err != nil {
+		return fmt.Errorf("unable to lock %v: %v", imagePath, err)
+	}
+	defer lock.Release(fd)
+
 	if exists, err := cache.LibraryImageExists(libraryImage.Hash, imageName); err != nil {
 		return fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
 	} else if !exists {
 		sylog.Infof("Downloading library image")
-		if err = client.DownloadImage(imagePath, libURI, cp.LibraryURL, true, cp.AuthToken); err != nil {
+		if err = client.DownloadImageHash(imagePath, libURI, cp.LibraryURL, true, cp.AuthToken, libraryImage.Hash); err != nil {
 			return fmt.Errorf("unable to Download Image: %v", err)
 		}
-
-		if cacheFileHash, err := client.ImageHash(imagePath); err != nil {
-			return fmt.Errorf("Error getting ImageHash: %v", err)
-		} else if cacheFileHash != libraryImage.Hash {
-			return fmt.Errorf("Cached File Hash(%s) and Expected Hash(%s) does not match", cacheFileHash, libraryImage.Hash)
-		}
 	}
 
 	// insert base metadata before unpacking fs
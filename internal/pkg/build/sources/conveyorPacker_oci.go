@@ -29,10 +29,12 @@ import (
 	"github.com/containers/image/types"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	imagetools "github.com/opencontainers/image-tools/image"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
 	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/shell"
 	sytypes "github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
 )
 
 // OCIConveyorPacker holds stuff that needs to be packed into the bundle
@@ -120,6 +122,13 @@ func (cp *OCIConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 		return fmt.Errorf("Invalid image source: %v", err)
 	}
 
+	// Blobs must not be removed from the cache while fetching the image
+	fd, err := cache.LockOciBlob()
+	if err != nil {
+		return fmt.Errorf("unable to lock oci blob cache: %v", err)
+	}
+	defer lock.Release(fd)
+
 	// Grab the modified source ref from the cache
 	cp.srcRef, err = ociclient.ConvertReference(cp.srcRef, cp.sysCtx)
 	if err != nil {
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,7 +6,6 @@
 package cgroups
 
 import (
-	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -15,11 +14,13 @@ import (
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
-// Manager manage container cgroup resources restriction
+// Manager manage container cgroup resources restriction, it uses
+// cgroups v2 unified hierarchy when the host is booted with it
 type Manager struct {
-	Path   string
-	Pid    int
-	cgroup cgroups.Cgroup
+	Path    string
+	Pid     int
+	cgroup  cgroups.Cgroup
+	unified *unified
 }
 
 func readSpecFromFile(path string) (spec specs.LinuxResources, err error) {
@@ -27,22 +28,14 @@ func readSpecFromFile(path string) (spec specs.LinuxResources, err error) {
 	if err != nil {
 		return
 	}
-
-	// convert TOML structures to OCI JSON structures
-	data, err := json.Marshal(conf)
-	if err != nil {
-		return
-	}
-
-	if err = json.Unmarshal(data, &spec); err != nil {
-		return
-	}
-
-	return
+	return conf.Spec()
 }
 
 // GetCgroupRootPath returns cgroup root path
 func (m *Manager) GetCgroupRootPath() string {
+	if m.unified != nil {
+		return UnifiedMountPoint
+	}
 	if m.cgroup == nil {
 		return ""
 	}
@@ -68,13 +61,24 @@ func (m *Manager) ApplyFromSpec(spec *specs.LinuxResources) (err error) {
 		return fmt.Errorf("cgroup path must be an absolute path")
 	}
 
-	path = cgroups.StaticPath(m.Path)
-
 	s := spec
 	if s == nil {
 		s = &specs.LinuxResources{}
 	}
 
+	if IsUnified() {
+		m.unified, err = newUnified(m.Path)
+		if err != nil {
+			return err
+		}
+		if err := m.unified.apply(s); err != nil {
+			return err
+		}
+		return m.unified.add(m.Pid)
+	}
+
+	path = cgroups.StaticPath(m.Path)
+
 	// creates cgroup
 	m.cgroup, err = cgroups.New(cgroups.V1, path, s)
 	if err != nil {
@@ -102,6 +106,10 @@ func (m *Manager) loadFromPid() (err error) {
 	if m.Pid == 0 {
 		return fmt.Errorf("no process ID specified")
 	}
+	if IsUnified() {
+		m.unified, err = loadUnified(m.Pid)
+		return
+	}
 	path := cgroups.PidPath(m.Pid)
 	m.cgroup, err = cgroups.Load(cgroups.V1, path)
 	return
@@ -109,11 +117,14 @@ func (m *Manager) loadFromPid() (err error) {
 
 // UpdateFromSpec updates cgroups resources restriction from OCI specification
 func (m *Manager) UpdateFromSpec(spec *specs.LinuxResources) (err error) {
-	if m.cgroup == nil {
+	if m.cgroup == nil && m.unified == nil {
 		if err = m.loadFromPid(); err != nil {
 			return
 		}
 	}
+	if m.unified != nil {
+		return m.unified.apply(spec)
+	}
 	err = m.cgroup.Update(spec)
 	return
 }
@@ -129,26 +140,35 @@ func (m *Manager) UpdateFromFile(path string) error {
 
 // Remove removes ressources restriction for current managed process
 func (m *Manager) Remove() error {
+	if m.unified != nil {
+		return m.unified.delete()
+	}
 	// deletes subgroup
 	return m.cgroup.Delete()
 }
 
 // Pause suspends all processes inside the container
 func (m *Manager) Pause() error {
-	if m.cgroup == nil {
+	if m.cgroup == nil && m.unified == nil {
 		if err := m.loadFromPid(); err != nil {
 			return err
 		}
 	}
+	if m.unified != nil {
+		return m.unified.freeze(true)
+	}
 	return m.cgroup.Freeze()
 }
 
 // Resume resumes all processes that have been previously paused
 func (m *Manager) Resume() error {
-	if m.cgroup == nil {
+	if m.cgroup == nil && m.unified == nil {
 		if err := m.loadFromPid(); err != nil {
 			return err
 		}
 	}
+	if m.unified != nil {
+		return m.unified.freeze(false)
+	}
 	return m.cgroup.Thaw()
 }
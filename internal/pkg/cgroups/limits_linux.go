@@ -0,0 +1,180 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	units "github.com/docker/go-units"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// defaultCPUPeriod is the CPU period (in usecs) used to compute
+// CPU quota from a number of CPUs
+const defaultCPUPeriod = 100000
+
+// Limits holds container resource limits as expressed on
+// the command line, an empty value means no limit
+type Limits struct {
+	// Memory limit with an optional unit suffix (b, k, m, g)
+	Memory string
+	// Total memory limit (memory + swap) with an optional unit
+	// suffix, -1 means unlimited swap
+	MemorySwap string
+	// Number of CPUs, may be a fractional number
+	CPUs string
+	// CPU shares (relative weight vs. other cgroups)
+	CPUShares uint64
+	// CPUs in which execution is allowed (eg: 0-3,5)
+	CpusetCpus string
+	// Maximum number of processes, -1 means unlimited
+	PidsLimit int64
+	// Block IO relative weight, between 10 and 1000
+	BlkioWeight uint16
+}
+
+// Empty returns true if no resource limit is set
+func (l Limits) Empty() bool {
+	return l == Limits{}
+}
+
+// Apply validates and sets resource limits into the cgroups
+// configuration, overriding existing values
+func (l Limits) Apply(c *Config) error {
+	if l.Memory != "" {
+		limit, err := units.RAMInBytes(l.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory limit %q: %s", l.Memory, err)
+		}
+		if c.Memory == nil {
+			c.Memory = &LinuxMemory{}
+		}
+		c.Memory.Limit = &limit
+	}
+
+	if l.MemorySwap != "" {
+		swap := int64(-1)
+		if l.MemorySwap != "-1" {
+			s, err := units.RAMInBytes(l.MemorySwap)
+			if err != nil {
+				return fmt.Errorf("invalid memory swap limit %q: %s", l.MemorySwap, err)
+			}
+			swap = s
+		}
+		if c.Memory == nil || c.Memory.Limit == nil {
+			return fmt.Errorf("memory swap limit requires a memory limit")
+		}
+		if swap != -1 && swap < *c.Memory.Limit {
+			return fmt.Errorf("memory swap limit must be greater than or equal to memory limit")
+		}
+		c.Memory.Swap = &swap
+	}
+
+	if l.CPUs != "" {
+		cpus, err := strconv.ParseFloat(l.CPUs, 64)
+		if err != nil || cpus <= 0 {
+			return fmt.Errorf("invalid number of CPUs %q", l.CPUs)
+		}
+		period := uint64(defaultCPUPeriod)
+		quota := int64(cpus * defaultCPUPeriod)
+		if c.CPU == nil {
+			c.CPU = &LinuxCPU{}
+		}
+		c.CPU.Period = &period
+		c.CPU.Quota = &quota
+	}
+
+	if l.CPUShares != 0 {
+		shares := l.CPUShares
+		if c.CPU == nil {
+			c.CPU = &LinuxCPU{}
+		}
+		c.CPU.Shares = &shares
+	}
+
+	if l.CpusetCpus != "" {
+		if c.CPU == nil {
+			c.CPU = &LinuxCPU{}
+		}
+		c.CPU.Cpus = l.CpusetCpus
+	}
+
+	if l.PidsLimit != 0 {
+		if l.PidsLimit < -1 {
+			return fmt.Errorf("invalid pids limit %d", l.PidsLimit)
+		}
+		c.Pids = &LinuxPids{Limit: l.PidsLimit}
+	}
+
+	if l.BlkioWeight != 0 {
+		if l.BlkioWeight < 10 || l.BlkioWeight > 1000 {
+			return fmt.Errorf("block IO weight must be between 10 and 1000")
+		}
+		weight := l.BlkioWeight
+		if c.BlockIO == nil {
+			c.BlockIO = &LinuxBlockIO{}
+		}
+		c.BlockIO.Weight = &weight
+	}
+
+	return nil
+}
+
+// Spec converts cgroups configuration into OCI LinuxResources
+func (c Config) Spec() (spec specs.LinuxResources, err error) {
+	// convert TOML structures to OCI JSON structures
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &spec)
+	return
+}
+
+// Controllers returns the list of cgroups controllers
+// used by resource restrictions
+func Controllers(spec *specs.LinuxResources) []string {
+	controllers := make([]string, 0)
+
+	if spec == nil {
+		return controllers
+	}
+	if len(spec.Devices) > 0 {
+		controllers = append(controllers, "devices")
+	}
+	if spec.Memory != nil {
+		controllers = append(controllers, "memory")
+	}
+	if spec.CPU != nil {
+		if spec.CPU.Shares != nil || spec.CPU.Quota != nil || spec.CPU.Period != nil ||
+			spec.CPU.RealtimeRuntime != nil || spec.CPU.RealtimePeriod != nil {
+			controllers = append(controllers, "cpu")
+		}
+		if spec.CPU.Cpus != "" || spec.CPU.Mems != "" {
+			controllers = append(controllers, "cpuset")
+		}
+	}
+	if spec.Pids != nil {
+		controllers = append(controllers, "pids")
+	}
+	if spec.BlockIO != nil {
+		controllers = append(controllers, "blkio")
+	}
+	if len(spec.HugepageLimits) > 0 {
+		controllers = append(controllers, "hugetlb")
+	}
+	if spec.Network != nil {
+		controllers = append(controllers, "net_cls")
+	}
+	if len(spec.Rdma) > 0 {
+		controllers = append(controllers, "rdma")
+	}
+
+	return controllers
+}
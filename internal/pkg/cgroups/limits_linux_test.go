@@ -0,0 +1,127 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitsApply(t *testing.T) {
+	testCases := []struct {
+		name        string
+		limits      Limits
+		controllers []string
+		expectError bool
+	}{
+		{
+			name:        "empty",
+			limits:      Limits{},
+			controllers: []string{},
+		},
+		{
+			name:        "memory",
+			limits:      Limits{Memory: "512m", MemorySwap: "1g"},
+			controllers: []string{"memory"},
+		},
+		{
+			name:        "unlimited swap",
+			limits:      Limits{Memory: "512m", MemorySwap: "-1"},
+			controllers: []string{"memory"},
+		},
+		{
+			name:        "swap without memory",
+			limits:      Limits{MemorySwap: "1g"},
+			expectError: true,
+		},
+		{
+			name:        "swap lower than memory",
+			limits:      Limits{Memory: "1g", MemorySwap: "512m"},
+			expectError: true,
+		},
+		{
+			name:        "bad memory",
+			limits:      Limits{Memory: "lots"},
+			expectError: true,
+		},
+		{
+			name:        "cpu",
+			limits:      Limits{CPUs: "1.5", CPUShares: 512},
+			controllers: []string{"cpu"},
+		},
+		{
+			name:        "bad cpus",
+			limits:      Limits{CPUs: "-1"},
+			expectError: true,
+		},
+		{
+			name:        "cpuset",
+			limits:      Limits{CpusetCpus: "0-1"},
+			controllers: []string{"cpuset"},
+		},
+		{
+			name:        "pids",
+			limits:      Limits{PidsLimit: 100},
+			controllers: []string{"pids"},
+		},
+		{
+			name:        "bad pids",
+			limits:      Limits{PidsLimit: -2},
+			expectError: true,
+		},
+		{
+			name:        "blkio",
+			limits:      Limits{BlkioWeight: 500},
+			controllers: []string{"blkio"},
+		},
+		{
+			name:        "bad blkio",
+			limits:      Limits{BlkioWeight: 5},
+			expectError: true,
+		},
+		{
+			name:        "all",
+			limits:      Limits{Memory: "1g", CPUs: "2", CpusetCpus: "0", PidsLimit: 10, BlkioWeight: 100},
+			controllers: []string{"memory", "cpu", "cpuset", "pids", "blkio"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{}
+			err := tc.limits.Apply(&c)
+			if err != nil && !tc.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tc.expectError {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				return
+			}
+
+			spec, err := c.Spec()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			controllers := Controllers(&spec)
+			if !reflect.DeepEqual(controllers, tc.controllers) {
+				t.Errorf("unexpected controllers %v instead of %v", controllers, tc.controllers)
+			}
+		})
+	}
+}
+
+func TestLimitsCPUs(t *testing.T) {
+	c := Config{}
+	if err := (Limits{CPUs: "0.5"}).Apply(&c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if *c.CPU.Period != defaultCPUPeriod {
+		t.Errorf("unexpected CPU period %d", *c.CPU.Period)
+	}
+	if *c.CPU.Quota != defaultCPUPeriod/2 {
+		t.Errorf("unexpected CPU quota %d", *c.CPU.Quota)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+// UserPath returns the cgroup path, relative to the hierarchy root, where
+// the unprivileged user uid can create the cgroup of process pid. With
+// cgroups v2 the cgroup is created in the nearest cgroup delegated to the
+// user (eg: user systemd slice), with cgroups v1 it's created in v1Path
+// which must be writable by users in each hierarchy.
+func UserPath(uid int, pid int, v1Path string) (string, error) {
+	name := fmt.Sprintf("singularity-%d", pid)
+
+	if !IsUnified() {
+		if v1Path == "" {
+			return "", fmt.Errorf("no cgroups path configured for unprivileged users with cgroups v1")
+		}
+		return filepath.Join("/", v1Path, strconv.Itoa(uid), name), nil
+	}
+
+	u, err := loadUnified(pid)
+	if err != nil {
+		return "", err
+	}
+
+	for current := u.path; current != UnifiedMountPoint; current = filepath.Dir(current) {
+		// stop at delegation boundary
+		if !fs.IsOwner(current, uint32(uid)) {
+			break
+		}
+		// a cgroup with controllers enabled for children can't
+		// contain processes, skip cgroups with processes
+		procs, err := ioutil.ReadFile(filepath.Join(current, "cgroup.procs"))
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(string(procs)) == "" {
+			return filepath.Join(strings.TrimPrefix(current, UnifiedMountPoint), name), nil
+		}
+	}
+
+	return "", fmt.Errorf("no cgroup delegated to user %d found in %s hierarchy", uid, u.path)
+}
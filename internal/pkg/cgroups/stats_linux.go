@@ -0,0 +1,129 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+)
+
+// Stats holds resource usage of a cgroup, a zero limit means no limit
+type Stats struct {
+	// Memory usage in bytes
+	MemoryUsage uint64 `json:"memoryUsage"`
+	// Memory limit in bytes
+	MemoryLimit uint64 `json:"memoryLimit"`
+	// Total CPU time consumed in nanoseconds
+	CPUUsage uint64 `json:"cpuUsage"`
+	// Number of processes
+	Pids uint64 `json:"pids"`
+	// Maximum number of processes
+	PidsLimit uint64 `json:"pidsLimit"`
+}
+
+// GetStats returns resource usage of the managed cgroup
+func (m *Manager) GetStats() (*Stats, error) {
+	if m.cgroup == nil && m.unified == nil {
+		if err := m.loadFromPid(); err != nil {
+			return nil, err
+		}
+	}
+	if m.unified != nil {
+		return m.unified.stats()
+	}
+
+	metrics, err := m.cgroup.Stat(cgroups.IgnoreNotExist)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	if metrics.Memory != nil && metrics.Memory.Usage != nil {
+		stats.MemoryUsage = metrics.Memory.Usage.Usage
+		// no limit is reported as the maximum page counter value
+		if metrics.Memory.Usage.Limit < 1<<62 {
+			stats.MemoryLimit = metrics.Memory.Usage.Limit
+		}
+	}
+	if metrics.CPU != nil && metrics.CPU.Usage != nil {
+		stats.CPUUsage = metrics.CPU.Usage.Total
+	}
+	if metrics.Pids != nil {
+		stats.Pids = metrics.Pids.Current
+		stats.PidsLimit = metrics.Pids.Limit
+	}
+
+	return stats, nil
+}
+
+// readUint reads an unsigned integer value from the cgroup
+// interface file name, max value and missing file return 0
+func (u *unified) readUint(name string) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(u.path, name))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// stats returns resource usage of the cgroup
+func (u *unified) stats() (*Stats, error) {
+	var err error
+
+	stats := &Stats{}
+
+	values := []struct {
+		name  string
+		value *uint64
+	}{
+		{"memory.current", &stats.MemoryUsage},
+		{"memory.max", &stats.MemoryLimit},
+		{"pids.current", &stats.Pids},
+		{"pids.max", &stats.PidsLimit},
+	}
+
+	for _, v := range values {
+		*v.value, err = u.readUint(v.name)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s: %s", v.name, err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(u.path, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usage, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("while reading cpu.stat: %s", err)
+			}
+			stats.CPUUsage = usage * 1000
+			break
+		}
+	}
+
+	return stats, scanner.Err()
+}
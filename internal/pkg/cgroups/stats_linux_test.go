@@ -0,0 +1,51 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnifiedStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"memory.current": "1048576\n",
+		"memory.max":     "max\n",
+		"pids.current":   "4\n",
+		"pids.max":       "100\n",
+		"cpu.stat":       "usage_usec 1500\nuser_usec 1000\nsystem_usec 500\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	u := &unified{path: dir}
+	stats, err := u.stats()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Stats{
+		MemoryUsage: 1048576,
+		MemoryLimit: 0,
+		CPUUsage:    1500000,
+		Pids:        4,
+		PidsLimit:   100,
+	}
+	if *stats != expected {
+		t.Errorf("unexpected stats %+v instead of %+v", *stats, expected)
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// UnifiedMountPoint is the mount point of cgroups v2 unified hierarchy
+const UnifiedMountPoint = "/sys/fs/cgroup"
+
+// cgroup2SuperMagic is the cgroup2 filesystem magic number
+const cgroup2SuperMagic = 0x63677270
+
+// unifiedControllers lists controllers enabled for container cgroups
+var unifiedControllers = []string{"cpu", "cpuset", "io", "memory", "pids", "hugetlb", "rdma"}
+
+// IsUnified returns true if the host uses cgroups v2 unified hierarchy
+func IsUnified() bool {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs(UnifiedMountPoint, &st); err != nil {
+		return false
+	}
+	return st.Type == cgroup2SuperMagic
+}
+
+// unsupportedError returns an error reporting a control
+// not supported by cgroups v2
+func unsupportedError(control string) error {
+	return fmt.Errorf("%s is not supported by cgroups v2", control)
+}
+
+// deviceRule returns a device cgroup rule in the devices.allow and
+// devices.deny format prefixed by allow or deny, e.g. "allow c 1:3 rw"
+func deviceRule(d specs.LinuxDeviceCgroup) string {
+	action := "deny"
+	if d.Allow {
+		action = "allow"
+	}
+	typ := d.Type
+	if typ == "" {
+		typ = "a"
+	}
+	major, minor := "*", "*"
+	if d.Major != nil {
+		major = strconv.FormatInt(*d.Major, 10)
+	}
+	if d.Minor != nil {
+		minor = strconv.FormatInt(*d.Minor, 10)
+	}
+	return fmt.Sprintf("%s %s %s:%s %s", action, typ, major, minor, d.Access)
+}
+
+// unifiedFile holds a value to write in a cgroups v2 interface file
+type unifiedFile struct {
+	name  string
+	value string
+}
+
+// unifiedResources maps OCI resources restriction onto
+// cgroups v2 interface files
+func unifiedResources(spec *specs.LinuxResources) ([]unifiedFile, error) {
+	files := make([]unifiedFile, 0)
+
+	if spec == nil {
+		return files, nil
+	}
+
+	if len(spec.Devices) > 0 {
+		return nil, unsupportedError(fmt.Sprintf("devices rule %q", deviceRule(spec.Devices[0])))
+	}
+
+	if m := spec.Memory; m != nil {
+		if m.Kernel != nil {
+			return nil, unsupportedError("memory.kernel")
+		}
+		if m.KernelTCP != nil {
+			return nil, unsupportedError("memory.kernelTCP")
+		}
+		if m.Swappiness != nil {
+			return nil, unsupportedError("memory.swappiness")
+		}
+		if m.DisableOOMKiller != nil && *m.DisableOOMKiller {
+			return nil, unsupportedError("memory.disableOOMKiller")
+		}
+		if m.Limit != nil {
+			files = append(files, unifiedFile{"memory.max", unifiedMax(*m.Limit)})
+		}
+		if m.Reservation != nil {
+			files = append(files, unifiedFile{"memory.low", unifiedMax(*m.Reservation)})
+		}
+		if m.Swap != nil {
+			// cgroups v1 swap limit includes memory, v2 limit is swap only
+			swap := *m.Swap
+			if swap > 0 {
+				if m.Limit == nil || *m.Limit <= 0 {
+					return nil, fmt.Errorf("memory.swap requires memory.limit with cgroups v2")
+				}
+				if swap < *m.Limit {
+					return nil, fmt.Errorf("memory.swap must be greater than or equal to memory.limit")
+				}
+				swap -= *m.Limit
+			}
+			files = append(files, unifiedFile{"memory.swap.max", unifiedMax(swap)})
+		}
+	}
+
+	if c := spec.CPU; c != nil {
+		if c.RealtimeRuntime != nil {
+			return nil, unsupportedError("cpu.realtimeRuntime")
+		}
+		if c.RealtimePeriod != nil {
+			return nil, unsupportedError("cpu.realtimePeriod")
+		}
+		if c.Shares != nil && *c.Shares != 0 {
+			files = append(files, unifiedFile{"cpu.weight", strconv.FormatUint(sharesToWeight(*c.Shares), 10)})
+		}
+		if c.Quota != nil || c.Period != nil {
+			quota := "max"
+			period := uint64(defaultCPUPeriod)
+			if c.Quota != nil && *c.Quota > 0 {
+				quota = strconv.FormatInt(*c.Quota, 10)
+			}
+			if c.Period != nil && *c.Period != 0 {
+				period = *c.Period
+			}
+			files = append(files, unifiedFile{"cpu.max", fmt.Sprintf("%s %d", quota, period)})
+		}
+		if c.Cpus != "" {
+			files = append(files, unifiedFile{"cpuset.cpus", c.Cpus})
+		}
+		if c.Mems != "" {
+			files = append(files, unifiedFile{"cpuset.mems", c.Mems})
+		}
+	}
+
+	if p := spec.Pids; p != nil {
+		files = append(files, unifiedFile{"pids.max", unifiedMax(p.Limit)})
+	}
+
+	if b := spec.BlockIO; b != nil {
+		if b.LeafWeight != nil {
+			return nil, unsupportedError("blockIO.leafWeight")
+		}
+		if b.Weight != nil {
+			files = append(files, unifiedFile{"io.weight", fmt.Sprintf("default %d", blkioToIOWeight(*b.Weight))})
+		}
+		for _, d := range b.WeightDevice {
+			if d.LeafWeight != nil {
+				return nil, unsupportedError("blockIO.weightDevice.leafWeight")
+			}
+			if d.Weight != nil {
+				files = append(files, unifiedFile{"io.weight", fmt.Sprintf("%d:%d %d", d.Major, d.Minor, blkioToIOWeight(*d.Weight))})
+			}
+		}
+		throttles := []struct {
+			key     string
+			devices []specs.LinuxThrottleDevice
+		}{
+			{"rbps", b.ThrottleReadBpsDevice},
+			{"wbps", b.ThrottleWriteBpsDevice},
+			{"riops", b.ThrottleReadIOPSDevice},
+			{"wiops", b.ThrottleWriteIOPSDevice},
+		}
+		for _, t := range throttles {
+			for _, d := range t.devices {
+				files = append(files, unifiedFile{"io.max", fmt.Sprintf("%d:%d %s=%d", d.Major, d.Minor, t.key, d.Rate)})
+			}
+		}
+	}
+
+	for _, h := range spec.HugepageLimits {
+		files = append(files, unifiedFile{fmt.Sprintf("hugetlb.%s.max", h.Pagesize), strconv.FormatUint(h.Limit, 10)})
+	}
+
+	if spec.Network != nil {
+		return nil, unsupportedError("network")
+	}
+
+	for device, r := range spec.Rdma {
+		limits := make([]string, 0, 2)
+		if r.HcaHandles != nil {
+			limits = append(limits, fmt.Sprintf("hca_handle=%d", *r.HcaHandles))
+		}
+		if r.HcaObjects != nil {
+			limits = append(limits, fmt.Sprintf("hca_object=%d", *r.HcaObjects))
+		}
+		if len(limits) > 0 {
+			files = append(files, unifiedFile{"rdma.max", device + " " + strings.Join(limits, " ")})
+		}
+	}
+
+	return files, nil
+}
+
+// unifiedMax returns limit value or max for negative or zero limit
+func unifiedMax(limit int64) string {
+	if limit <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// sharesToWeight converts cgroups v1 CPU shares [2-262144]
+// to cgroups v2 CPU weight [1-10000]
+func sharesToWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	} else if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// blkioToIOWeight converts cgroups v1 block IO weight [10-1000]
+// to cgroups v2 IO weight [1-10000]
+func blkioToIOWeight(weight uint16) uint64 {
+	w := uint64(weight)
+	if w < 10 {
+		w = 10
+	} else if w > 1000 {
+		w = 1000
+	}
+	return 1 + ((w-10)*9999)/990
+}
+
+// unified is a cgroup in cgroups v2 unified hierarchy
+type unified struct {
+	// absolute path of the cgroup directory
+	path string
+}
+
+// newUnified creates a cgroup at path relative to the unified
+// hierarchy root and enables controllers from the root to it
+func newUnified(path string) (*unified, error) {
+	u := &unified{path: filepath.Join(UnifiedMountPoint, path)}
+
+	if err := os.MkdirAll(u.path, 0755); err != nil {
+		return nil, err
+	}
+
+	// enable controllers in each parent cgroup subtree
+	current := UnifiedMountPoint
+	for _, elem := range strings.Split(strings.Trim(filepath.Clean(path), "/"), "/") {
+		if err := enableControllers(current); err != nil {
+			return nil, err
+		}
+		current = filepath.Join(current, elem)
+	}
+
+	return u, nil
+}
+
+// loadUnified returns the unified cgroup of process pid
+func loadUnified(pid int) (*unified, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// unified hierarchy entry has the form 0::/path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) == 3 && fields[0] == "0" && fields[1] == "" {
+			return &unified{path: filepath.Join(UnifiedMountPoint, fields[2])}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("no unified cgroup found for process %d", pid)
+}
+
+// enableControllers enables available controllers for
+// children of the cgroup at path
+func enableControllers(path string) error {
+	data, err := ioutil.ReadFile(filepath.Join(path, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	available := strings.Fields(string(data))
+
+	subtree := filepath.Join(path, "cgroup.subtree_control")
+	data, err = ioutil.ReadFile(subtree)
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(data))
+
+	enable := make([]string, 0, len(unifiedControllers))
+
+	for _, c := range unifiedControllers {
+		if hasController(available, c) && !hasController(enabled, c) {
+			enable = append(enable, "+"+c)
+		}
+	}
+
+	if len(enable) == 0 {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(subtree, []byte(strings.Join(enable, " ")), 0644); err != nil {
+		// cgroups above the subtree delegated to an unprivileged
+		// user are not writable, controllers not enabled by the
+		// administrator are reported when applying limits
+		if os.IsPermission(err) {
+			return nil
+		}
+		return fmt.Errorf("while enabling controllers in %s: %s", subtree, err)
+	}
+
+	return nil
+}
+
+func hasController(controllers []string, controller string) bool {
+	for _, c := range controllers {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes value in the cgroup interface file name
+func (u *unified) write(name string, value string) error {
+	path := filepath.Join(u.path, name)
+
+	if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s is not available, is the corresponding controller enabled ?", name)
+		}
+		return fmt.Errorf("while writing %q to %s: %s", value, path, err)
+	}
+
+	return nil
+}
+
+// apply applies resources restriction to the cgroup
+func (u *unified) apply(spec *specs.LinuxResources) error {
+	files, err := unifiedResources(spec)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := u.write(f.name, f.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add moves process pid into the cgroup
+func (u *unified) add(pid int) error {
+	return u.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// freeze suspends or resumes all processes in the cgroup
+func (u *unified) freeze(frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+
+	if _, err := os.Stat(filepath.Join(u.path, "cgroup.freeze")); os.IsNotExist(err) {
+		return fmt.Errorf("freezer is not supported by cgroups v2 on this kernel (requires cgroup.freeze)")
+	}
+
+	return u.write("cgroup.freeze", value)
+}
+
+// delete removes the cgroup
+func (u *unified) delete() error {
+	return os.Remove(u.path)
+}
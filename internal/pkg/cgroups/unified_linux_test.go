@@ -0,0 +1,139 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestUnifiedResources(t *testing.T) {
+	limit := int64(1024 * 1024 * 1024)
+	swap := int64(2 * 1024 * 1024 * 1024)
+	unlimited := int64(-1)
+	shares := uint64(1024)
+	quota := int64(50000)
+	period := uint64(100000)
+	weight := uint16(500)
+	kernel := int64(1024)
+	rt := int64(1000)
+	major := int64(1)
+	minor := int64(3)
+
+	testCases := []struct {
+		name        string
+		spec        *specs.LinuxResources
+		files       []unifiedFile
+		unsupported string
+	}{
+		{
+			name:  "nil",
+			spec:  nil,
+			files: []unifiedFile{},
+		},
+		{
+			name: "memory",
+			spec: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+			},
+			files: []unifiedFile{
+				{"memory.max", "1073741824"},
+				{"memory.swap.max", "1073741824"},
+			},
+		},
+		{
+			name: "unlimited swap",
+			spec: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: &limit, Swap: &unlimited},
+			},
+			files: []unifiedFile{
+				{"memory.max", "1073741824"},
+				{"memory.swap.max", "max"},
+			},
+		},
+		{
+			name: "cpu",
+			spec: &specs.LinuxResources{
+				CPU: &specs.LinuxCPU{Shares: &shares, Quota: &quota, Period: &period, Cpus: "0-1"},
+			},
+			files: []unifiedFile{
+				{"cpu.weight", "39"},
+				{"cpu.max", "50000 100000"},
+				{"cpuset.cpus", "0-1"},
+			},
+		},
+		{
+			name: "pids and io",
+			spec: &specs.LinuxResources{
+				Pids:    &specs.LinuxPids{Limit: 100},
+				BlockIO: &specs.LinuxBlockIO{Weight: &weight},
+			},
+			files: []unifiedFile{
+				{"pids.max", "100"},
+				{"io.weight", "default 4950"},
+			},
+		},
+		{
+			name: "kernel memory",
+			spec: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Kernel: &kernel},
+			},
+			unsupported: "memory.kernel",
+		},
+		{
+			name: "realtime",
+			spec: &specs.LinuxResources{
+				CPU: &specs.LinuxCPU{RealtimeRuntime: &rt},
+			},
+			unsupported: "cpu.realtimeRuntime",
+		},
+		{
+			name: "devices",
+			spec: &specs.LinuxResources{
+				Devices: []specs.LinuxDeviceCgroup{{Allow: false, Access: "rwm"}},
+			},
+			unsupported: `devices rule "deny a *:* rwm"`,
+		},
+		{
+			name: "device",
+			spec: &specs.LinuxResources{
+				Devices: []specs.LinuxDeviceCgroup{{Allow: true, Type: "c", Major: &major, Minor: &minor, Access: "rw"}},
+			},
+			unsupported: `devices rule "allow c 1:3 rw"`,
+		},
+		{
+			name: "network",
+			spec: &specs.LinuxResources{
+				Network: &specs.LinuxNetwork{},
+			},
+			unsupported: "network",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := unifiedResources(tc.spec)
+			if tc.unsupported != "" {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				if !strings.HasPrefix(err.Error(), tc.unsupported+" ") {
+					t.Fatalf("error %q doesn't report %s", err, tc.unsupported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(files, tc.files) {
+				t.Errorf("unexpected files %v instead of %v", files, tc.files)
+			}
+		})
+	}
+}
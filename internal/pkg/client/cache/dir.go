@@ -8,6 +8,7 @@ package cache
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path"
@@ -41,17 +42,47 @@ func Root() string {
 	return root
 }
 
-// Clean : wipes all files in the cache directory, will return a error if one occurs
+// Clean : wipes all files in the cache directory except entries still in use,
+// will return a error if one occurs
 func Clean() error {
-	sylog.Debugf("Removing: %v", Root())
+	if err := CleanType(""); err != nil {
+		return fmt.Errorf("unable to clean all cache: %s", err)
+	}
 
-	if err := os.RemoveAll(Root()); err != nil {
+	files, err := ioutil.ReadDir(Root())
+	if err != nil {
 		return fmt.Errorf("unable to clean all cache: %s", err)
 	}
 
+	for _, f := range files {
+		if isEntryDir(f.Name()) {
+			continue
+		}
+
+		path := filepath.Join(Root(), f.Name())
+		sylog.Debugf("Removing: %v", path)
+
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("unable to clean all cache: %s", err)
+		}
+	}
+
 	return nil
 }
 
+// isEntryDir returns if name is a cache directory holding entries
+func isEntryDir(name string) bool {
+	if name == OciBlobDir {
+		return true
+	}
+	for _, t := range entryDirs {
+		if name == t {
+			return true
+		}
+	}
+	return false
+}
+
 func updateCacheRoot() {
 	usr, err := user.Current()
 	if err != nil {
@@ -0,0 +1,288 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+const (
+	// MaxSizeEnv specifies the environment variable which can set the
+	// maximum size of the cache, e.g. 10G
+	MaxSizeEnv = "SINGULARITY_CACHE_MAXSIZE"
+
+	// MaxAgeEnv specifies the environment variable which can set the
+	// number of days after which unused cache entries are removed
+	MaxAgeEnv = "SINGULARITY_CACHE_MAXAGE"
+)
+
+// entryDirs are the directories inside cache.Dir() holding one cache
+// entry per sub-directory
+var entryDirs = []string{LibraryDir, OciTempDir, ShubDir, NetDir, LazyDir, OciSandboxDir}
+
+// Entry describes a cache entry, a directory holding a cached image
+type Entry struct {
+	// Path is the abs path of the entry directory
+	Path string
+	// Type is the cache directory holding the entry
+	Type string
+	// Size is the disk usage of the entry in bytes
+	Size int64
+	// LastUsed is the last time the entry was used
+	LastUsed time.Time
+}
+
+// LockEntry applies an exclusive lock on the cache entry holding the image
+// at path, it must be held while creating the image. The entry is marked
+// as used for the LRU eviction
+func LockEntry(path string) (int, error) {
+	return lockEntry(filepath.Dir(path))
+}
+
+func lockEntry(dir string) (int, error) {
+	return lockDir(dir, lock.Exclusive)
+}
+
+// lockDir applies a lock with lockFn on the cache entry directory dir and
+// marks it as used. An entry removed while waiting for the lock is created
+// again, so the lock is never held on a removed directory
+func lockDir(dir string, lockFn func(string) (int, error)) (int, error) {
+	for {
+		fd, err := lockFn(dir)
+		if os.IsNotExist(err) {
+			if err := initCacheDir(dir); err != nil {
+				return -1, err
+			}
+			continue
+		} else if err != nil {
+			return fd, err
+		}
+
+		var locked, st syscall.Stat_t
+		if err := syscall.Fstat(fd, &locked); err != nil {
+			lock.Release(fd)
+			return -1, err
+		}
+		if err := syscall.Stat(dir, &st); err == nil && st.Dev == locked.Dev && st.Ino == locked.Ino {
+			now := time.Now()
+			if err := os.Chtimes(dir, now, now); err != nil {
+				sylog.Debugf("Could not update access time of %s: %s", dir, err)
+			}
+			return fd, nil
+		}
+
+		sylog.Debugf("Cache entry %s removed while waiting for lock", dir)
+		lock.Release(fd)
+		if err := initCacheDir(dir); err != nil {
+			return -1, err
+		}
+	}
+}
+
+// Entries returns the entries of the cache, the blob cache shared by oci
+// images is returned as a single entry
+func Entries() ([]Entry, error) {
+	var entries []Entry
+
+	for _, t := range entryDirs {
+		dir := filepath.Join(Root(), t)
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read %s cache: %s", t, err)
+		}
+		for _, f := range files {
+			if !f.IsDir() {
+				continue
+			}
+			e, err := newEntry(filepath.Join(dir, f.Name()), t, f)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	blob := filepath.Join(Root(), OciBlobDir)
+	if fi, err := os.Stat(blob); err == nil {
+		e, err := newEntry(blob, OciBlobDir, fi)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func newEntry(path, t string, fi os.FileInfo) (Entry, error) {
+	e := Entry{Path: path, Type: t, LastUsed: fi.ModTime()}
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			e.Size += st.Blocks * 512
+		} else {
+			e.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return e, fmt.Errorf("unable to get size of %s: %s", path, err)
+	}
+	return e, nil
+}
+
+// Remove removes the cache entry, it returns false if the entry is
+// locked or is a sandbox still used by containers
+func (e Entry) Remove() (bool, error) {
+	fd, err := lock.TryExclusive(e.Path)
+	if err == syscall.EWOULDBLOCK {
+		sylog.Debugf("Not removing %s in use", e.Path)
+		return false, nil
+	} else if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to lock %s: %s", e.Path, err)
+	}
+	defer lock.Release(fd)
+
+	if e.Type == OciSandboxDir {
+		n, err := References(filepath.Base(e.Path))
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			sylog.Debugf("Not removing sandbox %s used by %d container(s)", e.Path, n)
+			return false, nil
+		}
+	}
+
+	sylog.Debugf("Removing: %v", e.Path)
+	if err := os.RemoveAll(e.Path); err != nil {
+		return false, fmt.Errorf("unable to remove %s: %s", e.Path, err)
+	}
+	return true, nil
+}
+
+// CleanType removes the entries of the cache directory t, or all entries
+// if t is empty. Entries in use are kept
+func CleanType(t string) error {
+	entries, err := Entries()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if t != "" && e.Type != t {
+			continue
+		}
+		removed, err := e.Remove()
+		if err != nil {
+			return err
+		}
+		if !removed {
+			sylog.Warningf("Not removing %s still in use", e.Path)
+		}
+	}
+	return nil
+}
+
+// Evict removes cache entries unused for more than maxAge, then least
+// recently used entries until the cache size is not greater than maxSize.
+// A zero maxAge or maxSize disables the corresponding limit, entries in
+// use are kept
+func Evict(maxSize int64, maxAge time.Duration) error {
+	if maxSize <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	// serialize evictions
+	fd, err := lock.Exclusive(Root())
+	if err != nil {
+		return fmt.Errorf("unable to lock cache: %s", err)
+	}
+	defer lock.Release(fd)
+
+	entries, err := Entries()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	for _, e := range entries {
+		expired := maxAge > 0 && time.Since(e.LastUsed) > maxAge
+		oversize := maxSize > 0 && size > maxSize
+		if !expired && !oversize {
+			continue
+		}
+
+		removed, err := e.Remove()
+		if err != nil {
+			return err
+		}
+		if removed {
+			sylog.Verbosef("Evicted %s from cache", e.Path)
+			size -= e.Size
+		}
+	}
+
+	if maxSize > 0 && size > maxSize {
+		sylog.Warningf("Cache size %d bytes exceeds limit of %d bytes, remaining entries are in use", size, maxSize)
+	}
+	return nil
+}
+
+// ParseSize parses a size in bytes with an optional K, M, G or T suffix
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	shift := uint(0)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+		if shift > 0 {
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int64
+		err      bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"10K", 10 << 10, false},
+		{"10m", 10 << 20, false},
+		{"2G", 2 << 30, false},
+		{"2GiB", 2 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"-1G", 0, true},
+		{"1X", 0, true},
+	}
+
+	for _, tt := range tests {
+		size, err := ParseSize(tt.size)
+		if err != nil && !tt.err {
+			t.Errorf("unexpected error for %q: %s", tt.size, err)
+		} else if err == nil && tt.err {
+			t.Errorf("unexpected success for %q", tt.size)
+		} else if size != tt.expected {
+			t.Errorf("unexpected size %d for %q", size, tt.size)
+		}
+	}
+}
+
+// addEntry creates a cache entry holding an image of size bytes last
+// used at the given time
+func addEntry(t *testing.T, path string, size int, used time.Time) {
+	if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Dir(path), used, used); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestEvict(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	now := time.Now()
+
+	old := LibraryImage("old", "old.sif")
+	addEntry(t, old, 1<<20, now.Add(-48*time.Hour))
+	lru := NetImage("lru", "lru.sif")
+	addEntry(t, lru, 1<<20, now.Add(-2*time.Hour))
+	recent := ShubImage("recent", "recent.sif")
+	addEntry(t, recent, 1<<20, now.Add(-time.Hour))
+
+	entries, err := Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("unexpected %d cache entries", len(entries))
+	}
+	for _, e := range entries {
+		if e.Size < 1<<20 {
+			t.Errorf("unexpected size %d of %s", e.Size, e.Path)
+		}
+	}
+
+	// no limit
+	if err := Evict(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(old) || !exists(lru) || !exists(recent) {
+		t.Fatalf("entries removed without limits")
+	}
+
+	// entries unused for more than a day
+	if err := Evict(0, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if exists(old) {
+		t.Errorf("expired entry not removed")
+	}
+	if !exists(lru) || !exists(recent) {
+		t.Errorf("unexpired entries removed")
+	}
+
+	// least recently used entry is removed first, locked
+	// entries are kept
+	fd, err := LockEntry(recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Evict(3<<19, 0); err != nil {
+		t.Fatal(err)
+	}
+	if exists(lru) {
+		t.Errorf("least recently used entry not removed")
+	}
+	if !exists(recent) {
+		t.Errorf("recently used entry removed")
+	}
+	if err := Evict(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(recent) {
+		t.Errorf("locked entry removed")
+	}
+	lock.Release(fd)
+
+	if err := Evict(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if exists(recent) {
+		t.Errorf("entry not removed after lock release")
+	}
+}
+
+func TestCleanType(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	now := time.Now()
+
+	library := LibraryImage("sum", "library.sif")
+	addEntry(t, library, 10, now)
+	net := NetImage("sum", "net.sif")
+	addEntry(t, net, 10, now)
+	shub := ShubImage("sum", "shub.sif")
+	addEntry(t, shub, 10, now)
+
+	if err := CleanType(LibraryDir); err != nil {
+		t.Fatal(err)
+	}
+	if exists(library) {
+		t.Errorf("library entry not removed")
+	}
+	if !exists(net) || !exists(shub) {
+		t.Errorf("entries of other types removed")
+	}
+
+	fd, err := LockEntry(shub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release(fd)
+
+	if err := Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if exists(net) {
+		t.Errorf("net entry not removed")
+	}
+	if !exists(shub) {
+		t.Errorf("locked entry removed")
+	}
+}
+
+func TestLockEntryRemoved(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	image := NetImage("removed", "removed.sif")
+	dir := filepath.Dir(image)
+
+	// hold the lock like Remove does while the entry is removed
+	fd, err := lock.Exclusive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan int)
+	go func() {
+		fd, err := LockEntry(image)
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- fd
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	lock.Release(fd)
+
+	fd = <-locked
+	defer lock.Release(fd)
+
+	if !exists(dir) {
+		t.Fatalf("removed entry %s not created again", dir)
+	}
+	// the lock is held on the new entry directory
+	if _, err := lock.TryExclusive(dir); err == nil {
+		t.Errorf("entry %s not locked", dir)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+const (
+	// LazyDir is the directory inside cache.Dir() where chunks of lazily
+	// loaded images are cached
+	LazyDir = "lazy"
+
+	// ChunkSize is the size of chunks fetched from lazily loaded images
+	ChunkSize = 1 << 20
+)
+
+// Lazy returns the directory inside cache.Dir() where chunks of lazily loaded
+// images are cached
+func Lazy() string {
+	return updateCacheSubdir(LazyDir)
+}
+
+// LazyImage creates a LazyDir/sum directory for the image at url and returns
+// its abs path
+func LazyImage(url string) string {
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(url)))
+	return updateCacheSubdir(filepath.Join(LazyDir, sum))
+}
+
+// ChunkCache is an io.ReaderAt reading an image through a cache of its
+// chunks, missing chunks are fetched from the image source on demand
+type ChunkCache struct {
+	src  io.ReaderAt
+	size int64
+
+	mu     sync.Mutex
+	data   *os.File
+	chunks *os.File
+}
+
+// NewChunkCache returns a chunk cache of size bytes for the image at url,
+// missing chunks are read from src. The validator identifies the image
+// content, cached chunks are discarded when it changes
+func NewChunkCache(url, validator string, size int64, src io.ReaderAt) (*ChunkCache, error) {
+	dir := LazyImage(url)
+
+	fd, err := lockEntry(dir)
+	if err != nil {
+		return nil, fmt.Errorf("while locking %s: %s", dir, err)
+	}
+	defer lock.Release(fd)
+
+	c := &ChunkCache{src: src, size: size}
+
+	c.data, err = os.OpenFile(filepath.Join(dir, "data"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("while opening chunk cache data: %s", err)
+	}
+	c.chunks, err = os.OpenFile(filepath.Join(dir, "chunks"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		c.data.Close()
+		return nil, fmt.Errorf("while opening chunk cache index: %s", err)
+	}
+
+	validatorPath := filepath.Join(dir, "validator")
+	previous, _ := ioutil.ReadFile(validatorPath)
+	fi, err := c.data.Stat()
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("while getting chunk cache data size: %s", err)
+	}
+
+	if validator == "" || string(previous) != validator || fi.Size() != size {
+		sylog.Debugf("Discarding cached chunks of %s", url)
+		if err := c.reset(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if err := ioutil.WriteFile(validatorPath, []byte(validator), 0644); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("while writing chunk cache validator: %s", err)
+		}
+	}
+
+	return c, nil
+}
+
+// reset discards all cached chunks
+func (c *ChunkCache) reset() error {
+	nchunks := (c.size + ChunkSize - 1) / ChunkSize
+
+	for _, t := range []struct {
+		f    *os.File
+		size int64
+	}{
+		{c.data, c.size},
+		{c.chunks, nchunks},
+	} {
+		if err := t.f.Truncate(0); err != nil {
+			return fmt.Errorf("while resetting chunk cache: %s", err)
+		}
+		if err := t.f.Truncate(t.size); err != nil {
+			return fmt.Errorf("while resetting chunk cache: %s", err)
+		}
+	}
+	return nil
+}
+
+// ReadAt reads len(p) bytes at offset off, chunks not cached yet are
+// fetched first
+func (c *ChunkCache) ReadAt(p []byte, off int64) (int, error) {
+	if off >= c.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > c.size {
+		end = c.size
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for chunk := off / ChunkSize; chunk*ChunkSize < end; chunk++ {
+		if err := c.fetch(chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := c.data.ReadAt(p[:end-off], off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// fetch copies the chunk from the image source to the cache if it's
+// not cached yet
+func (c *ChunkCache) fetch(chunk int64) error {
+	cached := make([]byte, 1)
+	if _, err := c.chunks.ReadAt(cached, chunk); err != nil {
+		return fmt.Errorf("while reading chunk cache index: %s", err)
+	}
+	if cached[0] != 0 {
+		return nil
+	}
+
+	off := chunk * ChunkSize
+	length := int64(ChunkSize)
+	if off+length > c.size {
+		length = c.size - off
+	}
+
+	b := make([]byte, length)
+	if n, err := c.src.ReadAt(b, off); err != nil && !(err == io.EOF && int64(n) == length) {
+		return fmt.Errorf("while fetching chunk %d: %s", chunk, err)
+	}
+	if _, err := c.data.WriteAt(b, off); err != nil {
+		return fmt.Errorf("while caching chunk %d: %s", chunk, err)
+	}
+	if _, err := c.chunks.WriteAt([]byte{1}, chunk); err != nil {
+		return fmt.Errorf("while updating chunk cache index: %s", err)
+	}
+	return nil
+}
+
+// Close closes the chunk cache files
+func (c *ChunkCache) Close() error {
+	c.chunks.Close()
+	return c.data.Close()
+}
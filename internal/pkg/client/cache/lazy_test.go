@@ -0,0 +1,102 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// countingReader counts reads done on the image source
+type countingReader struct {
+	r     *bytes.Reader
+	reads int
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+func TestChunkCache(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	const url = "https://example.com/image.sif"
+
+	content := make([]byte, 3*ChunkSize+100)
+	rand.Read(content)
+	src := &countingReader{r: bytes.NewReader(content)}
+
+	c, err := NewChunkCache(url, "v1", int64(len(content)), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read across the first two chunks
+	p := make([]byte, 200)
+	off := int64(ChunkSize - 100)
+	if n, err := c.ReadAt(p, off); err != nil || n != len(p) {
+		t.Fatalf("unexpected read of %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(p, content[off:off+200]) {
+		t.Errorf("unexpected data read")
+	}
+	if src.reads != 2 {
+		t.Errorf("unexpected %d source reads instead of 2", src.reads)
+	}
+
+	// cached chunks are not fetched again
+	if _, err := c.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if src.reads != 2 {
+		t.Errorf("cached chunk fetched again")
+	}
+
+	// short read at the end of the image
+	off = int64(len(content) - 50)
+	if n, err := c.ReadAt(p, off); err != io.EOF || n != 50 {
+		t.Errorf("unexpected read of %d bytes at end of image: %v", n, err)
+	} else if !bytes.Equal(p[:50], content[off:]) {
+		t.Errorf("unexpected data read at end of image")
+	}
+	if _, err := c.ReadAt(p, int64(len(content))); err != io.EOF {
+		t.Errorf("unexpected error for read past end of image: %v", err)
+	}
+	c.Close()
+
+	// chunks are kept for the same image content
+	src.reads = 0
+	c, err = NewChunkCache(url, "v1", int64(len(content)), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if src.reads != 0 {
+		t.Errorf("cached chunk fetched again after reopening")
+	}
+	c.Close()
+
+	// chunks are discarded when image content changed
+	c, err = NewChunkCache(url, "v2", int64(len(content)), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if src.reads != 1 {
+		t.Errorf("stale chunk not fetched again")
+	}
+	c.Close()
+}
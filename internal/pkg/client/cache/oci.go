@@ -8,6 +8,8 @@ package cache
 import (
 	"os"
 	"path/filepath"
+
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
 )
 
 const (
@@ -22,6 +24,13 @@ func OciBlob() string {
 	return updateCacheSubdir(OciBlobDir)
 }
 
+// LockOciBlob applies a shared lock on the oci blob cache, it must be held
+// while fetching images so blobs are not evicted. The blob cache is marked
+// as used for the LRU eviction
+func LockOciBlob() (int, error) {
+	return lockDir(OciBlob(), lock.Shared)
+}
+
 // OciTemp returns the directory inside cache.Dir() where splatted out oci images live
 func OciTemp() string {
 	return updateCacheSubdir(OciTempDir)
@@ -0,0 +1,162 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+const (
+	// OciSandboxDir is the directory inside cache.Dir() where oci images
+	// unpacked as sandboxes live
+	OciSandboxDir = "oci-sandbox"
+
+	// refsDir is the directory inside a sandbox entry holding references
+	refsDir = ".refs"
+)
+
+// OciSandbox returns the directory inside cache.Dir() where oci images unpacked
+// as sandboxes live
+func OciSandbox() string {
+	return updateCacheSubdir(OciSandboxDir)
+}
+
+// OciSandboxEntry creates a OciSandboxDir/sum directory and returns its abs path,
+// the entry holds the sandbox and its references
+func OciSandboxEntry(sum string) string {
+	return updateCacheSubdir(filepath.Join(OciSandboxDir, sum))
+}
+
+// OciSandboxImage creates a OciSandboxDir/sum directory and returns the abs path
+// of the sandbox
+func OciSandboxImage(sum, name string) string {
+	return filepath.Join(OciSandboxEntry(sum), name)
+}
+
+// OciSandboxExists returns whether the sandbox with the given sha sum exists in
+// the OciSandbox() cache
+func OciSandboxExists(sum, name string) (bool, error) {
+	_, err := os.Stat(OciSandboxImage(sum, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// LockOciSandbox applies an exclusive lock on the sandbox entry with the given
+// sha sum, it must be held while creating, referencing or removing the sandbox
+func LockOciSandbox(sum string) (int, error) {
+	return lockEntry(OciSandboxEntry(sum))
+}
+
+// AddReference adds a reference on the sandbox entry with the given sha sum,
+// the entry must be locked with LockOciSandbox. The reference is a shared lock
+// on a file of the entry. The lock file descriptor is deliberately opened
+// without O_CLOEXEC and never closed: it leaks into the starter and into the
+// container processes started from the sandbox, the lock is released by the
+// kernel when the last of them exits. The reference file itself is left in
+// place, it's only removed as a stale reference by the next call to References
+func AddReference(sum string) error {
+	dir := filepath.Join(OciSandboxEntry(sum), refsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("while creating references directory: %s", err)
+	}
+
+	f, err := ioutil.TempFile(dir, "ref-")
+	if err != nil {
+		return fmt.Errorf("while creating reference: %s", err)
+	}
+	f.Close()
+
+	if _, err := lock.Shared(f.Name()); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("while locking reference: %s", err)
+	}
+	return nil
+}
+
+// References returns the number of containers still using the sandbox entry
+// with the given sha sum, the entry must be locked with LockOciSandbox. Stale
+// references left by exited containers are removed
+func References(sum string) (int, error) {
+	dir := filepath.Join(OciSandboxEntry(sum), refsDir)
+	refs, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("while reading references: %s", err)
+	}
+
+	count := 0
+	for _, ref := range refs {
+		path := filepath.Join(dir, ref.Name())
+		fd, err := syscall.Open(path, os.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			continue
+		}
+		err = syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB)
+		syscall.Close(fd)
+		if err == syscall.EWOULDBLOCK {
+			count++
+			continue
+		}
+		sylog.Debugf("Removing stale reference %s", path)
+		os.Remove(path)
+	}
+	return count, nil
+}
+
+// CleanOciSandbox removes sandboxes of the OciSandbox() cache, sandboxes still
+// used by containers are kept
+func CleanOciSandbox() error {
+	entries, err := ioutil.ReadDir(OciSandbox())
+	if err != nil {
+		return fmt.Errorf("unable to open oci-sandbox cache: %s", err)
+	}
+
+	for _, e := range entries {
+		if err := removeOciSandbox(e.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOciSandbox removes the sandbox entry with the given sha sum if no
+// container is using it
+func removeOciSandbox(sum string) error {
+	fd, err := LockOciSandbox(sum)
+	if err != nil {
+		return fmt.Errorf("unable to lock oci-sandbox cache entry %s: %s", sum, err)
+	}
+	defer lock.Release(fd)
+
+	n, err := References(sum)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		sylog.Warningf("Not removing sandbox %s used by %d container(s)", sum, n)
+		return nil
+	}
+
+	dir := OciSandboxEntry(sum)
+	sylog.Debugf("Removing: %v", dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("unable to clean oci-sandbox cache: %s", err)
+	}
+	return nil
+}
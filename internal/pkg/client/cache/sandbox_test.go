@@ -0,0 +1,184 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+func TestOciSandbox(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{"Default OCI sandbox", "", filepath.Join(cacheDefault, "oci-sandbox")},
+		{"Custom OCI sandbox", cacheCustom, filepath.Join(cacheCustom, "oci-sandbox")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer Clean()
+			defer os.Unsetenv(DirEnv)
+
+			os.Setenv(DirEnv, tt.env)
+
+			if r := OciSandbox(); r != tt.expected {
+				t.Errorf("Unexpected result: %s (expected %s)", r, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	const sum = "0123456789abcdef"
+
+	image := OciSandboxImage(sum, "test_latest")
+	if err := os.MkdirAll(image, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := OciSandboxExists(sum, "test_latest"); err != nil || !exists {
+		t.Fatalf("sandbox not found: %v", err)
+	}
+
+	fd, err := LockOciSandbox(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddReference(sum); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := References(sum); err != nil || n != 1 {
+		t.Errorf("unexpected references count %d: %v", n, err)
+	}
+	lock.Release(fd)
+
+	// the reference is held by a child process
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	closeInherited(t, cmd.Process.Pid)
+
+	if err := Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(image); err != nil {
+		t.Errorf("referenced sandbox removed: %s", err)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if n, err := References(sum); err != nil || n != 0 {
+		t.Errorf("unexpected references count %d: %v", n, err)
+	}
+	if err := CleanOciSandbox(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(image); !os.IsNotExist(err) {
+		t.Errorf("unreferenced sandbox not removed: %v", err)
+	}
+}
+
+func TestReferenceDroppedOnExit(t *testing.T) {
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+	defer os.RemoveAll(cacheCustom)
+
+	const sum = "fedcba9876543210"
+
+	fd, err := LockOciSandbox(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release(fd)
+
+	if err := AddReference(sum); err != nil {
+		t.Fatal(err)
+	}
+
+	// the container process inherits the reference and exits normally
+	// once its standard input is closed
+	cmd := exec.Command("cat")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if n := closeInherited(t, cmd.Process.Pid); n != 1 {
+		t.Fatalf("reference inherited %d times by the container process", n)
+	}
+	if n, err := References(sum); err != nil || n != 1 {
+		t.Errorf("unexpected references count %d while container is running: %v", n, err)
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock is dropped but the reference file is only reaped
+	// by References
+	dir := filepath.Join(OciSandboxEntry(sum), refsDir)
+	refs, err := ioutil.ReadDir(dir)
+	if err != nil || len(refs) != 1 {
+		t.Fatalf("unexpected reference files %v: %v", refs, err)
+	}
+	if n, err := References(sum); err != nil || n != 0 {
+		t.Errorf("unexpected references count %d after container exit: %v", n, err)
+	}
+	if refs, err := ioutil.ReadDir(dir); err != nil || len(refs) != 0 {
+		t.Errorf("stale reference files not removed %v: %v", refs, err)
+	}
+}
+
+// closeInherited closes file descriptors of the current process which are
+// also opened by the process pid, leaving the child the only holder of
+// references, it returns the number of closed file descriptors
+func closeInherited(t *testing.T, pid int) int {
+	dir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	f, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil || fd <= 2 {
+			continue
+		}
+		link, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if filepath.Base(filepath.Dir(link)) == refsDir {
+			syscall.Close(fd)
+			n++
+		}
+	}
+	return n
+}
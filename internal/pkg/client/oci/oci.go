@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -131,6 +131,7 @@ func calculateRefHash(ref types.ImageReference, sys *types.SystemContext) (strin
 	if err != nil {
 		return "", err
 	}
+	defer source.Close()
 
 	man, _, err := source.GetManifest(context.TODO(), nil)
 	if err != nil {
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -180,29 +180,10 @@ func List(username string, name string) ([]*File, error) {
 			return nil, err
 		}
 		pattern := filepath.Join(path, name, name+".json")
-		files, err := filepath.Glob(pattern)
+		list, err = readFiles(pattern, list)
 		if err != nil {
 			return nil, err
 		}
-		for _, file := range files {
-			r, err := os.Open(file)
-			if os.IsNotExist(err) {
-				continue
-			}
-			if err != nil {
-				return nil, err
-			}
-			b, err := ioutil.ReadAll(r)
-			r.Close()
-			if err != nil {
-				return nil, err
-			}
-			f := &File{Path: file}
-			if err := json.Unmarshal(b, f); err != nil {
-				return nil, err
-			}
-			list = append(list, f)
-		}
 		privileged = !privileged
 		if privileged {
 			break
@@ -212,6 +193,41 @@ func List(username string, name string) ([]*File, error) {
 	return list, nil
 }
 
+// ListPrivileged returns privileged instance files of all
+// users matching name pattern
+func ListPrivileged(name string) ([]*File, error) {
+	pattern := filepath.Join(privPath, "*", name, name+".json")
+	return readFiles(pattern, make([]*File, 0))
+}
+
+// readFiles appends instance files matching pattern to list
+func readFiles(pattern string, list []*File) ([]*File, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		r, err := os.Open(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		f := &File{Path: file}
+		if err := json.Unmarshal(b, f); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
 // PrivilegedPath returns if instance file is stored in privileged path or not
 func (i *File) PrivilegedPath() bool {
 	return strings.HasPrefix(i.Path, privPath)
@@ -296,6 +312,22 @@ func (i *File) MountNamespaces() error {
 	return nil
 }
 
+// NamespacePath returns the path of an instance namespace, the path
+// is accessible as long as the instance is running
+func (i *File) NamespacePath(nstype specs.LinuxNamespaceType) (string, error) {
+	ns, ok := nsMap[nstype]
+	if !ok {
+		return "", fmt.Errorf("unknown namespace type %s", nstype)
+	}
+	path := filepath.Join(filepath.Dir(i.Path), "ns")
+	nspath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	nsBase := filepath.Join(fmt.Sprintf("/proc/%d/root", i.PPid), nspath)
+	return filepath.Join(nsBase, ns), nil
+}
+
 // UpdateNamespacesPath updates namespaces path for the provided configuration
 func (i *File) UpdateNamespacesPath(configNs []specs.LinuxNamespace) error {
 	path := filepath.Join(filepath.Dir(i.Path), "ns")
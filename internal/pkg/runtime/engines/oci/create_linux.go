@@ -465,6 +465,12 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 	return nil
 }
 
+// isDenyAllDevices returns true if d is the default rule denying access
+// to all devices
+func isDenyAllDevices(d specs.LinuxDeviceCgroup) bool {
+	return !d.Allow && (d.Type == "" || d.Type == "a") && d.Major == nil && d.Minor == nil && d.Access == "rwm"
+}
+
 func (c *container) addCgroups(pid int, system *mount.System) error {
 	name := c.engine.CommonConfig.ContainerID
 	cgroupsPath := c.engine.EngineConfig.OciConfig.Linux.CgroupsPath
@@ -479,7 +485,26 @@ func (c *container) addCgroups(pid int, system *mount.System) error {
 
 	manager := &cgroups.Manager{Path: cgroupsPath, Pid: pid}
 
-	if err := manager.ApplyFromSpec(c.engine.EngineConfig.OciConfig.Linux.Resources); err != nil {
+	resources := c.engine.EngineConfig.OciConfig.Linux.Resources
+	unified := cgroups.IsUnified()
+
+	// cgroups v2 device controller requires eBPF programs not supported,
+	// the default deny-all rule is skipped as device access is then only
+	// restricted by the devices created in container, any other rule is
+	// refused by the cgroups manager
+	if unified && resources != nil {
+		devices := resources.Devices[:0]
+		for _, d := range resources.Devices {
+			if isDenyAllDevices(d) {
+				sylog.Warningf("Ignoring default deny-all device rule not supported by cgroups v2, device access is not restricted by cgroups")
+				continue
+			}
+			devices = append(devices, d)
+		}
+		resources.Devices = devices
+	}
+
+	if err := manager.ApplyFromSpec(resources); err != nil {
 		return fmt.Errorf("Failed to apply cgroups ressources restriction: %s", err)
 	}
 
@@ -498,6 +523,10 @@ func (c *container) addCgroups(pid int, system *mount.System) error {
 		flags, opt := mount.ConvertOptions(m.Options)
 		options := strings.Join(opt, ",")
 
+		if unified {
+			return c.addUnifiedCgroupMount(pid, m.Destination, cgroupRootPath, flags, system, manager)
+		}
+
 		readOnly := false
 		if flags&syscall.MS_RDONLY != 0 {
 			readOnly = true
@@ -588,6 +617,44 @@ func (c *container) addCgroups(pid int, system *mount.System) error {
 	return nil
 }
 
+// addUnifiedCgroupMount binds the container cgroup from cgroups v2
+// unified hierarchy at destination
+func (c *container) addUnifiedCgroupMount(pid int, dest string, root string, flags uintptr, system *mount.System, manager *cgroups.Manager) error {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	source := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		cgroupLine := strings.SplitN(scanner.Text(), ":", 3)
+		if len(cgroupLine) == 3 && cgroupLine[0] == "0" && cgroupLine[1] == "" {
+			source = filepath.Join(root, cgroupLine[2])
+			break
+		}
+	}
+	if source == "" {
+		return fmt.Errorf("failed to determine unified cgroup path of process %d", pid)
+	}
+
+	flags |= uintptr(syscall.MS_BIND)
+	if err := system.Points.AddBind(mount.OtherTag, source, dest, flags); err != nil {
+		return err
+	}
+	if flags&syscall.MS_RDONLY != 0 {
+		if err := system.Points.AddRemount(mount.OtherTag, dest, flags); err != nil {
+			return err
+		}
+	}
+
+	c.engine.EngineConfig.Cgroups = manager
+
+	return nil
+}
+
 func (c *container) addAllPaths(system *mount.System) error {
 	// add masked path
 	if err := c.addMaskedPathsMount(system); err != nil {
@@ -786,7 +853,10 @@ func (c *container) addDevices(system *mount.System) error {
 			c.engine.EngineConfig.OciConfig.Linux.Resources = &specs.LinuxResources{}
 		}
 
-		c.engine.EngineConfig.OciConfig.Linux.Resources.Devices = append(c.engine.EngineConfig.OciConfig.Linux.Resources.Devices, cgroupDevices...)
+		// the default devices are implicitly allowed with cgroups v2
+		if !cgroups.IsUnified() {
+			c.engine.EngineConfig.OciConfig.Linux.Resources.Devices = append(c.engine.EngineConfig.OciConfig.Linux.Resources.Devices, cgroupDevices...)
+		}
 	}
 
 	return nil
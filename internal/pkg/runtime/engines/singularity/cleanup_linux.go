@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,14 +6,19 @@
 package singularity
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"syscall"
 
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
 
 	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	singularityConfig "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/config"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
 )
 
 /*
@@ -44,6 +49,13 @@ func (engine *EngineOperations) CleanupContainer(fatal error, status syscall.Wai
 		}
 	}
 
+	if pid := engine.EngineConfig.FuseOverlayPid; pid > 0 {
+		sylog.Debugf("Stopping fuse-overlayfs process %d", pid)
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			sylog.Errorf("failed to stop fuse-overlayfs process %d: %s", pid, err)
+		}
+	}
+
 	if engine.EngineConfig.GetInstance() {
 		uid := os.Getuid()
 
@@ -66,6 +78,12 @@ func (engine *EngineOperations) CleanupContainer(fatal error, status syscall.Wai
 				}
 				defer syscall.Setresuid(uid, uid, 0)
 
+				// networks are connected by root to privileged instances
+				// only, whoever started the instance
+				if err := engine.cleanupConnectedNetworks(file); err != nil {
+					sylog.Errorf("%s", err)
+				}
+
 				if err = file.Delete(); err != nil {
 					return
 				}
@@ -77,3 +95,55 @@ func (engine *EngineOperations) CleanupContainer(fatal error, status syscall.Wai
 
 	return nil
 }
+
+// cleanupConnectedNetworks tears down networks connected to
+// the instance with singularity network connect
+func (engine *EngineOperations) cleanupConnectedNetworks(file *instance.File) error {
+	engineConfig := singularityConfig.NewConfig()
+	instanceConfig := &config.Common{
+		EngineConfig: engineConfig,
+	}
+	if err := json.Unmarshal(file.Config, instanceConfig); err != nil {
+		return err
+	}
+
+	connected := engineConfig.GetConnectedNetworks()
+	if len(connected) == 0 {
+		return nil
+	}
+
+	networks := make([]string, 0, len(connected))
+	for name := range connected {
+		networks = append(networks, name)
+	}
+
+	// network namespace is gone, plugins only release resources
+	// allocated on host like IP addresses or port mappings
+	setup, err := network.NewSetup(networks, strconv.Itoa(file.Pid), "", engine.cniPath())
+	if err != nil {
+		return err
+	}
+	for name, ifname := range connected {
+		if err := setup.SetInterfaceName(name, ifname); err != nil {
+			return err
+		}
+	}
+	setup.SetEnvPath("/bin:/sbin:/usr/bin:/usr/sbin")
+
+	return setup.DelNetworks()
+}
+
+// cniPath returns CNI configuration and plugins paths
+func (engine *EngineOperations) cniPath() *network.CNIPath {
+	cniPath := &network.CNIPath{
+		Conf:   defaultCNIConfPath,
+		Plugin: defaultCNIPluginPath,
+	}
+	if engine.EngineConfig.File.CniConfPath != "" {
+		cniPath.Conf = engine.EngineConfig.File.CniConfPath
+	}
+	if engine.EngineConfig.File.CniPluginPath != "" {
+		cniPath.Plugin = engine.EngineConfig.File.CniPluginPath
+	}
+	return cniPath
+}
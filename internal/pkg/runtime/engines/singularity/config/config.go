@@ -6,6 +6,7 @@
 package singularity
 
 import (
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
 	"github.com/sylabs/singularity/pkg/image"
 )
@@ -36,6 +37,7 @@ type FileConfig struct {
 	SharedLoopDevices       bool     `default:"no" authorized:"yes,no" directive:"shared loop devices"`
 	MaxLoopDevices          uint     `default:"256" directive:"max loop devices"`
 	SessiondirMaxSize       uint     `default:"16" directive:"sessiondir max size"`
+	CacheMaxAge             uint     `default:"0" directive:"cache max age"`
 	MountDev                string   `default:"yes" authorized:"yes,no,minimal" directive:"mount dev"`
 	EnableOverlay           string   `default:"try" authorized:"yes,no,try" directive:"enable overlay"`
 	BindPath                []string `default:"/etc/localtime,/etc/hosts" directive:"bind path"`
@@ -47,50 +49,61 @@ type FileConfig struct {
 	MemoryFSType            string   `default:"tmpfs" authorized:"tmpfs,ramfs" directive:"memory fs type"`
 	CniConfPath             string   `directive:"cni configuration path"`
 	CniPluginPath           string   `directive:"cni plugin path"`
+	UserResourceLimits      []string `directive:"user resource limits"`
+	UserCgroupsPath         string   `directive:"user cgroups path"`
 	MksquashfsPath          string   `directive:"mksquashfs path"`
+	CryptsetupPath          string   `directive:"cryptsetup path"`
+	CacheMaxSize            string   `default:"0" directive:"cache max size"`
 }
 
 // JSONConfig stores engine specific confguration that is allowed to be set by the user
 type JSONConfig struct {
-	WritableImage bool          `json:"writableImage,omitempty"`
-	WritableTmpfs bool          `json:"writableTmpfs,omitempty"`
-	Contain       bool          `json:"container,omitempty"`
-	Nv            bool          `json:"nv,omitempty"`
-	CustomHome    bool          `json:"customHome,omitempty"`
-	Instance      bool          `json:"instance,omitempty"`
-	InstanceJoin  bool          `json:"instanceJoin,omitempty"`
-	BootInstance  bool          `json:"bootInstance,omitempty"`
-	RunPrivileged bool          `json:"runPrivileged,omitempty"`
-	AllowSUID     bool          `json:"allowSUID,omitempty"`
-	KeepPrivs     bool          `json:"keepPrivs,omitempty"`
-	NoPrivs       bool          `json:"noPrivs,omitempty"`
-	NoHome        bool          `json:"noHome,omitempty"`
-	NoInit        bool          `json:"noInit,omitempty"`
-	DeleteImage   bool          `json:"deleteImage,omitempty"`
-	Image         string        `json:"image"`
-	OverlayImage  []string      `json:"overlayImage,omitempty"`
-	Workdir       string        `json:"workdir,omitempty"`
-	ScratchDir    []string      `json:"scratchdir,omitempty"`
-	HomeSource    string        `json:"homedir,omitempty"`
-	HomeDest      string        `json:"homeDest,omitempty"`
-	BindPath      []string      `json:"bindpath,omitempty"`
-	Command       string        `json:"command,omitempty"`
-	Shell         string        `json:"shell,omitempty"`
-	TmpDir        string        `json:"tmpdir,omitempty"`
-	AddCaps       string        `json:"addCaps,omitempty"`
-	DropCaps      string        `json:"dropCaps,omitempty"`
-	Hostname      string        `json:"hostname,omitempty"`
-	ImageList     []image.Image `json:"imageList,omitempty"`
-	Network       string        `json:"network,omitempty"`
-	NetworkArgs   []string      `json:"networkArgs,omitempty"`
-	DNS           string        `json:"dns,omitempty"`
-	Cwd           string        `json:"cwd,omitempty"`
-	Security      []string      `json:"security,omitempty"`
-	OpenFd        []int         `json:"openFd,omitempty"`
-	CgroupsPath   string        `json:"cgroupsPath,omitempty"`
-	TargetUID     int           `json:"targetUID,omitempty"`
-	TargetGID     []int         `json:"targetGID,omitempty"`
-	LibrariesPath []string      `json:"librariesPath,omitempty"`
+	WritableImage bool                  `json:"writableImage,omitempty"`
+	WritableTmpfs bool                  `json:"writableTmpfs,omitempty"`
+	Contain       bool                  `json:"container,omitempty"`
+	Nv            bool                  `json:"nv,omitempty"`
+	CustomHome    bool                  `json:"customHome,omitempty"`
+	Instance      bool                  `json:"instance,omitempty"`
+	InstanceJoin  bool                  `json:"instanceJoin,omitempty"`
+	BootInstance  bool                  `json:"bootInstance,omitempty"`
+	RunPrivileged bool                  `json:"runPrivileged,omitempty"`
+	AllowSUID     bool                  `json:"allowSUID,omitempty"`
+	KeepPrivs     bool                  `json:"keepPrivs,omitempty"`
+	NoPrivs       bool                  `json:"noPrivs,omitempty"`
+	NoHome        bool                  `json:"noHome,omitempty"`
+	NoInit        bool                  `json:"noInit,omitempty"`
+	DeleteImage   bool                  `json:"deleteImage,omitempty"`
+	Image         string                `json:"image"`
+	ImageSource   string                `json:"imageSource,omitempty"`
+	ImageDigest   string                `json:"imageDigest,omitempty"`
+	OverlayImage  []string              `json:"overlayImage,omitempty"`
+	Workdir       string                `json:"workdir,omitempty"`
+	ScratchDir    []string              `json:"scratchdir,omitempty"`
+	HomeSource    string                `json:"homedir,omitempty"`
+	HomeDest      string                `json:"homeDest,omitempty"`
+	BindPath      []string              `json:"bindpath,omitempty"`
+	Command       string                `json:"command,omitempty"`
+	Shell         string                `json:"shell,omitempty"`
+	TmpDir        string                `json:"tmpdir,omitempty"`
+	AddCaps       string                `json:"addCaps,omitempty"`
+	DropCaps      string                `json:"dropCaps,omitempty"`
+	Hostname      string                `json:"hostname,omitempty"`
+	ImageList     []image.Image         `json:"imageList,omitempty"`
+	Network       string                `json:"network,omitempty"`
+	NetworkArgs   []string              `json:"networkArgs,omitempty"`
+	NetworkPorts  []string              `json:"networkPorts,omitempty"`
+	Connected     map[string]string     `json:"connectedNetworks,omitempty"`
+	DNS           string                `json:"dns,omitempty"`
+	Cwd           string                `json:"cwd,omitempty"`
+	Security      []string              `json:"security,omitempty"`
+	OpenFd        []int                 `json:"openFd,omitempty"`
+	OverlayFd     map[string][]int      `json:"overlayFd,omitempty"`
+	CgroupsPath   string                `json:"cgroupsPath,omitempty"`
+	Resources     *specs.LinuxResources `json:"resources,omitempty"`
+	TargetUID     int                   `json:"targetUID,omitempty"`
+	TargetGID     []int                 `json:"targetGID,omitempty"`
+	LibrariesPath []string              `json:"librariesPath,omitempty"`
+	EncryptionKey []byte                `json:"encryptionKey,omitempty"`
 }
 
 // NewConfig returns singularity.EngineConfig with a parsed FileConfig
@@ -114,6 +127,30 @@ func (e *EngineConfig) GetImage() string {
 	return e.JSON.Image
 }
 
+// SetImageSource sets the docker or OCI source the container image was
+// converted from.
+func (e *EngineConfig) SetImageSource(source string) {
+	e.JSON.ImageSource = source
+}
+
+// GetImageSource retrieves the docker or OCI source the container image was
+// converted from.
+func (e *EngineConfig) GetImageSource() string {
+	return e.JSON.ImageSource
+}
+
+// SetImageDigest sets the manifest digest of the docker or OCI source of
+// the container image.
+func (e *EngineConfig) SetImageDigest(digest string) {
+	e.JSON.ImageDigest = digest
+}
+
+// GetImageDigest retrieves the manifest digest of the docker or OCI source
+// of the container image.
+func (e *EngineConfig) GetImageDigest() string {
+	return e.JSON.ImageDigest
+}
+
 // SetWritableImage defines the container image as writable or not.
 func (e *EngineConfig) SetWritableImage(writable bool) {
 	e.JSON.WritableImage = writable
@@ -374,6 +411,28 @@ func (e *EngineConfig) GetNetworkArgs() []string {
 	return e.JSON.NetworkArgs
 }
 
+// SetNetworkPorts sets ports to publish on container networks
+func (e *EngineConfig) SetNetworkPorts(ports []string) {
+	e.JSON.NetworkPorts = ports
+}
+
+// GetNetworkPorts retrieves ports published on container networks
+func (e *EngineConfig) GetNetworkPorts() []string {
+	return e.JSON.NetworkPorts
+}
+
+// SetConnectedNetworks sets networks connected to a running instance
+// with their container interface name
+func (e *EngineConfig) SetConnectedNetworks(networks map[string]string) {
+	e.JSON.Connected = networks
+}
+
+// GetConnectedNetworks retrieves networks connected to a running instance
+// with their container interface name
+func (e *EngineConfig) GetConnectedNetworks() map[string]string {
+	return e.JSON.Connected
+}
+
 // SetDNS sets a commas separated list of DNS servers to add in resolv.conf
 func (e *EngineConfig) SetDNS(dns string) {
 	e.JSON.DNS = dns
@@ -414,6 +473,21 @@ func (e *EngineConfig) GetOpenFd() []int {
 	return e.JSON.OpenFd
 }
 
+// SetOverlayFd sets the file descriptors of the upper and work
+// directories opened for the overlay directory path
+func (e *EngineConfig) SetOverlayFd(path string, fds []int) {
+	if e.JSON.OverlayFd == nil {
+		e.JSON.OverlayFd = make(map[string][]int)
+	}
+	e.JSON.OverlayFd[path] = fds
+}
+
+// GetOverlayFd returns the file descriptors of the upper and work
+// directories opened for the overlay directory path
+func (e *EngineConfig) GetOverlayFd(path string) []int {
+	return e.JSON.OverlayFd[path]
+}
+
 // SetWritableTmpfs sets writable tmpfs flag
 func (e *EngineConfig) SetWritableTmpfs(writable bool) {
 	e.JSON.WritableTmpfs = writable
@@ -444,6 +518,16 @@ func (e *EngineConfig) GetCgroupsPath() string {
 	return e.JSON.CgroupsPath
 }
 
+// SetResources sets cgroups resources restriction
+func (e *EngineConfig) SetResources(resources *specs.LinuxResources) {
+	e.JSON.Resources = resources
+}
+
+// GetResources returns cgroups resources restriction
+func (e *EngineConfig) GetResources() *specs.LinuxResources {
+	return e.JSON.Resources
+}
+
 // SetTargetUID sets target UID to execute the container process as user ID
 func (e *EngineConfig) SetTargetUID(uid int) {
 	e.JSON.TargetUID = uid
@@ -485,3 +569,13 @@ func (e *EngineConfig) GetDeleteImage() bool {
 func (e *EngineConfig) SetDeleteImage(delete bool) {
 	e.JSON.DeleteImage = delete
 }
+
+// SetEncryptionKey sets the key used to unlock an encrypted root filesystem
+func (e *EngineConfig) SetEncryptionKey(key []byte) {
+	e.JSON.EncryptionKey = key
+}
+
+// GetEncryptionKey returns the key used to unlock an encrypted root filesystem
+func (e *EngineConfig) GetEncryptionKey() []byte {
+	return e.JSON.EncryptionKey
+}
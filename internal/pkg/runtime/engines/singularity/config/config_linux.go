@@ -18,4 +18,7 @@ type EngineConfig struct {
 	File      *FileConfig      `json:"-"`
 	Network   *network.Setup   `json:"-"`
 	Cgroups   *cgroups.Manager `json:"-"`
+	// FuseOverlayPid is the PID of fuse-overlayfs process
+	// to stop during cleanup
+	FuseOverlayPid int `json:"-"`
 }
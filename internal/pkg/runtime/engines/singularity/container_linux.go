@@ -28,6 +28,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/network"
+	"github.com/sylabs/singularity/pkg/util/crypt"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
 	"github.com/sylabs/singularity/pkg/util/loop"
 	"github.com/sylabs/singularity/pkg/util/nvidia"
@@ -45,6 +46,7 @@ type container struct {
 	rpcOps           *client.RPC
 	session          *layout.Session
 	sessionLayerType string
+	overlayBackend   overlay.Backend
 	sessionFsType    string
 	sessionSize      int
 	userNS           bool
@@ -66,6 +68,7 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 		engine:           engine,
 		rpcOps:           rpcOps,
 		sessionLayerType: "none",
+		overlayBackend:   overlay.KernelBackend,
 		sessionFsType:    engine.EngineConfig.File.MemoryFSType,
 		mountInfoPath:    fmt.Sprintf("/proc/%d/mountinfo", pid),
 		skippedMount:     make([]string, 0),
@@ -180,20 +183,7 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 			nspath := fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f)
 			networks := strings.Split(engine.EngineConfig.GetNetwork(), ",")
 
-			cniPath := &network.CNIPath{}
-
-			if engine.EngineConfig.File.CniConfPath != "" {
-				cniPath.Conf = engine.EngineConfig.File.CniConfPath
-			} else {
-				cniPath.Conf = defaultCNIConfPath
-			}
-			if engine.EngineConfig.File.CniPluginPath != "" {
-				cniPath.Plugin = engine.EngineConfig.File.CniPluginPath
-			} else {
-				cniPath.Plugin = defaultCNIPluginPath
-			}
-
-			setup, err := network.NewSetup(networks, strconv.Itoa(pid), nspath, cniPath)
+			setup, err := network.NewSetup(networks, strconv.Itoa(pid), nspath, engine.cniPath())
 			if err != nil {
 				return fmt.Errorf("%s", err)
 			}
@@ -201,6 +191,18 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 			if err := setup.SetArgs(netargs); err != nil {
 				return fmt.Errorf("%s", err)
 			}
+			if ports := engine.EngineConfig.GetNetworkPorts(); len(ports) > 0 {
+				portMaps := make([]*network.PortMapEntry, len(ports))
+				for i, p := range ports {
+					portMaps[i], err = network.ParsePortMap(p)
+					if err != nil {
+						return fmt.Errorf("%s", err)
+					}
+				}
+				if err := setup.SetPortMaps(portMaps); err != nil {
+					return fmt.Errorf("%s", err)
+				}
+			}
 
 			setup.SetEnvPath("/bin:/sbin:/usr/bin:/usr/sbin")
 
@@ -214,16 +216,32 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 		}
 	}
 
-	if os.Geteuid() == 0 {
-		path := engine.EngineConfig.GetCgroupsPath()
-		if path != "" {
-			cgroupPath := filepath.Join("/singularity", strconv.Itoa(pid))
-			manager := &cgroups.Manager{Pid: pid, Path: cgroupPath}
-			if err := manager.ApplyFromFile(path); err != nil {
-				return fmt.Errorf("Failed to apply cgroups ressources restriction: %s", err)
+	path := engine.EngineConfig.GetCgroupsPath()
+	resources := engine.EngineConfig.GetResources()
+	if path != "" || resources != nil {
+		var err error
+
+		cgroupPath := filepath.Join("/singularity", strconv.Itoa(pid))
+		if os.Geteuid() != 0 {
+			// unprivileged users create the container cgroup
+			// in the subtree delegated to them
+			cgroupPath, err = cgroups.UserPath(os.Getuid(), pid, engine.EngineConfig.File.UserCgroupsPath)
+			if err != nil {
+				return fmt.Errorf("Failed to find a cgroup delegated to user: %s", err)
 			}
-			engine.EngineConfig.Cgroups = manager
+			sylog.Debugf("Using user cgroup %s", cgroupPath)
+		}
+
+		manager := &cgroups.Manager{Pid: pid, Path: cgroupPath}
+		if resources != nil {
+			err = manager.ApplyFromSpec(resources)
+		} else {
+			err = manager.ApplyFromFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to apply cgroups ressources restriction: %s", err)
 		}
+		engine.EngineConfig.Cgroups = manager
 	}
 
 	sylog.Debugf("Chdir into / to avoid errors\n")
@@ -275,11 +293,15 @@ func (c *container) setupSessionLayout(system *mount.System) error {
 		return fmt.Errorf("failed to resolved session directory %s: %s", buildcfg.SESSIONDIR, err)
 	}
 
-	if enabled, _ := proc.HasFilesystem("overlay"); enabled && !c.userNS {
-		switch c.engine.EngineConfig.File.EnableOverlay {
-		case "yes", "try":
-			overlayEnabled = true
+	switch c.engine.EngineConfig.File.EnableOverlay {
+	case "yes", "try":
+		backend, err := overlay.DetectBackend(c.userNS)
+		if err != nil {
+			sylog.Debugf("Overlay layer not available: %s", err)
+			break
 		}
+		c.overlayBackend = backend
+		overlayEnabled = true
 	}
 
 	imgObject, err := c.loadImage(c.engine.EngineConfig.GetImage(), true)
@@ -300,7 +322,7 @@ func (c *container) setupSessionLayout(system *mount.System) error {
 	}
 
 	if overlayEnabled {
-		sylog.Debugf("Attempting to use overlayfs (enable overlay = %v)\n", c.engine.EngineConfig.File.EnableOverlay)
+		sylog.Debugf("Attempting to use overlayfs with %s backend (enable overlay = %v)\n", c.overlayBackend, c.engine.EngineConfig.File.EnableOverlay)
 		if imgObject.Type == image.SIF {
 			err = c.setupSIFOverlay(imgObject, c.engine.EngineConfig.GetWritableImage())
 			if err == nil {
@@ -327,7 +349,7 @@ func (c *container) setupSessionLayout(system *mount.System) error {
 // setupOverlayLayout sets up the session with overlay filesystem
 func (c *container) setupOverlayLayout(system *mount.System, sessionPath string) (err error) {
 	sylog.Debugf("Creating overlay SESSIONDIR layout\n")
-	if c.session, err = layout.NewSession(sessionPath, c.sessionFsType, c.sessionSize, system, overlay.New()); err != nil {
+	if c.session, err = layout.NewSession(sessionPath, c.sessionFsType, c.sessionSize, system, overlay.NewWithBackend(c.overlayBackend)); err != nil {
 		return err
 	}
 
@@ -513,9 +535,17 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 			source = "."
 		}
 
-		// overlay requires root filesystem UID/GID since upper/work
-		// directories are owned by root
 		if mnt.Type == "overlay" {
+			switch {
+			case c.overlayBackend == overlay.FuseBackend:
+				return c.mountFuseOverlay(dest, optsString)
+			case c.userNS:
+				// trusted xattrs are not available to unprivileged overlay mounts
+				optsString += ",userxattr"
+			}
+
+			// overlay requires root filesystem UID/GID since upper/work
+			// directories are owned by root
 			c.rpcOps.SetFsID(0, 0)
 			defer c.rpcOps.SetFsID(os.Getuid(), os.Getgid())
 		}
@@ -524,6 +554,27 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 	return err
 }
 
+// mountFuseOverlay mounts overlay layer to dest with fuse-overlayfs
+func (c *container) mountFuseOverlay(dest string, options string) error {
+	program, err := overlay.FuseOverlayfsPath()
+	if err != nil {
+		return err
+	}
+
+	sylog.Debugf("Mounting overlay to %s with %s\n", dest, program)
+	pid, err := c.rpcOps.FuseOverlay(program, dest, options)
+	if err != nil {
+		return err
+	}
+
+	// without PID namespace, fuse-overlayfs won't be killed with
+	// container processes and must be stopped during cleanup
+	if !c.pidNS {
+		c.engine.EngineConfig.FuseOverlayPid = pid
+	}
+	return nil
+}
+
 // mount image via loop
 func (c *container) mountImage(mnt *mount.Point) error {
 	maxDevices := int(c.engine.EngineConfig.File.MaxLoopDevices)
@@ -561,15 +612,55 @@ func (c *container) mountImage(mnt *mount.Point) error {
 	}
 
 	path := fmt.Sprintf("/dev/loop%d", number)
+	mountType := mnt.Type
+
+	if mountType == "encryptfs" {
+		cryptsetup, err := crypt.FindCryptsetup(c.engine.EngineConfig.File.CryptsetupPath)
+		if err != nil {
+			return err
+		}
+		cryptDev, err := c.openCryptDevice(cryptsetup, path)
+		if err != nil {
+			return err
+		}
+		// schedule removal once mounted, the device is busy until
+		// unmounted or removed right away if the mount fails
+		defer func() {
+			if err := c.rpcOps.CryptClose(cryptsetup, cryptDev); err != nil {
+				sylog.Warningf("failed to schedule removal of %s: %s", cryptDev, err)
+			}
+		}()
+		path = cryptDev
+		mountType = "squashfs"
+	}
+
 	sylog.Debugf("Mounting loop device %s to %s\n", path, mnt.Destination)
-	_, err = c.rpcOps.Mount(path, mnt.Destination, mnt.Type, flags, optsString)
+	_, err = c.rpcOps.Mount(path, mnt.Destination, mountType, flags, optsString)
 	if err != nil {
-		return fmt.Errorf("failed to mount %s filesystem: %s", mnt.Type, err)
+		return fmt.Errorf("failed to mount %s filesystem: %s", mountType, err)
 	}
 
 	return nil
 }
 
+// openCryptDevice unlocks the encrypted loop device with cryptsetup
+// and returns the path of the device-mapper device
+func (c *container) openCryptDevice(cryptsetup string, loopDev string) (string, error) {
+	key := c.engine.EngineConfig.GetEncryptionKey()
+	if len(key) == 0 {
+		return "", fmt.Errorf("encrypted container requires --passphrase or --pem-path")
+	}
+	// key isn't needed anymore and must not be stored in instance file
+	c.engine.EngineConfig.SetEncryptionKey(nil)
+
+	sylog.Debugf("Unlocking encrypted loop device %s", loopDev)
+	cryptDev, err := c.rpcOps.CryptOpen(cryptsetup, loopDev, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to unlock encrypted container: %s", err)
+	}
+	return cryptDev, nil
+}
+
 func (c *container) loadImage(path string, rootfs bool) (*image.Image, error) {
 	list := c.engine.EngineConfig.GetImageList()
 
@@ -633,6 +724,11 @@ func (c *container) addRootfsMount(system *mount.System) error {
 		mountType = "squashfs"
 	case image.EXT3:
 		mountType = "ext3"
+	case image.ENCRYPTSQUASHFS:
+		if c.userNS {
+			return fmt.Errorf("encrypted container requires setuid mode or root privileges")
+		}
+		mountType = "encryptfs"
 	case image.SANDBOX:
 		sylog.Debugf("Mounting directory rootfs: %v\n", rootfs)
 		flags |= syscall.MS_BIND
@@ -699,6 +795,7 @@ func (c *container) addOverlayMount(system *mount.System) error {
 	nb := 0
 	ov := c.session.Layer.(*overlay.Overlay)
 	hasUpper := false
+	openedUpper := false
 
 	if c.engine.EngineConfig.GetWritableTmpfs() {
 		sylog.Debugf("Setup writable tmpfs overlay")
@@ -736,6 +833,8 @@ func (c *container) addOverlayMount(system *mount.System) error {
 	}
 
 	for _, img := range c.engine.EngineConfig.GetOverlayImage() {
+		var upperFd []int
+
 		splitted := strings.SplitN(img, ":", 2)
 
 		imageObject, err := c.loadImage(splitted[0], false)
@@ -775,19 +874,31 @@ func (c *container) addOverlayMount(system *mount.System) error {
 			}
 			ov.AddLowerDir(dst)
 		case image.SANDBOX:
-			if os.Geteuid() != 0 {
-				return fmt.Errorf("only root user can use sandbox as overlay")
+			// within user namespace the overlay is mounted unprivileged
+			// and access to the directory is checked against the user,
+			// in setuid mode the directory and its upper and work
+			// directories checked and opened during stage 1 are mounted
+			dir := imageObject.Path
+			if os.Geteuid() != 0 && !c.userNS {
+				dir = imageObject.Source
+				if imageObject.Writable {
+					fds := c.engine.EngineConfig.GetOverlayFd(imageObject.Path)
+					if len(fds) != 2 {
+						return fmt.Errorf("no upper and work directories opened for overlay %s", splitted[0])
+					}
+					upperFd = fds
+				}
 			}
 
 			flags := uintptr(c.suidFlag | syscall.MS_NODEV)
-			err = system.Points.AddBind(mount.PreLayerTag, imageObject.Path, dst, flags)
+			err = system.Points.AddBind(mount.PreLayerTag, dir, dst, flags)
 			if err != nil {
 				return fmt.Errorf("while adding sandbox image: %s", err)
 			}
 			system.Points.AddRemount(mount.PreLayerTag, dst, flags)
 
 			if !imageObject.Writable {
-				if fs.IsDir(filepath.Join(imageObject.Path, "upper")) {
+				if fs.IsDir(filepath.Join(dir, "upper")) {
 					ov.AddLowerDir(filepath.Join(dst, "upper"))
 				} else {
 					ov.AddLowerDir(dst)
@@ -805,6 +916,11 @@ func (c *container) addOverlayMount(system *mount.System) error {
 		if imageObject.Writable && !hasUpper {
 			upper := filepath.Join(dst, "upper")
 			work := filepath.Join(dst, "work")
+			if upperFd != nil {
+				upper = fmt.Sprintf("/proc/self/fd/%d", upperFd[0])
+				work = fmt.Sprintf("/proc/self/fd/%d", upperFd[1])
+				openedUpper = true
+			}
 
 			if err := ov.SetUpperDir(upper); err != nil {
 				return fmt.Errorf("failed to add overlay upper: %s", err)
@@ -817,7 +933,9 @@ func (c *container) addOverlayMount(system *mount.System) error {
 		}
 	}
 
-	if hasUpper {
+	// upper and work directories opened during stage 1 were already
+	// checked and created with user ownership
+	if hasUpper && !openedUpper {
 		if err := system.RunAfterTag(mount.PreLayerTag, c.overlayUpperWork); err != nil {
 			return err
 		}
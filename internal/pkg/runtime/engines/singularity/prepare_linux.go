@@ -11,9 +11,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/cgroups"
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/starter"
@@ -23,10 +25,13 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/syecl"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/layout/layer/overlay"
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/sypgp"
 	"github.com/sylabs/singularity/pkg/util/capabilities"
+	"golang.org/x/crypto/openpgp"
 )
 
 // prepareUserCaps is responsible for checking that user's requested
@@ -202,6 +207,32 @@ func (e *EngineOperations) prepareRootCaps() error {
 	return nil
 }
 
+// prepareResourceLimits checks that cgroups resources restriction
+// requested by user are authorized by configuration, without SUID
+// the kernel enforces the restrictions delegated to the user
+func (e *EngineOperations) prepareResourceLimits(starterConfig *starter.Config) error {
+	resources := e.EngineConfig.GetResources()
+	if resources == nil || os.Getuid() == 0 || !starterConfig.GetIsSUID() {
+		return nil
+	}
+
+	for _, controller := range cgroups.Controllers(resources) {
+		found := false
+		for _, c := range e.EngineConfig.File.UserResourceLimits {
+			if c == controller {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s resource limits are not authorized by configuration", controller)
+		}
+		sylog.Debugf("User %s resource limits authorized", controller)
+	}
+
+	return nil
+}
+
 func (e *EngineOperations) prepareFd() {
 	fds := make([]int, 0)
 
@@ -289,6 +320,10 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		}
 	}
 
+	if err := e.prepareResourceLimits(starterConfig); err != nil {
+		return err
+	}
+
 	if e.EngineConfig.File.MountSlave {
 		starterConfig.SetMountPropagation("rslave")
 	} else {
@@ -465,6 +500,18 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		if !fs.IsOwner(buildcfg.ECL_FILE, 0) {
 			return fmt.Errorf("%s must be owned by root", buildcfg.ECL_FILE)
 		}
+		// check for ownership of the global keyring used by the ECL
+		if fs.IsFile(buildcfg.GLOBAL_KEYRING) && !fs.IsOwner(buildcfg.GLOBAL_KEYRING, 0) {
+			return fmt.Errorf("%s must be owned by root", buildcfg.GLOBAL_KEYRING)
+		}
+		// check for ownership of the CA bundles used by the ECL
+		if ecl, err := syecl.LoadConfig(buildcfg.ECL_FILE); err == nil {
+			for _, path := range ecl.CABundles() {
+				if !fs.IsOwner(path, 0) {
+					return fmt.Errorf("%s must be owned by root", path)
+				}
+			}
+		}
 	}
 
 	// Save the current working directory to restore it in stage 2
@@ -500,7 +547,7 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		if err := e.prepareContainerConfig(starterConfig); err != nil {
 			return err
 		}
-		if err := e.loadImages(); err != nil {
+		if err := e.loadImages(starterConfig); err != nil {
 			return err
 		}
 	}
@@ -519,7 +566,7 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	return nil
 }
 
-func (e *EngineOperations) loadImages() error {
+func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 	images := make([]image.Image, 0)
 
 	// load rootfs image
@@ -550,18 +597,25 @@ func (e *EngineOperations) loadImages() error {
 			return fmt.Errorf("path mismatch for sandbox %s != %s", cwd, img.Path)
 		}
 	}
-	if img.Type == image.SIF {
-		// query the ECL module, proceed if an ecl config file is found
-		ecl, err := syecl.LoadConfig(buildcfg.ECL_FILE)
-		if err == nil {
-			if err = ecl.ValidateConfig(); err != nil {
-				return err
-			}
-			_, err := ecl.ShouldRunFp(img.File)
+	// query the ECL module, proceed if an ecl config file is found
+	ecl, err := syecl.LoadConfig(buildcfg.ECL_FILE)
+	if err == nil {
+		if err = ecl.ValidateConfig(); err != nil {
+			return err
+		}
+		// signatures are only verified with the global keyring
+		var kr openpgp.EntityList
+		if ecl.Activated && img.Type == image.SIF {
+			kr, err = sypgp.LoadGlobalPubKeyring()
 			if err != nil {
-				return err
+				return fmt.Errorf("could not load global keyring: %s", err)
 			}
 		}
+		source := e.EngineConfig.GetImageSource()
+		digest := e.EngineConfig.GetImageDigest()
+		if _, err = ecl.ShouldRunImage(img, source, digest, kr); err != nil {
+			return err
+		}
 	}
 	// first image is always the root filesystem
 	images = append(images, *img)
@@ -581,6 +635,17 @@ func (e *EngineOperations) loadImages() error {
 		if err != nil {
 			return fmt.Errorf("failed to open overlay image %s: %s", splitted[0], err)
 		}
+		if img.Type == image.SANDBOX && os.Getuid() != 0 && starterConfig.GetIsSUID() {
+			fds, err := checkOverlayDir(img)
+			if err != nil {
+				return fmt.Errorf("can't use %s as overlay: %s", splitted[0], err)
+			}
+			if fds != nil {
+				e.EngineConfig.SetOverlayFd(img.Path, fds)
+				// closed with other file descriptors in stage 2
+				e.EngineConfig.SetOpenFd(append(e.EngineConfig.GetOpenFd(), fds...))
+			}
+		}
 		images = append(images, *img)
 	}
 
@@ -589,6 +654,72 @@ func (e *EngineOperations) loadImages() error {
 	return nil
 }
 
+// checkOverlayDir verifies that the directory opened by img can be used
+// as overlay by an unprivileged user in setuid mode. For a writable
+// overlay the directory must be owned by the user, upper and work
+// directories are opened or created relative to the opened directory
+// without following symlinks and checked through their file descriptors
+// which are returned, the overlay is then mounted on these directories
+// and not on paths which may have been replaced since
+func checkOverlayDir(img *image.Image) (fds []int, err error) {
+	uid := uint32(os.Getuid())
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(img.Fd), &st); err != nil {
+		return nil, fmt.Errorf("can't stat %s: %s", img.Path, err)
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return nil, fmt.Errorf("%s is not a directory", img.Path)
+	}
+	if !img.Writable {
+		return nil, nil
+	}
+	if st.Uid != uid {
+		return nil, fmt.Errorf("directory %s is not owned by user %d", img.Path, uid)
+	}
+
+	defer func() {
+		if err != nil {
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+			fds = nil
+		}
+	}()
+
+	for _, name := range []string{"upper", "work"} {
+		path := filepath.Join(img.Path, name)
+
+		// stage 1 runs with user privileges, a missing directory
+		// is created with user ownership
+		err := syscall.Mkdirat(int(img.Fd), name, 0755)
+		if err != nil && err != syscall.EEXIST {
+			return fds, fmt.Errorf("failed to create %s directory: %s", path, err)
+		}
+		fd, err := syscall.Openat(int(img.Fd), name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW, 0)
+		if err == syscall.ELOOP {
+			return fds, fmt.Errorf("%s is a symlink, it must be a directory", path)
+		} else if err == syscall.ENOTDIR {
+			return fds, fmt.Errorf("%s is not a directory", path)
+		} else if err != nil {
+			return fds, fmt.Errorf("failed to open %s: %s", path, err)
+		}
+		fds = append(fds, fd)
+
+		if err := syscall.Fstat(fd, &st); err != nil {
+			return fds, fmt.Errorf("can't stat %s: %s", path, err)
+		}
+		if st.Uid != uid {
+			return fds, fmt.Errorf("directory %s is not owned by user %d", path, uid)
+		}
+		if err := overlay.CheckUserUpperFd(fd, path); err != nil {
+			return fds, err
+		}
+	}
+
+	return fds, nil
+}
+
 func (e *EngineOperations) loadImage(path string, writable bool) (*image.Image, error) {
 	imgObject, err := image.Init(path, writable)
 	if err != nil {
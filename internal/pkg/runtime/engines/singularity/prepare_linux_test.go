@@ -0,0 +1,91 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+func TestCheckOverlayDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		writable bool
+		setup    func(dir string) error
+		wantErr  bool
+	}{
+		{
+			name:     "read-only",
+			writable: false,
+			setup:    func(dir string) error { return nil },
+		},
+		{
+			name:     "upper symlink",
+			writable: true,
+			setup: func(dir string) error {
+				return os.Symlink("/etc", filepath.Join(dir, "upper"))
+			},
+			wantErr: true,
+		},
+		{
+			name:     "work symlink",
+			writable: true,
+			setup: func(dir string) error {
+				if err := os.Mkdir(filepath.Join(dir, "upper"), 0755); err != nil {
+					return err
+				}
+				return os.Symlink("/etc", filepath.Join(dir, "work"))
+			},
+			wantErr: true,
+		},
+		{
+			name:     "upper file",
+			writable: true,
+			setup: func(dir string) error {
+				return ioutil.WriteFile(filepath.Join(dir, "upper"), nil, 0644)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "overlay-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := tt.setup(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := image.Init(dir, tt.writable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer img.File.Close()
+
+			fds, err := checkOverlayDir(img)
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+			if tt.wantErr && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if err != nil && fds != nil {
+				t.Errorf("file descriptors returned with error")
+			}
+		})
+	}
+}
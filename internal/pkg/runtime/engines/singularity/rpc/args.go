@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -58,3 +58,23 @@ type SetFsIDArgs struct {
 	UID int
 	GID int
 }
+
+// FuseOverlayArgs defines the arguments to mount an overlay with fuse-overlayfs.
+type FuseOverlayArgs struct {
+	Program string
+	Target  string
+	Options string
+}
+
+// CryptOpenArgs defines the arguments to unlock an encrypted device.
+type CryptOpenArgs struct {
+	Cryptsetup string
+	Device     string
+	Key        []byte
+}
+
+// CryptCloseArgs defines the arguments to close an encrypted device.
+type CryptCloseArgs struct {
+	Cryptsetup string
+	Device     string
+}
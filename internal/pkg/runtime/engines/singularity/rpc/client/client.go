@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -106,3 +106,40 @@ func (t *RPC) SetFsID(uid int, gid int) (int, error) {
 	err := t.Client.Call(t.Name+".SetFsID", arguments, &reply)
 	return reply, err
 }
+
+// FuseOverlay calls the fuse overlay RPC using the supplied arguments
+// and returns the PID of the fuse-overlayfs process.
+func (t *RPC) FuseOverlay(program string, target string, options string) (int, error) {
+	arguments := &args.FuseOverlayArgs{
+		Program: program,
+		Target:  target,
+		Options: options,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".FuseOverlay", arguments, &reply)
+	return reply, err
+}
+
+// CryptOpen calls the crypt open RPC using the supplied arguments
+// and returns the path of the unlocked device.
+func (t *RPC) CryptOpen(cryptsetup string, device string, key []byte) (string, error) {
+	arguments := &args.CryptOpenArgs{
+		Cryptsetup: cryptsetup,
+		Device:     device,
+		Key:        key,
+	}
+	var reply string
+	err := t.Client.Call(t.Name+".CryptOpen", arguments, &reply)
+	return reply, err
+}
+
+// CryptClose calls the crypt close RPC using the supplied arguments,
+// the device is removed once it's not used anymore.
+func (t *RPC) CryptClose(cryptsetup string, device string) error {
+	arguments := &args.CryptCloseArgs{
+		Cryptsetup: cryptsetup,
+		Device:     device,
+	}
+	var reply int
+	return t.Client.Call(t.Name+".CryptClose", arguments, &reply)
+}
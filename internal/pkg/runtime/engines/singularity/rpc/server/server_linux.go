@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -8,21 +8,31 @@ package server
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
+	"github.com/sylabs/singularity/pkg/util/crypt"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
 var diskGID = -1
 
+// fuse-overlayfs mount point is checked every fuseOverlayDelay
+// up to fuseOverlayRetries times
+const (
+	fuseOverlayRetries = 100
+	fuseOverlayDelay   = 50 * time.Millisecond
+)
+
 // Methods is a receiver type.
 type Methods int
 
@@ -182,3 +192,50 @@ func (t *Methods) SetFsID(arguments *args.SetFsIDArgs, reply *int) error {
 	})
 	return nil
 }
+
+// FuseOverlay starts fuse-overlayfs to mount an overlay with the specified
+// arguments, waits until the mount point is ready and sets reply to the
+// PID of the fuse-overlayfs process.
+func (t *Methods) FuseOverlay(arguments *args.FuseOverlayArgs, reply *int) error {
+	cmd := exec.Command(arguments.Program, "-f", "-o", arguments.Options, arguments.Target)
+	// detach from controlling terminal, process must outlive RPC server
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %s", arguments.Program, err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	for i := 0; i < fuseOverlayRetries; i++ {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("%s exited before mounting %s: %v", arguments.Program, arguments.Target, err)
+		case <-time.After(fuseOverlayDelay):
+		}
+		if point, err := proc.ParentMount(arguments.Target); err == nil && point == arguments.Target {
+			sylog.Debugf("%s mounted %s (PID %d)", arguments.Program, arguments.Target, cmd.Process.Pid)
+			*reply = cmd.Process.Pid
+			return nil
+		}
+	}
+
+	cmd.Process.Kill()
+	return fmt.Errorf("timeout while waiting %s to mount %s", arguments.Program, arguments.Target)
+}
+
+// CryptOpen unlocks an encrypted device with cryptsetup.
+func (t *Methods) CryptOpen(arguments *args.CryptOpenArgs, reply *string) (err error) {
+	dev := &crypt.Device{Cryptsetup: arguments.Cryptsetup}
+	*reply, err = dev.Open(arguments.Key, arguments.Device)
+	return err
+}
+
+// CryptClose schedules the removal of an unlocked device with cryptsetup.
+func (t *Methods) CryptClose(arguments *args.CryptCloseArgs, reply *int) error {
+	dev := &crypt.Device{Cryptsetup: arguments.Cryptsetup}
+	return dev.Close(arguments.Device, true)
+}
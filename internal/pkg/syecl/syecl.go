@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -15,30 +15,70 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	toml "github.com/pelletier/go-toml"
+	"github.com/sylabs/singularity/internal/pkg/util/user"
+	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/signing"
+	"golang.org/x/crypto/openpgp"
 )
 
 // EclConfig describes the structure of an execution control list configuration file
 type EclConfig struct {
-	Activated  bool        `toml:"activated"` // toggle the activation of the ECL rules
-	ExecGroups []execgroup `toml:"execgroup"` // Slice of all execution groups
+	Activated      bool        `toml:"activated"`                // toggle the activation of the ECL rules
+	DenyUnsigned   bool        `toml:"denyunsigned,omitempty"`   // refuse image formats which can't be signed
+	AllowedDigests []string    `toml:"alloweddigests,omitempty"` // digests of docker and OCI images allowed to run
+	ExecGroups     []execgroup `toml:"execgroup"`                // Slice of all execution groups
 }
 
 // execgroup describes an execution group, the main unit of configuration:
 //	TagName: a descriptive identifier
 //	ListMode: whether the execgroup follows a whitelist, whitestrict or blacklist model
-//		whitelist: one or more KeyFP's present and verified,
-//		whitestrict: all KeyFP's present and verified,
-//		blacklist: none of the KeyFP should be present
+//		whitelist: one or more KeyFP's present and verified with the global keyring,
+//			or a certificate issued by CABundle,
+//		whitestrict: all KeyFP's present and verified, and a certificate issued
+//			by CABundle if set,
+//		blacklist: none of the KeyFP nor a certificate issued by CABundle should be present
 //	DirPath: containers must be stored in this directory path
 //	KeyFPs: list of Key Fingerprints of entities to verify
+//	CABundle: optional PEM file of CA certificates to verify X.509 signatures
+//	Match: how DirPath is compared to the directory of a container
+//		exact: the directory is DirPath (default),
+//		prefix: the directory is DirPath or one of its subdirectories,
+//		glob: the directory matches the DirPath shell pattern
+//	Users: optional user names the execgroup is restricted to
+//	Groups: optional group names the execgroup is restricted to
+//	Priority: execgroups of higher priority are considered first
 type execgroup struct {
 	TagName  string   `toml:"tagname"`
 	ListMode string   `toml:"mode"`
 	DirPath  string   `toml:"dirpath"`
 	KeyFPs   []string `toml:"keyfp"`
+	CABundle string   `toml:"cabundle,omitempty"`
+	Match    string   `toml:"match,omitempty"`
+	Users    []string `toml:"users,omitempty"`
+	Groups   []string `toml:"groups,omitempty"`
+	Priority int      `toml:"priority,omitempty"`
+}
+
+// Decision explains whether a container is allowed to run and which
+// execgroup took the decision
+type Decision struct {
+	Path     string   // container path with symlinks resolved
+	TagName  string   // tagname of the execgroup applying to the container
+	Mode     string   // list mode of the execgroup applying to the container
+	Match    string   // why the execgroup applies to the container
+	Shadowed []string // tagnames of other matching execgroups of lower precedence
+	Allowed  bool     // whether the container is allowed to run
+	Err      error    // why the container is not allowed to run
+}
+
+// identity is the user execgroup users and groups are checked against
+type identity struct {
+	uid  uint32
+	gids []uint32
 }
 
 // LoadConfig opens an ECL config file and unmarshals it into structures
@@ -69,25 +109,55 @@ func PutConfig(ecl EclConfig, confPath string) (err error) {
 func (ecl *EclConfig) ValidateConfig() (err error) {
 	m := map[string]bool{}
 
+	for _, d := range ecl.AllowedDigests {
+		decoded, err := hex.DecodeString(strings.TrimPrefix(d, "sha256:"))
+		if !strings.HasPrefix(d, "sha256:") || err != nil || len(decoded) != 32 {
+			return fmt.Errorf("expecting a sha256:<64 chars hex> digest string: %s", d)
+		}
+	}
+	// digests are reported by the user and can't be verified, only
+	// signatures of SIF images allow an image to run
+	if len(ecl.AllowedDigests) > 0 && !ecl.DenyUnsigned {
+		return fmt.Errorf("alloweddigests requires denyunsigned to be set")
+	}
+
 	for _, v := range ecl.ExecGroups {
-		if m[v.DirPath] {
-			return fmt.Errorf("a specific dirpath can only appear in one execgroup: %s", v.DirPath)
+		// a dirpath may appear in several execgroups restricted to different users
+		key := fmt.Sprintf("%s:%s:%v:%v", v.match(), v.DirPath, v.Users, v.Groups)
+		if m[key] {
+			return fmt.Errorf("a specific dirpath can only appear in one execgroup for the same users and groups: %s", v.DirPath)
 		}
-		m[v.DirPath] = true
+		m[key] = true
 
-		// if we allow containers everywhere, don't test dirpath constraint
-		if v.DirPath != "" {
-			path, err := filepath.EvalSymlinks(v.DirPath)
-			if err != nil {
-				return err
+		switch v.match() {
+		case "exact", "prefix":
+			if v.match() == "prefix" && v.DirPath == "" {
+				return fmt.Errorf("execgroup %s: prefix match requires a dirpath", v.TagName)
 			}
-			abs, err := filepath.Abs(path)
-			if err != nil {
-				return err
+			// if we allow containers everywhere, don't test dirpath constraint
+			if v.DirPath != "" {
+				path, err := filepath.EvalSymlinks(v.DirPath)
+				if err != nil {
+					return err
+				}
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				if v.DirPath != abs {
+					return fmt.Errorf("all execgroup dirpath`s should be fully cleaned with symlinks resolved")
+				}
 			}
-			if v.DirPath != abs {
-				return fmt.Errorf("all execgroup dirpath`s should be fully cleaned with symlinks resolved")
+		case "glob":
+			// patterns can't be resolved, they are matched against resolved paths
+			if !filepath.IsAbs(v.DirPath) || filepath.Clean(v.DirPath) != v.DirPath {
+				return fmt.Errorf("execgroup %s: glob dirpath should be an absolute cleaned pattern: %s", v.TagName, v.DirPath)
 			}
+			if _, err := filepath.Match(v.DirPath, ""); err != nil {
+				return fmt.Errorf("execgroup %s: invalid dirpath pattern %s: %s", v.TagName, v.DirPath, err)
+			}
+		default:
+			return fmt.Errorf("the match field can only be either: exact, prefix, glob")
 		}
 		if v.ListMode != "whitelist" && v.ListMode != "whitestrict" && v.ListMode != "blacklist" {
 			return fmt.Errorf("the mode field can only be either: whitelist, whitestrict, blacklist")
@@ -98,23 +168,77 @@ func (ecl *EclConfig) ValidateConfig() (err error) {
 				return fmt.Errorf("expecting a 40 chars hex fingerprint string")
 			}
 		}
+		if v.CABundle != "" && !filepath.IsAbs(v.CABundle) {
+			return fmt.Errorf("execgroup cabundle should be an absolute path: %s", v.CABundle)
+		}
+		for _, name := range v.Users {
+			if _, err := user.GetPwNam(name); err != nil {
+				return fmt.Errorf("execgroup %s: unknown user %s: %s", v.TagName, name, err)
+			}
+		}
+		for _, name := range v.Groups {
+			if _, err := user.GetGrNam(name); err != nil {
+				return fmt.Errorf("execgroup %s: unknown group %s: %s", v.TagName, name, err)
+			}
+		}
 	}
 	return
 }
 
+// CABundles returns the CA bundles of all execgroups
+func (ecl *EclConfig) CABundles() (bundles []string) {
+	for _, v := range ecl.ExecGroups {
+		if v.CABundle != "" {
+			bundles = append(bundles, v.CABundle)
+		}
+	}
+	return bundles
+}
+
+// hasCertificate returns whether the primary partition of an opened container
+// has a valid X.509 signature issued by the CA bundle of egroup, if any
+func hasCertificate(fp *os.File, egroup *execgroup) (bool, error) {
+	if egroup.CABundle == "" {
+		return false, nil
+	}
+	roots, err := signing.LoadCABundle(egroup.CABundle)
+	if err != nil {
+		return false, fmt.Errorf("could not load CA bundle: %s", err)
+	}
+	certs, err := signing.GetVerifiedCertificatesFp(fp, roots)
+	if err != nil {
+		return false, err
+	}
+	return len(certs) > 0, nil
+}
+
+// hasFingerprint returns whether fingerprint is part of keyfps
+func hasFingerprint(keyfps []string, fingerprint string) bool {
+	for _, u := range keyfps {
+		if strings.EqualFold(u, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
 // checkWhiteList evaluates authorization by requiring at least 1 entity
-func checkWhiteList(fp *os.File, egroup *execgroup) (ok bool, err error) {
-	// get all signing entities fingerprints on the primary partition
-	keyfps, err := signing.GetSignEntitiesFp(fp)
+func checkWhiteList(fp *os.File, egroup *execgroup, kr openpgp.KeyRing) (ok bool, err error) {
+	// get all verified signing entities fingerprints on the primary partition
+	keyfps, _, err := signing.GetVerifiedEntitiesFp(fp, kr)
 	if err != nil {
 		return
 	}
 	// was the primary partition signed by an authorized entity?
 	for _, v := range egroup.KeyFPs {
-		for _, u := range keyfps {
-			if v == u {
-				ok = true
-			}
+		if hasFingerprint(keyfps, v) {
+			ok = true
+		}
+	}
+	// or with a certificate issued by an authorized CA?
+	if !ok {
+		if ok, err = hasCertificate(fp, egroup); err != nil {
+			return false, err
 		}
 	}
 	if !ok {
@@ -125,26 +249,26 @@ func checkWhiteList(fp *os.File, egroup *execgroup) (ok bool, err error) {
 }
 
 // checkWhiteStrict evaluates authorization by requiring all entities
-func checkWhiteStrict(fp *os.File, egroup *execgroup) (ok bool, err error) {
-	// get all signing entities fingerprints on the primary partition
-	keyfps, err := signing.GetSignEntitiesFp(fp)
+func checkWhiteStrict(fp *os.File, egroup *execgroup, kr openpgp.KeyRing) (ok bool, err error) {
+	// get all verified signing entities fingerprints on the primary partition
+	keyfps, _, err := signing.GetVerifiedEntitiesFp(fp, kr)
 	if err != nil {
 		return
 	}
 
 	// was the primary partition signed by all authorized entity?
-	m := map[string]bool{}
 	for _, v := range egroup.KeyFPs {
-		m[v] = false
-		for _, u := range keyfps {
-			if v == u {
-				m[v] = true
-			}
+		if !hasFingerprint(keyfps, v) {
+			return false, fmt.Errorf("%s is not signed by required entities", fp.Name())
 		}
 	}
-	for _, v := range m {
-		if v != true {
-			return false, fmt.Errorf("%s is not signed by required entities", fp.Name())
+	// and with a certificate issued by the authorized CA?
+	if egroup.CABundle != "" {
+		if ok, err = hasCertificate(fp, egroup); err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, fmt.Errorf("%s is not signed with a certificate of the required CA", fp.Name())
 		}
 	}
 
@@ -152,62 +276,299 @@ func checkWhiteStrict(fp *os.File, egroup *execgroup) (ok bool, err error) {
 }
 
 // checkBlackList evaluates authorization by requiring all entities to be absent
-func checkBlackList(fp *os.File, egroup *execgroup) (ok bool, err error) {
-	// get all signing entities fingerprints on the primary partition
-	keyfps, err := signing.GetSignEntitiesFp(fp)
+func checkBlackList(fp *os.File, egroup *execgroup, kr openpgp.KeyRing) (ok bool, err error) {
+	// get all signing entities fingerprints on the primary partition, a
+	// signature doesn't need to be valid to forbid execution
+	verified, claimed, err := signing.GetVerifiedEntitiesFp(fp, kr)
 	if err != nil {
 		return
 	}
-	// was the primary partition signed by an authorized entity?
+	keyfps := append(verified, claimed...)
+	// was the primary partition signed by a forbidden entity?
 	for _, v := range egroup.KeyFPs {
-		for _, u := range keyfps {
-			if v == u {
-				return false, fmt.Errorf("%s is signed by a forbidden entity", fp.Name())
-			}
+		if hasFingerprint(keyfps, v) {
+			return false, fmt.Errorf("%s is signed by a forbidden entity", fp.Name())
 		}
 	}
+	// was the primary partition signed with a certificate of a forbidden CA?
+	if ok, err = hasCertificate(fp, egroup); err != nil {
+		return false, err
+	}
+	if ok {
+		return false, fmt.Errorf("%s is signed with a certificate of a forbidden CA", fp.Name())
+	}
 
 	return true, nil
 }
 
-func shouldRun(ecl *EclConfig, fp *os.File) (ok bool, err error) {
-	var egroup *execgroup
+// match returns how the dirpath of egroup is matched
+func (egroup *execgroup) match() string {
+	if egroup.Match == "" {
+		return "exact"
+	}
+	return egroup.Match
+}
 
-	// look what execgroup a container is part of
-	for _, v := range ecl.ExecGroups {
-		if filepath.Dir(fp.Name()) == v.DirPath {
-			egroup = &v
-			break
+// pathRank returns the precedence of the dirpath of egroup, lower ranks
+// are considered first: exact dirpaths, prefixes, patterns and no dirpath
+func (egroup *execgroup) pathRank() int {
+	switch {
+	case egroup.DirPath == "":
+		return 3
+	case egroup.match() == "prefix":
+		return 1
+	case egroup.match() == "glob":
+		return 2
+	}
+	return 0
+}
+
+// matchPath returns whether the dirpath of egroup matches dir and why
+func (egroup *execgroup) matchPath(dir string) (string, bool) {
+	if egroup.DirPath == "" {
+		return "execgroup without dirpath applies to any directory", true
+	}
+	switch egroup.match() {
+	case "exact":
+		if dir == egroup.DirPath {
+			return fmt.Sprintf("%s is the dirpath", dir), true
+		}
+	case "prefix":
+		if dir == egroup.DirPath || strings.HasPrefix(dir, strings.TrimSuffix(egroup.DirPath, "/")+"/") {
+			return fmt.Sprintf("%s is under the dirpath prefix %s", dir, egroup.DirPath), true
+		}
+	case "glob":
+		if ok, _ := filepath.Match(egroup.DirPath, dir); ok {
+			return fmt.Sprintf("%s matches the dirpath pattern %s", dir, egroup.DirPath), true
 		}
 	}
-	// go back at it and this time look for an empty dirpath execgroup to fallback into
-	if egroup == nil {
-		for _, v := range ecl.ExecGroups {
-			if v.DirPath == "" {
-				egroup = &v
-				break
+	return "", false
+}
+
+// matchIdentity returns whether egroup applies to id and why, an execgroup
+// without users nor groups applies to everyone
+func (egroup *execgroup) matchIdentity(id identity) (string, bool, error) {
+	if len(egroup.Users) == 0 && len(egroup.Groups) == 0 {
+		return "", true, nil
+	}
+	for _, name := range egroup.Users {
+		pw, err := user.GetPwNam(name)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to retrieve user information for %s: %s", name, err)
+		}
+		if pw.UID == id.uid {
+			return "user " + name, true, nil
+		}
+	}
+	for _, name := range egroup.Groups {
+		gr, err := user.GetGrNam(name)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to retrieve group information for %s: %s", name, err)
+		}
+		for _, gid := range id.gids {
+			if gr.GID == gid {
+				return "group " + name, true, nil
 			}
 		}
 	}
+	return "", false, nil
+}
+
+// sortGroups returns the execgroups in precedence order: higher priority
+// first, then exact dirpaths, longest dirpath prefixes, dirpath patterns
+// and execgroups without dirpath, then execgroups restricted to users or
+// groups. Execgroups of the same precedence keep their configuration order
+func sortGroups(groups []execgroup) []*execgroup {
+	sorted := make([]*execgroup, len(groups))
+	for i := range groups {
+		sorted[i] = &groups[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.pathRank() != b.pathRank() {
+			return a.pathRank() < b.pathRank()
+		}
+		if a.pathRank() == 1 && len(a.DirPath) != len(b.DirPath) {
+			return len(a.DirPath) > len(b.DirPath)
+		}
+		restricted := func(g *execgroup) bool {
+			return len(g.Users) > 0 || len(g.Groups) > 0
+		}
+		return restricted(a) && !restricted(b)
+	})
+	return sorted
+}
+
+// currentIdentity returns the identity of the user running the process
+func currentIdentity() (identity, error) {
+	id := identity{uid: uint32(os.Getuid()), gids: []uint32{uint32(os.Getgid())}}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return id, fmt.Errorf("could not get user groups: %s", err)
+	}
+	for _, gid := range groups {
+		id.gids = append(id.gids, uint32(gid))
+	}
+	return id, nil
+}
+
+// decide applies the execgroup of highest precedence matching the path
+// of an opened container and id. The path is resolved from the file
+// descriptor so the execgroup applies to the container actually verified
+func decide(ecl *EclConfig, fp *os.File, kr openpgp.KeyRing, id identity) *Decision {
+	d := &Decision{Path: fp.Name()}
+
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fp.Fd()))
+	if err != nil {
+		d.Err = fmt.Errorf("could not resolve %s: %s", fp.Name(), err)
+		return d
+	}
+	d.Path = path
+	dir := filepath.Dir(path)
+
+	// look what execgroup a container is part of
+	var egroup *execgroup
+	for _, v := range sortGroups(ecl.ExecGroups) {
+		why, ok := v.matchPath(dir)
+		if !ok {
+			continue
+		}
+		who, ok, err := v.matchIdentity(id)
+		if err != nil {
+			d.Err = err
+			return d
+		}
+		if !ok {
+			continue
+		}
+		if egroup != nil {
+			d.Shadowed = append(d.Shadowed, v.TagName)
+			continue
+		}
+		egroup = v
+		d.TagName = v.TagName
+		d.Mode = v.ListMode
+		d.Match = why
+		if who != "" {
+			d.Match += " and the execgroup applies to " + who
+		}
+	}
 
 	if egroup == nil {
-		return false, fmt.Errorf("%s not part of any execgroup", fp.Name())
+		d.Err = fmt.Errorf("%s not part of any execgroup", fp.Name())
+		return d
 	}
 
 	switch egroup.ListMode {
 	case "whitelist":
-		return checkWhiteList(fp, egroup)
+		d.Allowed, d.Err = checkWhiteList(fp, egroup, kr)
 	case "whitestrict":
-		return checkWhiteStrict(fp, egroup)
+		d.Allowed, d.Err = checkWhiteStrict(fp, egroup, kr)
 	case "blacklist":
-		return checkBlackList(fp, egroup)
+		d.Allowed, d.Err = checkBlackList(fp, egroup, kr)
+	default:
+		d.Err = fmt.Errorf("ECL config file invalid")
 	}
+	return d
+}
+
+func shouldRun(ecl *EclConfig, fp *os.File, kr openpgp.KeyRing) (ok bool, err error) {
+	id, err := currentIdentity()
+	if err != nil {
+		return false, err
+	}
+
+	d := decide(ecl, fp, kr, id)
+	return d.Allowed, d.Err
+}
+
+// formatName returns the name of an image format
+func formatName(format int) string {
+	switch format {
+	case image.SIF:
+		return "SIF"
+	case image.SANDBOX:
+		return "sandbox"
+	case image.SQUASHFS:
+		return "squashfs"
+	case image.EXT3:
+		return "ext3"
+	case image.ENCRYPTSQUASHFS:
+		return "encrypted squashfs"
+	}
+	return "unknown"
+}
+
+// CheckImage explains whether an opened image would be allowed to run by the
+// current user according to the ECL rules, even if they are not activated.
+// When allowed digests are set, images are refused unless they are converted
+// from a docker or OCI source with one of these digests. Source and digest are
+// reported by the caller, so an allowed digest never allows an image by itself:
+// SIF images are then checked against their execgroup rules with signatures
+// verified with the public keys of kr, and other formats which can't be signed
+// are refused if unsigned formats are denied
+func (ecl *EclConfig) CheckImage(img *image.Image, source, digest string, kr openpgp.KeyRing) (*Decision, error) {
+	d := &Decision{Path: img.Path}
+
+	if source == "" && len(ecl.AllowedDigests) > 0 {
+		d.Match = fmt.Sprintf("%s has no docker or OCI source and allowed digests are set", img.Path)
+		d.Err = fmt.Errorf("%s is not run from a docker or OCI source, only images with an allowed digest may run", img.Path)
+		return d, nil
+	}
+	if len(ecl.AllowedDigests) > 0 {
+		allowed := false
+		for _, v := range ecl.AllowedDigests {
+			if digest != "" && strings.EqualFold(v, digest) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			d.Match = fmt.Sprintf("%s is a docker or OCI source with digest %s", source, digest)
+			d.Err = fmt.Errorf("%s digest %s is not in the allowed digests", source, digest)
+			return d, nil
+		}
+	}
+
+	switch {
+	case img.Type == image.SIF:
+		id, err := currentIdentity()
+		if err != nil {
+			return nil, err
+		}
+		return decide(ecl, img.File, kr, id), nil
+	case ecl.DenyUnsigned:
+		d.Match = fmt.Sprintf("%s is a %s image which can't be signed", img.Path, formatName(img.Type))
+		d.Err = fmt.Errorf("%s is a %s image, only signed SIF images are allowed to run", img.Path, formatName(img.Type))
+	default:
+		d.Match = fmt.Sprintf("%s is a %s image and unsigned formats are not denied", img.Path, formatName(img.Type))
+		d.Allowed = true
+	}
+	return d, nil
+}
 
-	return false, fmt.Errorf("ECL config file invalid")
+// ShouldRunImage determines if an opened image should run according to the
+// ECL rules, source and digest are set for images converted from a docker or
+// OCI source. Signatures are verified with the public keys of kr
+func (ecl *EclConfig) ShouldRunImage(img *image.Image, source, digest string, kr openpgp.KeyRing) (ok bool, err error) {
+	// look if ECL rules are activated
+	if ecl.Activated == false {
+		return true, nil
+	}
+
+	d, err := ecl.CheckImage(img, source, digest, kr)
+	if err != nil {
+		return false, err
+	}
+	return d.Allowed, d.Err
 }
 
-// ShouldRun determines if a container should run according to its execgroup rules
-func (ecl *EclConfig) ShouldRun(cpath string) (ok bool, err error) {
+// ShouldRun determines if a container should run according to its execgroup rules,
+// signatures are verified with the public keys of kr
+func (ecl *EclConfig) ShouldRun(cpath string, kr openpgp.KeyRing) (ok bool, err error) {
 	// look if ECL rules are activated
 	if ecl.Activated == false {
 		return true, nil
@@ -217,16 +578,18 @@ func (ecl *EclConfig) ShouldRun(cpath string) (ok bool, err error) {
 	if err != nil {
 		return false, err
 	}
+	defer fp.Close()
 
-	return shouldRun(ecl, fp)
+	return shouldRun(ecl, fp, kr)
 }
 
-// ShouldRunFp determines if an already opened container should run according to its execgroup rules
-func (ecl *EclConfig) ShouldRunFp(fp *os.File) (ok bool, err error) {
+// ShouldRunFp determines if an already opened container should run according to its execgroup rules,
+// signatures are verified with the public keys of kr
+func (ecl *EclConfig) ShouldRunFp(fp *os.File, kr openpgp.KeyRing) (ok bool, err error) {
 	// look if ECL rules are activated
 	if ecl.Activated == false {
 		return true, nil
 	}
 
-	return shouldRun(ecl, fp)
+	return shouldRun(ecl, fp, kr)
 }
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,22 +6,58 @@
 package syecl
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/signing"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+var (
+	keyFP1 string // fingerprint of the first global keyring entity
+	keyFP2 string // fingerprint of the second global keyring entity
 )
 
-const (
-	KeyFP1 = "5994BE54C31CF1B5E1994F987C52CF6D055F072B"
-	KeyFP2 = "7064B1D6EFF01B1262FED3F03581D99FE87EAFD1"
+var (
+	testKeyring openpgp.EntityList // global keyring used for verification
+	testEclDir  string             // dirname of the test containers outside execgroups
 )
 
 var (
-	srcContainer1 = filepath.Join("testdata", "container1.sif")
-	srcContainer2 = filepath.Join("testdata", "container2.sif")
-	srcContainer3 = filepath.Join("testdata", "container3.sif")
+	srcContainer1 string // container signed by the first entity
+	srcContainer2 string // container signed by both entities
+	srcContainer3 string // container signed by the first entity
+	srcContainer4 string // container signed by the second entity
+	srcTampered   string // container modified after being signed by the first entity
+	srcForged     string // container signed by an unknown entity claiming to be the first entity
+	srcExpired    string // container signed by an expired entity of the global keyring
+	srcCert       string // container signed with a certificate issued by the test CA
+	srcOtherCert  string // container signed with a certificate issued by another CA
+	srcCertTamper string // container modified after being signed with a certificate of the test CA
 )
 
 var (
@@ -30,26 +66,39 @@ var (
 	testEclDirPath1  string // dirname of the first Ecl execgroup
 	testEclDirPath2  string // dirname of the second Ecl execgroup
 	testEclDirPath3  string // dirname of the third Ecl execgroup
+	testEclDirPath4  string // dirname of the fourth Ecl execgroup
+	testCABundle     string // pathname of the CA bundle of the test CA
 	testContainer1   string // pathname of the first test container
 	testContainer2   string // pathname of the second test container
 	testContainer3   string // pathname of the third test container
 	testContainer4   string // pathname of the forth test container
+	testContainer5   string // pathname of the fifth test container
+	testTampered1    string // pathname of the tampered container in whitelist
+	testForged1      string // pathname of the forged container in whitelist
+	testForged3      string // pathname of the forged container in blacklist
+	testExpired1     string // pathname of the container signed by an expired key in whitelist
+	testCert3        string // pathname of the container signed with a certificate in blacklist
+	testCert4        string // pathname of the container signed with a certificate in CA whitelist
+	testOtherCert4   string // pathname of the container signed with another CA certificate in CA whitelist
+	testCertTamper4  string // pathname of the tampered certificate signed container in CA whitelist
+	testContainer6   string // pathname of the OpenPGP signed container in CA whitelist
 )
 
 var testEclConfig = EclConfig{
 	Activated: true,
 	ExecGroups: []execgroup{
-		{"group1", "whitelist", "", []string{KeyFP1, KeyFP2}},
-		{"group2", "whitestrict", "", []string{KeyFP1, KeyFP2}},
-		{"group3", "blacklist", "", []string{KeyFP1}},
+		{TagName: "group1", ListMode: "whitelist"},
+		{TagName: "group2", ListMode: "whitestrict"},
+		{TagName: "group3", ListMode: "blacklist"},
+		{TagName: "group4", ListMode: "whitelist"},
 	},
 }
 
 var testEclConfig2 = EclConfig{
 	Activated: true,
 	ExecGroups: []execgroup{
-		{"pathdup", "whitelist", "/tmp", nil},
-		{"pathdup", "whitelist", "/tmp", nil},
+		{TagName: "pathdup", ListMode: "whitelist", DirPath: "/tmp"},
+		{TagName: "pathdup", ListMode: "whitelist", DirPath: "/tmp"},
 	},
 }
 
@@ -75,8 +124,8 @@ func TestLoadConfig(t *testing.T) {
 	if ecl.ExecGroups[0].DirPath != testEclDirPath1 {
 		t.Error("the path was expected to be:", testEclDirPath1)
 	}
-	if ecl.ExecGroups[0].KeyFPs[0] != KeyFP1 {
-		t.Error("the entity was expected to be:", KeyFP1)
+	if ecl.ExecGroups[0].KeyFPs[0] != keyFP1 {
+		t.Error("the entity was expected to be:", keyFP1)
 	}
 }
 
@@ -110,52 +159,494 @@ func TestShouldRun(t *testing.T) {
 		t.Error(`ecl.ValidateConfig():`, err)
 	}
 
-	// check container1 authorization
-	run, err := ecl.ShouldRun(testContainer1)
+	tests := []struct {
+		name string
+		path string
+		run  bool
+	}{
+		{"whitelist", testContainer1, true},
+		{"whitelist tampered", testTampered1, false},
+		{"whitelist forged", testForged1, false},
+		{"whitelist expired key", testExpired1, false},
+		{"whitestrict", testContainer2, true},
+		{"whitestrict missing entity", testContainer3, false},
+		{"blacklist", testContainer4, false},
+		{"blacklist forged", testForged3, false},
+		{"blacklist other entity", testContainer5, true},
+		{"blacklist ca", testCert3, false},
+		{"whitelist ca", testCert4, true},
+		{"whitelist ca other", testOtherCert4, false},
+		{"whitelist ca tampered", testCertTamper4, false},
+		{"whitelist ca openpgp", testContainer6, false},
+		{"outside dirpath", srcContainer1, false},
+	}
+
+	for _, tt := range tests {
+		run, err := ecl.ShouldRun(tt.path, testKeyring)
+		if tt.run && (err != nil || !run) {
+			t.Errorf("%s: %s should be allowed to run: %v", tt.name, tt.path, err)
+		} else if !tt.run && (err == nil || run) {
+			t.Errorf("%s: %s should NOT be allowed to run", tt.name, tt.path)
+		}
+	}
+
+	// in this second round of tests, set DirPath to "", and test container outside of execgroups
+	ecl.ExecGroups[0].DirPath = ""
+	ecl.ExecGroups[1].DirPath = ""
+
+	// check container1 authorization (outside of defined dirpath)
+	run, err := ecl.ShouldRun(srcContainer1, testKeyring)
 	if err != nil {
-		t.Error(`ecl.ShouldRun(testContainer1):`, err)
+		t.Error(`ecl.ShouldRun(srcContainer1):`, err)
 	}
 	if !run {
-		t.Error(testContainer1, "should be allowed to run")
+		t.Error(srcContainer1, "should be allowed to run")
+	}
+
+	// a signature is not valid if the signer is not in the keyring
+	run, err = ecl.ShouldRun(srcContainer1, openpgp.EntityList{})
+	if err == nil || run {
+		t.Error(srcContainer1, "should NOT be allowed to run without keyring")
 	}
-	// check container2 authorization
-	run, err = ecl.ShouldRun(testContainer2)
+}
+
+func TestValidateMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []execgroup
+		valid  bool
+	}{
+		{"prefix", []execgroup{{ListMode: "whitelist", DirPath: testEclDirPath1, Match: "prefix"}}, true},
+		{"prefix without dirpath", []execgroup{{ListMode: "whitelist", Match: "prefix"}}, false},
+		{"glob", []execgroup{{ListMode: "whitelist", DirPath: "/opt/*/containers", Match: "glob"}}, true},
+		{"glob relative", []execgroup{{ListMode: "whitelist", DirPath: "opt/*", Match: "glob"}}, false},
+		{"glob not cleaned", []execgroup{{ListMode: "whitelist", DirPath: "/opt/*/", Match: "glob"}}, false},
+		{"glob invalid", []execgroup{{ListMode: "whitelist", DirPath: "/opt/[", Match: "glob"}}, false},
+		{"unknown match", []execgroup{{ListMode: "whitelist", DirPath: testEclDirPath1, Match: "regex"}}, false},
+		{"users", []execgroup{{ListMode: "whitelist", Users: []string{"root"}, Groups: []string{"root"}}}, true},
+		{"unknown user", []execgroup{{ListMode: "whitelist", Users: []string{"no-such-ecl-user"}}}, false},
+		{"unknown group", []execgroup{{ListMode: "whitelist", Groups: []string{"no-such-ecl-group"}}}, false},
+		{"dirpath per users", []execgroup{
+			{ListMode: "whitelist", DirPath: testEclDirPath1},
+			{ListMode: "blacklist", DirPath: testEclDirPath1, Users: []string{"root"}},
+		}, true},
+		{"dirpath per match", []execgroup{
+			{ListMode: "whitelist", DirPath: testEclDirPath1},
+			{ListMode: "blacklist", DirPath: testEclDirPath1, Match: "prefix"},
+		}, true},
+		{"dirpath duplicated", []execgroup{
+			{ListMode: "whitelist", DirPath: testEclDirPath1, Users: []string{"root"}},
+			{ListMode: "blacklist", DirPath: testEclDirPath1, Users: []string{"root"}},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		ecl := EclConfig{Activated: true, ExecGroups: tt.groups}
+		err := ecl.ValidateConfig()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	base, err := ioutil.TempDir("", "ecldecide-")
 	if err != nil {
-		t.Error(`ecl.ShouldRun(testContainer2):`, err)
+		t.Fatal(err)
 	}
-	if !run {
-		t.Error(testContainer2, "should be allowed to run")
+	defer os.RemoveAll(base)
+	// dirpaths are matched against resolved paths
+	if base, err = filepath.EvalSymlinks(base); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
 	}
-	// check container3 authorization (fails with KeyFP)
-	run, err = ecl.ShouldRun(testContainer3)
-	if err == nil || run == true {
-		t.Error(testContainer3, "should NOT be allowed to run")
+	for _, dir := range []string{"", "a", "a/b", "c"} {
+		if err := copyFile(filepath.Join(base, dir, "img.sif"), srcContainer1); err != nil {
+			t.Fatal(err)
+		}
 	}
-	// check srcContainer1 authorization (fails with dirpath)
-	run, err = ecl.ShouldRun(srcContainer1)
-	if err == nil || run == true {
-		t.Error(srcContainer1, "should NOT be allowed to run")
+	if err := os.Symlink(filepath.Join(base, "a/b"), filepath.Join(base, "l")); err != nil {
+		t.Fatal(err)
 	}
-	// check container4 authorization (fails with blacklist)
-	run, err = ecl.ShouldRun(testContainer4)
-	if err == nil || run == true {
-		t.Error(testContainer4, "should NOT be allowed to run")
+
+	ecl := EclConfig{
+		Activated: true,
+		ExecGroups: []execgroup{
+			{TagName: "override", ListMode: "blacklist", KeyFPs: []string{keyFP1}, Groups: []string{"root"}, Priority: 1},
+			{TagName: "glob", ListMode: "whitelist", DirPath: filepath.Join(base, "?"), KeyFPs: []string{keyFP1}, Match: "glob"},
+			{TagName: "prefix", ListMode: "whitelist", DirPath: filepath.Join(base, "a"), KeyFPs: []string{keyFP1}, Match: "prefix"},
+			{TagName: "deny", ListMode: "blacklist", DirPath: filepath.Join(base, "a/b"), KeyFPs: []string{keyFP1}, Match: "prefix"},
+			{TagName: "users", ListMode: "blacklist", DirPath: filepath.Join(base, "c"), KeyFPs: []string{keyFP1}, Match: "glob", Users: []string{"root"}},
+		},
+	}
+	if err := ecl.ValidateConfig(); err != nil {
+		t.Fatalf("ecl.ValidateConfig(): %v", err)
 	}
 
-	// in this second round of tests, set DirPath to "", and test container in testdata/
-	ecl.ExecGroups[0].DirPath = ""
-	ecl.ExecGroups[1].DirPath = ""
+	other := identity{uid: 1234, gids: []uint32{1234}}
+	rootUser := identity{uid: 0, gids: []uint32{1234}}
+	rootGroup := identity{uid: 0, gids: []uint32{1234, 0}}
 
-	// check container1 authorization (outside of defined dirpath)
-	run, err = ecl.ShouldRun(srcContainer1)
+	tests := []struct {
+		name     string
+		path     string
+		id       identity
+		tagname  string
+		shadowed []string
+		run      bool
+	}{
+		{"prefix", "a/img.sif", other, "prefix", []string{"glob"}, true},
+		{"longest prefix", "a/b/img.sif", other, "deny", []string{"prefix"}, false},
+		{"symlink", "l/img.sif", other, "deny", []string{"prefix"}, false},
+		{"glob", "c/img.sif", other, "glob", nil, true},
+		{"users", "c/img.sif", rootUser, "users", []string{"glob"}, false},
+		{"priority", "c/img.sif", rootGroup, "override", []string{"users", "glob"}, false},
+		{"no execgroup", "img.sif", other, "", nil, false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(base, tt.path)
+		fp, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := decide(&ecl, fp, testKeyring, tt.id)
+		fp.Close()
+
+		if d.TagName != tt.tagname {
+			t.Errorf("%s: %s matched execgroup %q instead of %q", tt.name, path, d.TagName, tt.tagname)
+		}
+		if fmt.Sprint(d.Shadowed) != fmt.Sprint(tt.shadowed) {
+			t.Errorf("%s: unexpected shadowed execgroups %v, expected %v", tt.name, d.Shadowed, tt.shadowed)
+		}
+		if tt.run && (d.Err != nil || !d.Allowed) {
+			t.Errorf("%s: %s should be allowed to run: %v", tt.name, path, d.Err)
+		} else if !tt.run && (d.Err == nil || d.Allowed) {
+			t.Errorf("%s: %s should NOT be allowed to run", tt.name, path)
+		}
+		if d.Path == path && tt.path == "l/img.sif" {
+			t.Errorf("%s: path %s not resolved", tt.name, path)
+		}
+	}
+
+	// the execgroup applies to the opened container even if a path
+	// component is replaced before the decision
+	path := filepath.Join(base, "l/img.sif")
+	fp, err := os.Open(path)
 	if err != nil {
-		t.Error(`ecl.ShouldRun(srcContainer1):`, err)
+		t.Fatal(err)
 	}
-	if !run {
-		t.Error(srcContainer1, "should be allowed to run")
+	defer fp.Close()
+	if err := os.Remove(filepath.Join(base, "l")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "c"), filepath.Join(base, "l")); err != nil {
+		t.Fatal(err)
+	}
+	if d := decide(&ecl, fp, testKeyring, other); d.TagName != "deny" || d.Allowed {
+		t.Errorf("swapped path %s matched execgroup %q instead of %q", path, d.TagName, "deny")
 	}
 }
 
+func TestShouldRunImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	other := "sha256:" + strings.Repeat("cd", 32)
+
+	for _, d := range []string{digest, strings.Repeat("ab", 32), "sha256:abcd"} {
+		ecl := EclConfig{Activated: true, DenyUnsigned: true, AllowedDigests: []string{d}}
+		if err := ecl.ValidateConfig(); d == digest && err != nil {
+			t.Errorf("unexpected error validating digest %s: %v", d, err)
+		} else if d != digest && err == nil {
+			t.Errorf("unexpected success validating digest %s", d)
+		}
+	}
+	ecl := EclConfig{Activated: true, AllowedDigests: []string{digest}}
+	if err := ecl.ValidateConfig(); err == nil {
+		t.Errorf("unexpected success validating allowed digests without denyunsigned")
+	}
+
+	sandbox, err := image.Init(testEclDir, false)
+	if err != nil {
+		t.Fatalf("failed to open sandbox: %v", err)
+	}
+	defer sandbox.File.Close()
+	sifImage, err := image.Init(testContainer1, false)
+	if err != nil {
+		t.Fatalf("failed to open SIF image: %v", err)
+	}
+	defer sifImage.File.Close()
+
+	tests := []struct {
+		name   string
+		ecl    EclConfig
+		img    *image.Image
+		source string
+		digest string
+		run    bool
+	}{
+		{"deactivated", EclConfig{DenyUnsigned: true}, sandbox, "", "", true},
+		{"sandbox", EclConfig{Activated: true}, sandbox, "", "", true},
+		{"sandbox denied", EclConfig{Activated: true, DenyUnsigned: true}, sandbox, "", "", false},
+		{"sif", testEclConfig, sifImage, "", "", true},
+		{"oci source", EclConfig{Activated: true}, sandbox, "oci:image", digest, true},
+		{"oci source unsigned", EclConfig{Activated: true, DenyUnsigned: true}, sandbox, "oci:image", digest, false},
+		{"oci digest", EclConfig{Activated: true, AllowedDigests: []string{digest}}, sandbox, "oci:image", digest, true},
+		// an allowed digest never bypasses the unsigned and SIF rules
+		{"oci digest unsigned", EclConfig{Activated: true, DenyUnsigned: true, AllowedDigests: []string{digest}}, sandbox, "oci:image", digest, false},
+		{"oci digest sif", EclConfig{Activated: true, AllowedDigests: []string{digest}}, sifImage, "oci:image", digest, false},
+		{"oci other digest", EclConfig{Activated: true, AllowedDigests: []string{digest}}, sandbox, "oci:image", other, false},
+		{"oci no digest", EclConfig{Activated: true, AllowedDigests: []string{digest}}, sandbox, "oci:image", "", false},
+		// images without source, e.g. run directly from the cache, are refused
+		{"local image", EclConfig{Activated: true, AllowedDigests: []string{digest}}, sandbox, "", "", false},
+		{"local sif", EclConfig{Activated: true, DenyUnsigned: true, AllowedDigests: []string{digest}, ExecGroups: testEclConfig.ExecGroups}, sifImage, "", "", false},
+		{"oci digest signed sif", EclConfig{Activated: true, DenyUnsigned: true, AllowedDigests: []string{digest}, ExecGroups: testEclConfig.ExecGroups}, sifImage, "oci:image", digest, true},
+		{"local sif digest", EclConfig{Activated: true, DenyUnsigned: true, AllowedDigests: []string{digest}, ExecGroups: testEclConfig.ExecGroups}, sifImage, "", digest, false},
+	}
+
+	for _, tt := range tests {
+		run, err := tt.ecl.ShouldRunImage(tt.img, tt.source, tt.digest, testKeyring)
+		if tt.run && (err != nil || !run) {
+			t.Errorf("%s: %s should be allowed to run: %v", tt.name, tt.img.Path, err)
+		} else if !tt.run && (err == nil || run) {
+			t.Errorf("%s: %s should NOT be allowed to run", tt.name, tt.img.Path)
+		}
+	}
+}
+
+// newExpiredEntity returns an entity created two hours ago with a key
+// valid for one hour
+func newExpiredEntity(name, email string) (*openpgp.Entity, error) {
+	config := &packet.Config{
+		Time: func() time.Time {
+			return time.Now().Add(-2 * time.Hour)
+		},
+	}
+	e, err := openpgp.NewEntity(name, "", email, config)
+	if err != nil {
+		return nil, err
+	}
+
+	lifetime := uint32(3600)
+	for id, i := range e.Identities {
+		i.SelfSignature.KeyLifetimeSecs = &lifetime
+		if err := i.SelfSignature.SignUserId(id, e.PrimaryKey, e.PrivateKey, config); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// createContainer creates a SIF file with a primary partition at path
+func createContainer(path string) error {
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Data:     bytes.Repeat([]byte{'p'}, 4096),
+	}
+	input.Size = int64(len(input.Data))
+	if err := input.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		return err
+	}
+
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{input},
+	}
+	_, err := sif.CreateContainer(cinfo)
+	return err
+}
+
+// createSignedContainer creates a SIF file at path signed by entities
+func createSignedContainer(path string, entities ...*openpgp.Entity) error {
+	if err := createContainer(path); err != nil {
+		return err
+	}
+	for _, e := range entities {
+		if err := signing.SignWithEntity(path, e, signing.SignOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tamperContainer modifies the primary partition of the SIF file at path
+func tamperContainer(path string) error {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return err
+	}
+	descr, _, err := fimg.GetPartPrimSys()
+	fimg.UnloadContainer()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte{'t'}, descr.Fileoff); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// forgeContainer creates a SIF file at path signed by signer, with a
+// signature descriptor claiming the fingerprint of claimed
+func forgeContainer(path string, signer, claimed *openpgp.Entity) error {
+	if err := createContainer(path); err != nil {
+		return err
+	}
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return err
+	}
+	sum := sha512.Sum384(descr.GetData(&fimg))
+
+	var signedmsg bytes.Buffer
+	plaintext, err := clearsign.Encode(&signedmsg, signer.PrivateKey, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(plaintext, "SIFHASH:\n%x", sum); err != nil {
+		return err
+	}
+	if err := plaintext.Close(); err != nil {
+		return err
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataSignature,
+		Groupid:  descr.Groupid,
+		Link:     descr.ID,
+		Data:     signedmsg.Bytes(),
+	}
+	input.Size = int64(len(input.Data))
+	if err := input.SetSignExtra(sif.HashSHA384, hex.EncodeToString(claimed.PrimaryKey.Fingerprint[:])); err != nil {
+		return err
+	}
+	return fimg.AddObject(input)
+}
+
+// createCertificate returns a code signing certificate of the public key
+// of key issued by parent with parentKey, or a self-signed CA certificate
+// if parent is nil
+func createCertificate(name string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, error) {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// writePEM writes a PEM block of type blockType holding der to path
+func writePEM(path, blockType string, der []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
+
+// createCertificates writes the test CA certificate to caPath and returns
+// the paths of an RSA certificate and key issued by the test CA, followed
+// by the paths of an ECDSA certificate and key issued by another CA
+func createCertificates(dir, caPath string) ([]string, error) {
+	var paths []string
+
+	for i, name := range []string{"test", "other"} {
+		caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ca, err := createCertificate(name+" CA", caKey, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			if err := writePEM(caPath, "CERTIFICATE", ca.Raw); err != nil {
+				return nil, err
+			}
+		}
+
+		var key crypto.Signer
+		var keyType string
+		var keyDer []byte
+		if i == 0 {
+			k, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				return nil, err
+			}
+			key, keyType, keyDer = k, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k)
+		} else {
+			k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				return nil, err
+			}
+			if keyDer, err = x509.MarshalPKCS8PrivateKey(k); err != nil {
+				return nil, err
+			}
+			key, keyType = k, "PRIVATE KEY"
+		}
+		cert, err := createCertificate(name+" signer", key, ca, caKey)
+		if err != nil {
+			return nil, err
+		}
+
+		certPath := filepath.Join(dir, name+"-cert.pem")
+		keyPath := filepath.Join(dir, name+"-key.pem")
+		if err := writePEM(certPath, "CERTIFICATE", cert.Raw); err != nil {
+			return nil, err
+		}
+		if err := writePEM(keyPath, keyType, keyDer); err != nil {
+			return nil, err
+		}
+		paths = append(paths, certPath, keyPath)
+	}
+
+	return paths, nil
+}
+
+// createX509Container creates a SIF file at path signed with the
+// certificate and private key PEM files certPath and keyPath
+func createX509Container(path, certPath, keyPath string) error {
+	if err := createContainer(path); err != nil {
+		return err
+	}
+	return signing.SignX509(path, certPath, keyPath, signing.SignOptions{})
+}
+
 func copyFile(dst, src string) error {
 	s, err := os.Open(src)
 	if err != nil {
@@ -192,7 +683,31 @@ func setup() error {
 	testEclFileName2 = tmpfile.Name()
 	tmpfile.Close()
 
-	// Create three directories where we put test containers
+	// Create the entities, the third one is not part of the global keyring
+	var entities []*openpgp.Entity
+	for i := 1; i <= 3; i++ {
+		e, err := openpgp.NewEntity(fmt.Sprintf("ecl%d", i), "", fmt.Sprintf("ecl%d@example.com", i), nil)
+		if err != nil {
+			return err
+		}
+		entities = append(entities, e)
+	}
+	// the fourth one is part of the global keyring but its key is expired
+	expired, err := newExpiredEntity("ecl4", "ecl4@example.com")
+	if err != nil {
+		return err
+	}
+	testKeyring = openpgp.EntityList{entities[0], entities[1], expired}
+	keyFP1 = fmt.Sprintf("%X", entities[0].PrimaryKey.Fingerprint[:])
+	// fingerprints are case insensitive
+	keyFP2 = fmt.Sprintf("%x", entities[1].PrimaryKey.Fingerprint[:])
+
+	// Create four directories where we put test containers
+	testEclDir, err = ioutil.TempDir("", "ecldir-")
+	if err != nil {
+		return err
+	}
+
 	testEclDirPath1, err = ioutil.TempDir("", "ecldir1-")
 	if err != nil {
 		return err
@@ -208,26 +723,106 @@ func setup() error {
 		return err
 	}
 
-	// Set the just created Dirpaths in the EclConfig struct to marshal
+	testEclDirPath4, err = ioutil.TempDir("", "ecldir4-")
+	if err != nil {
+		return err
+	}
+
+	// Set the just created Dirpaths and fingerprints in the EclConfig struct to marshal
 	testEclConfig.ExecGroups[0].DirPath = testEclDirPath1
+	testEclConfig.ExecGroups[0].KeyFPs = []string{keyFP1, keyFP2, fmt.Sprintf("%X", expired.PrimaryKey.Fingerprint)}
 	testEclConfig.ExecGroups[1].DirPath = testEclDirPath2
+	testEclConfig.ExecGroups[1].KeyFPs = []string{keyFP1, keyFP2}
 	testEclConfig.ExecGroups[2].DirPath = testEclDirPath3
+	testEclConfig.ExecGroups[2].KeyFPs = []string{keyFP1}
+	testEclConfig.ExecGroups[3].DirPath = testEclDirPath4
+	testCABundle = filepath.Join(testEclDir, "ca.pem")
+	testEclConfig.ExecGroups[2].CABundle = testCABundle
+	testEclConfig.ExecGroups[3].CABundle = testCABundle
+
+	// create the source containers outside of execgroups
+	srcContainer1 = filepath.Join(testEclDir, "container1.sif")
+	if err := createSignedContainer(srcContainer1, entities[0]); err != nil {
+		return err
+	}
+	srcContainer2 = filepath.Join(testEclDir, "container2.sif")
+	if err := createSignedContainer(srcContainer2, entities[0], entities[1]); err != nil {
+		return err
+	}
+	srcContainer3 = filepath.Join(testEclDir, "container3.sif")
+	if err := createSignedContainer(srcContainer3, entities[0]); err != nil {
+		return err
+	}
+	srcContainer4 = filepath.Join(testEclDir, "container4.sif")
+	if err := createSignedContainer(srcContainer4, entities[1]); err != nil {
+		return err
+	}
+	srcTampered = filepath.Join(testEclDir, "tampered.sif")
+	if err := createSignedContainer(srcTampered, entities[0]); err != nil {
+		return err
+	}
+	if err := tamperContainer(srcTampered); err != nil {
+		return err
+	}
+	srcForged = filepath.Join(testEclDir, "forged.sif")
+	if err := forgeContainer(srcForged, entities[2], entities[0]); err != nil {
+		return err
+	}
 
-	// prepare and copy test containers from testdata/* to their test dirpaths
-	testContainer1 = filepath.Join(testEclDirPath1, filepath.Base(srcContainer1))
-	if err := copyFile(testContainer1, srcContainer1); err != nil {
+	srcExpired = filepath.Join(testEclDir, "expired.sif")
+	if err := createSignedContainer(srcExpired, expired); err != nil {
+		return err
+	}
+
+	// sign containers with certificates of the test CA and of another CA
+	certPaths, err := createCertificates(testEclDir, testCABundle)
+	if err != nil {
 		return err
 	}
-	testContainer2 = filepath.Join(testEclDirPath2, filepath.Base(srcContainer2))
-	if err := copyFile(testContainer2, srcContainer2); err != nil {
+	srcCert = filepath.Join(testEclDir, "cert.sif")
+	if err := createX509Container(srcCert, certPaths[0], certPaths[1]); err != nil {
 		return err
 	}
-	testContainer3 = filepath.Join(testEclDirPath2, filepath.Base(srcContainer3))
-	if err := copyFile(testContainer3, srcContainer3); err != nil {
+	srcOtherCert = filepath.Join(testEclDir, "othercert.sif")
+	if err := createX509Container(srcOtherCert, certPaths[2], certPaths[3]); err != nil {
 		return err
 	}
-	testContainer4 = filepath.Join(testEclDirPath3, filepath.Base(srcContainer3))
-	return copyFile(testContainer4, srcContainer3)
+	srcCertTamper = filepath.Join(testEclDir, "certtampered.sif")
+	if err := createX509Container(srcCertTamper, certPaths[0], certPaths[1]); err != nil {
+		return err
+	}
+	if err := tamperContainer(srcCertTamper); err != nil {
+		return err
+	}
+
+	// copy test containers to their test dirpaths
+	copies := []struct {
+		dst *string
+		dir string
+		src string
+	}{
+		{&testContainer1, testEclDirPath1, srcContainer1},
+		{&testTampered1, testEclDirPath1, srcTampered},
+		{&testForged1, testEclDirPath1, srcForged},
+		{&testExpired1, testEclDirPath1, srcExpired},
+		{&testContainer2, testEclDirPath2, srcContainer2},
+		{&testContainer3, testEclDirPath2, srcContainer3},
+		{&testContainer4, testEclDirPath3, srcContainer3},
+		{&testContainer5, testEclDirPath3, srcContainer4},
+		{&testForged3, testEclDirPath3, srcForged},
+		{&testCert3, testEclDirPath3, srcCert},
+		{&testCert4, testEclDirPath4, srcCert},
+		{&testOtherCert4, testEclDirPath4, srcOtherCert},
+		{&testCertTamper4, testEclDirPath4, srcCertTamper},
+		{&testContainer6, testEclDirPath4, srcContainer1},
+	}
+	for _, c := range copies {
+		*c.dst = filepath.Join(c.dir, filepath.Base(c.src))
+		if err := copyFile(*c.dst, c.src); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func shutdown() {
@@ -236,6 +831,8 @@ func shutdown() {
 	os.RemoveAll(testEclDirPath1)
 	os.RemoveAll(testEclDirPath2)
 	os.RemoveAll(testEclDirPath3)
+	os.RemoveAll(testEclDirPath4)
+	os.RemoveAll(testEclDir)
 }
 
 func TestMain(m *testing.M) {
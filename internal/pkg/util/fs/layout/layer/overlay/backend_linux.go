@@ -0,0 +1,125 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+)
+
+// Backend identifies how the overlay layer is mounted
+type Backend string
+
+const (
+	// KernelBackend mounts the overlay layer with the kernel overlay filesystem
+	KernelBackend Backend = "overlay"
+	// FuseBackend mounts the overlay layer with fuse-overlayfs
+	FuseBackend Backend = "fuse-overlayfs"
+)
+
+// unprivilegedRelease is the first kernel release allowing
+// overlay mounts from unprivileged user namespaces
+var unprivilegedRelease = [2]int{5, 11}
+
+// osReleasePath is the path of the running kernel release
+var osReleasePath = "/proc/sys/kernel/osrelease"
+
+// DetectBackend returns the backend able to mount the overlay layer,
+// userNS indicates that the layer is mounted from an unprivileged
+// user namespace
+func DetectBackend(userNS bool) (Backend, error) {
+	kernel, _ := proc.HasFilesystem("overlay")
+	sylog.Debugf("Kernel overlay filesystem support: %v", kernel)
+
+	if !userNS {
+		if !kernel {
+			return "", fmt.Errorf("overlay filesystem not supported by kernel")
+		}
+		sylog.Debugf("Selected %s backend for overlay layer", KernelBackend)
+		return KernelBackend, nil
+	}
+
+	if kernel {
+		allowed, err := unprivilegedOverlay()
+		if err != nil {
+			sylog.Debugf("Could not determine if kernel allows unprivileged overlay: %s", err)
+		} else if allowed {
+			sylog.Debugf("Kernel allows overlay mounts in user namespace")
+			sylog.Debugf("Selected %s backend for overlay layer", KernelBackend)
+			return KernelBackend, nil
+		}
+		sylog.Debugf("Kernel doesn't allow overlay mounts in user namespace")
+	}
+
+	if _, err := FuseOverlayfsPath(); err != nil {
+		return "", err
+	}
+	if fuse, _ := proc.HasFilesystem("fuse"); !fuse {
+		return "", fmt.Errorf("fuse filesystem not supported by kernel")
+	}
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		return "", fmt.Errorf("fuse device not available: %s", err)
+	}
+
+	sylog.Debugf("Selected %s backend for overlay layer", FuseBackend)
+	return FuseBackend, nil
+}
+
+// FuseOverlayfsPath returns the path of fuse-overlayfs executable
+func FuseOverlayfsPath() (string, error) {
+	path, err := exec.LookPath(string(FuseBackend))
+	if err != nil {
+		return "", fmt.Errorf("%s not found in PATH", FuseBackend)
+	}
+	sylog.Debugf("Found %s at %s", FuseBackend, path)
+	return path, nil
+}
+
+// unprivilegedOverlay returns if the running kernel allows
+// overlay mounts from unprivileged user namespaces
+func unprivilegedOverlay() (bool, error) {
+	b, err := ioutil.ReadFile(osReleasePath)
+	if err != nil {
+		return false, err
+	}
+	major, minor, err := parseRelease(string(b))
+	if err != nil {
+		return false, err
+	}
+	if major != unprivilegedRelease[0] {
+		return major > unprivilegedRelease[0], nil
+	}
+	return minor >= unprivilegedRelease[1], nil
+}
+
+// parseRelease returns major and minor version of a kernel release
+func parseRelease(release string) (int, int, error) {
+	fields := strings.SplitN(strings.TrimSpace(release), ".", 3)
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("bad kernel release format: %q", release)
+	}
+	major, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad kernel major version: %s", err)
+	}
+	// minor version may be directly followed by a suffix as in 5.11-rc1
+	minorStr := fields[1]
+	if i := strings.IndexFunc(minorStr, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minorStr = minorStr[:i]
+	}
+	minor, err := strconv.Atoi(minorStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad kernel minor version: %s", err)
+	}
+	return major, minor, nil
+}
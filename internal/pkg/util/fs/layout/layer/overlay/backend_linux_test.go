@@ -0,0 +1,79 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		release string
+		major   int
+		minor   int
+		wantErr bool
+	}{
+		{"4.18.0-80.el8.x86_64\n", 4, 18, false},
+		{"5.11.0", 5, 11, false},
+		{"5.11-rc1", 5, 11, false},
+		{"6.1", 6, 1, false},
+		{"5", 0, 0, true},
+		{"a.b.c", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		major, minor, err := parseRelease(tt.release)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("unexpected success for release %q", tt.release)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for release %q: %s", tt.release, err)
+		} else if major != tt.major || minor != tt.minor {
+			t.Errorf("release %q: got %d.%d instead of %d.%d", tt.release, major, minor, tt.major, tt.minor)
+		}
+	}
+}
+
+func TestUnprivilegedOverlay(t *testing.T) {
+	defer func(path string) { osReleasePath = path }(osReleasePath)
+
+	f, err := ioutil.TempFile("", "osrelease-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	osReleasePath = f.Name()
+
+	tests := []struct {
+		release string
+		allowed bool
+	}{
+		{"3.10.0-957.el7.x86_64", false},
+		{"5.10.0", false},
+		{"5.11.0", true},
+		{"5.15.0-generic", true},
+		{"6.0.0", true},
+	}
+
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(osReleasePath, []byte(tt.release), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed, err := unprivilegedOverlay()
+		if err != nil {
+			t.Errorf("unexpected error for release %s: %s", tt.release, err)
+		} else if allowed != tt.allowed {
+			t.Errorf("release %s: got %v instead of %v", tt.release, allowed, tt.allowed)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -27,11 +27,24 @@ type Overlay struct {
 	lowerDirs []string
 	upperDir  string
 	workDir   string
+	backend   Backend
 }
 
 // New creates and returns an overlay layer manager
+// using kernel overlay filesystem
 func New() *Overlay {
-	return &Overlay{}
+	return NewWithBackend(KernelBackend)
+}
+
+// NewWithBackend creates and returns an overlay layer manager
+// mounting the layer with the provided backend
+func NewWithBackend(backend Backend) *Overlay {
+	return &Overlay{backend: backend}
+}
+
+// Backend returns the backend used to mount the overlay layer
+func (o *Overlay) Backend() Backend {
+	return o.backend
 }
 
 // Add adds required directory in session layout
@@ -0,0 +1,77 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// statfs flags of read-only, nosuid and nodev filesystems
+const (
+	stRdonly = 0x1
+	stNosuid = 0x2
+	stNodev  = 0x4
+)
+
+// incompatibleUpperFs maps magic numbers of filesystems which
+// can't be used as overlay upper layer to their names
+var incompatibleUpperFs = map[int64]string{
+	0x6969:     "NFS",
+	0xff534d42: "CIFS",
+	0xfe534d42: "SMB2",
+	0x517b:     "SMB",
+	0x65735546: "FUSE",
+	0x794c7630: "overlay",
+	0x73717368: "squashfs",
+	0x47504653: "GPFS",
+	0x0bd00bd0: "Lustre",
+}
+
+// CheckUpperFs returns an error if path is located on a filesystem
+// which can't be used as overlay upper layer
+func CheckUpperFs(path string) error {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fmt.Errorf("can't determine filesystem type of %s: %s", path, err)
+	}
+	return checkUpperStatfs(path, &st)
+}
+
+// CheckUserUpperFd returns an error if the directory opened with fd
+// can't be used as overlay upper layer owned by an unprivileged user.
+// Files are copied up with root privileges, the filesystem must be
+// mounted with nosuid and nodev options so that setuid binaries and
+// device nodes copied from the container can't be used on the host
+func CheckUserUpperFd(fd int, path string) error {
+	var st syscall.Statfs_t
+
+	if err := syscall.Fstatfs(fd, &st); err != nil {
+		return fmt.Errorf("can't determine filesystem type of %s: %s", path, err)
+	}
+	return checkUserUpperStatfs(path, &st)
+}
+
+func checkUserUpperStatfs(path string, st *syscall.Statfs_t) error {
+	if err := checkUpperStatfs(path, st); err != nil {
+		return err
+	}
+	if st.Flags&(stNosuid|stNodev) != stNosuid|stNodev {
+		return fmt.Errorf("%s must be located on a filesystem mounted with nosuid and nodev options", path)
+	}
+	return nil
+}
+
+func checkUpperStatfs(path string, st *syscall.Statfs_t) error {
+	if name, ok := incompatibleUpperFs[int64(st.Type)]; ok {
+		return fmt.Errorf("%s is located on a %s filesystem which can't be used as overlay upper layer", path, name)
+	}
+	if st.Flags&stRdonly != 0 {
+		return fmt.Errorf("%s is located on a read-only filesystem", path)
+	}
+	return nil
+}
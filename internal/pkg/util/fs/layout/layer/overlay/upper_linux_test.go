@@ -0,0 +1,79 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestCheckUpperStatfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fstype  int64
+		flags   int64
+		wantErr bool
+	}{
+		{"ext4", 0xef53, 0, false},
+		{"xfs", 0x58465342, 0, false},
+		{"tmpfs", 0x01021994, 0, false},
+		{"read-only ext4", 0xef53, stRdonly, true},
+		{"nfs", 0x6969, 0, true},
+		{"overlay", 0x794c7630, 0, true},
+		{"fuse", 0x65735546, 0, true},
+	}
+
+	for _, tt := range tests {
+		st := &syscall.Statfs_t{}
+		st.Type = tt.fstype
+		st.Flags = tt.flags
+
+		err := checkUpperStatfs("/overlay", st)
+		if tt.wantErr && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		} else if !tt.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		}
+	}
+
+	if err := CheckUpperFs("/non/existent/path"); err == nil {
+		t.Errorf("unexpected success with non existent path")
+	}
+}
+
+func TestCheckUserUpperStatfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fstype  int64
+		flags   int64
+		wantErr bool
+	}{
+		{"nosuid nodev ext4", 0xef53, stNosuid | stNodev, false},
+		{"nosuid nodev tmpfs", 0x01021994, stNosuid | stNodev, false},
+		{"ext4", 0xef53, 0, true},
+		{"nosuid ext4", 0xef53, stNosuid, true},
+		{"nodev ext4", 0xef53, stNodev, true},
+		{"read-only nosuid nodev ext4", 0xef53, stRdonly | stNosuid | stNodev, true},
+		{"nosuid nodev nfs", 0x6969, stNosuid | stNodev, true},
+	}
+
+	for _, tt := range tests {
+		st := &syscall.Statfs_t{}
+		st.Type = tt.fstype
+		st.Flags = tt.flags
+
+		err := checkUserUpperStatfs("/overlay", st)
+		if tt.wantErr && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		} else if !tt.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		}
+	}
+
+	if err := CheckUserUpperFd(-1, "/overlay"); err == nil {
+		t.Errorf("unexpected success with invalid file descriptor")
+	}
+}
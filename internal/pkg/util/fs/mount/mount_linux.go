@@ -137,8 +137,9 @@ var authorizedTags = map[AuthorizedTag]struct {
 }
 
 var authorizedImage = map[string]fsContext{
-	"ext3":     {true},
-	"squashfs": {true},
+	"ext3":      {true},
+	"squashfs":  {true},
+	"encryptfs": {true},
 }
 
 var authorizedFS = map[string]fsContext{
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -12,6 +12,7 @@ import (
 
 	ocitypes "github.com/containers/image/types"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/crypt"
 )
 
 // Bundle is the temporary build environment used during the image
@@ -58,6 +59,9 @@ type Options struct {
 	Sections []string `json:"sections"`
 	// contains docker credentials if specified
 	DockerAuthConfig *ocitypes.DockerAuthConfig
+	// EncryptionKeyInfo describes the key material used to encrypt
+	// the root filesystem, nil for an unencrypted root filesystem
+	EncryptionKeyInfo *crypt.KeyInfo `json:"-"`
 }
 
 // NewBundle creates a Bundle environment
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -7,15 +7,13 @@ package client
 
 import (
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
-	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
-	pb "gopkg.in/cheggaaa/pb.v1"
+	net "github.com/sylabs/singularity/pkg/client/net"
 )
 
 // Timeout for an image pull in seconds - could be a large download...
@@ -24,6 +22,13 @@ const pullTimeout = 1800
 // DownloadImage will retrieve an image from the Container Library,
 // saving it into the specified file
 func DownloadImage(filePath string, libraryRef string, libraryURL string, Force bool, authToken string) error {
+	return DownloadImageHash(filePath, libraryRef, libraryURL, Force, authToken, "")
+}
+
+// DownloadImageHash will retrieve an image from the Container Library,
+// saving it into the specified file. If hash is not empty, the downloaded
+// image is checked against it before being moved to the specified file
+func DownloadImageHash(filePath string, libraryRef string, libraryURL string, Force bool, authToken string, hash string) error {
 
 	if !IsLibraryPullRef(libraryRef) {
 		return fmt.Errorf("Not a valid library reference: %s", libraryRef)
@@ -51,73 +56,36 @@ func DownloadImage(filePath string, libraryRef string, libraryURL string, Force
 		}
 	}
 
-	client := &http.Client{
+	d := &net.Downloader{
+		Header:  http.Header{},
 		Timeout: pullTimeout * time.Second,
+		CheckResponse: func(res *http.Response) error {
+			if res.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("The requested image was not found in the library")
+			}
+			jRes, err := ParseErrorBody(res.Body)
+			if err != nil {
+				jRes = ParseErrorResponse(res)
+			}
+			return fmt.Errorf("Download did not succeed: %d %s\n\t%v",
+				jRes.Error.Code, jRes.Error.Status, jRes.Error.Message)
+		},
 	}
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return err
-	}
-
 	if authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+authToken)
-	}
-	req.Header.Set("User-Agent", useragent.Value())
-
-	res, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("The requested image was not found in the library")
+		d.Header.Set("Authorization", "Bearer "+authToken)
 	}
-
-	if res.StatusCode != http.StatusOK {
-		jRes, err := ParseErrorBody(res.Body)
-		if err != nil {
-			jRes = ParseErrorResponse(res)
+	if hash != "" {
+		d.Verify = func(path string) error {
+			fileHash, err := ImageHash(path)
+			if err != nil {
+				return fmt.Errorf("Error getting ImageHash: %v", err)
+			}
+			if fileHash != hash {
+				return fmt.Errorf("Downloaded File Hash(%s) and Expected Hash(%s) does not match", fileHash, hash)
+			}
+			return nil
 		}
-		return fmt.Errorf("Download did not succeed: %d %s\n\t%v",
-			jRes.Error.Code, jRes.Error.Status, jRes.Error.Message)
 	}
 
-	sylog.Debugf("OK response received, beginning body download\n")
-
-	// Perms are 777 *prior* to umask
-	out, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	sylog.Debugf("Created output file: %s\n", filePath)
-
-	bodySize := res.ContentLength
-	bar := pb.New(int(bodySize)).SetUnits(pb.U_BYTES)
-	if sylog.GetLevel() < 0 {
-		bar.NotPrint = true
-	}
-	bar.ShowTimeLeft = true
-	bar.ShowSpeed = true
-
-	// create proxy reader
-	bodyProgress := bar.NewProxyReader(res.Body)
-
-	bar.Start()
-
-	// Write the body to file
-	_, err = io.Copy(out, bodyProgress)
-	if err != nil {
-		return err
-	}
-
-	bar.Finish()
-
-	sylog.Debugf("Download complete\n")
-
-	return nil
-
+	return d.Download(filePath, url)
 }
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -128,3 +129,59 @@ func Test_DownloadImage(t *testing.T) {
 		}))
 	}
 }
+
+func Test_DownloadImageHash(t *testing.T) {
+
+	hash, err := ImageHash("test_data/test_sha256")
+	if err != nil {
+		t.Fatalf("Error getting test file hash: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		hash        string
+		expectError bool
+	}{
+		{"Matching hash", hash, false},
+		{"Mismatching hash", "sha256.0123456789abcdef", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, test.WithoutPrivilege(func(t *testing.T) {
+
+			m := mockRawService{
+				t:        t,
+				code:     http.StatusOK,
+				testFile: "test_data/test_sha256",
+				httpPath: "/v1/imagefile/entity/collection/image:tag",
+			}
+
+			m.Run()
+			defer m.Stop()
+
+			dir, err := ioutil.TempDir("", "test")
+			if err != nil {
+				t.Fatalf("Error creating a temporary directory for testing")
+			}
+			defer os.RemoveAll(dir)
+
+			outFile := filepath.Join(dir, "image.sif")
+
+			err = DownloadImageHash(outFile, "entity/collection/image:tag", m.baseURI, false, "", tt.hash)
+
+			if err != nil && !tt.expectError {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if err == nil && tt.expectError {
+				t.Errorf("Unexpected success. Expected error.")
+			}
+
+			_, err = os.Stat(outFile)
+			if tt.expectError && !os.IsNotExist(err) {
+				t.Errorf("Image with mismatching hash moved to %s", outFile)
+			}
+			if _, err := os.Stat(outFile + ".partial"); !os.IsNotExist(err) {
+				t.Errorf("Partial download not removed")
+			}
+		}))
+	}
+}
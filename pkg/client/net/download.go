@@ -0,0 +1,472 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+const (
+	// DefaultParallel is the default number of concurrent range requests
+	// used to download a file
+	DefaultParallel = 4
+
+	// chunkRetries is the number of attempts to download a chunk
+	chunkRetries = 3
+)
+
+// Parallel is the number of concurrent range requests used to download
+// a file when the Downloader doesn't set it
+var Parallel = DefaultParallel
+
+// chunkSize is the size of chunks downloaded with range requests
+var chunkSize int64 = 8 << 20
+
+// Downloader retrieves files over http(s). Data are written to a temporary
+// file with the .partial suffix next to the destination, and a failed
+// download is resumed from the chunks already retrieved by the next download
+// of the same url. When the server supports range requests, chunks are
+// retrieved with concurrent requests
+type Downloader struct {
+	// Header holds headers added to requests
+	Header http.Header
+	// Parallel is the number of concurrent range requests, Parallel
+	// package variable is used if zero
+	Parallel int
+	// Verify, if set, is called with the path of the completed temporary
+	// file before it's moved to its destination
+	Verify func(path string) error
+	// CheckResponse, if set, returns the error describing an unsuccessful
+	// response
+	CheckResponse func(res *http.Response) error
+	// Timeout is the timeout of a request
+	Timeout time.Duration
+	// Validator, if set, is the ETag or Last-Modified header value the
+	// remote file must have, the download fails if the file changed
+	Validator string
+}
+
+// partialState records the progress of a download with range requests
+type partialState struct {
+	URL       string `json:"url"`
+	Validator string `json:"validator"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunkSize"`
+	Done      []bool `json:"done"`
+}
+
+// Download retrieves url into filePath
+func (d *Downloader) Download(filePath, url string) error {
+	partial := filePath + ".partial"
+	statePath := partial + ".json"
+
+	client := &http.Client{Timeout: d.Timeout}
+
+	res, err := d.get(client, url, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	if d.Validator != "" && (res.StatusCode == http.StatusOK || res.StatusCode == http.StatusPartialContent) {
+		if responseValidator(res) != d.Validator {
+			res.Body.Close()
+			return errChanged
+		}
+	}
+
+	digest := instanceDigest(res)
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		res.Body.Close()
+		err = d.downloadChunks(client, url, res, partial, statePath)
+	case http.StatusOK:
+		// server doesn't support range requests
+		sylog.Debugf("Range requests not supported by server, downloading %s in a single stream", url)
+		err = d.downloadStream(res, partial)
+		res.Body.Close()
+		os.Remove(statePath)
+	default:
+		err = d.checkResponse(res)
+		res.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	// the file assembled from chunks is checked against the checksum
+	// sent by the server
+	if digest != "" {
+		if err := checkDigest(partial, digest); err != nil {
+			// don't resume a corrupted download
+			os.Remove(partial)
+			return err
+		}
+	}
+
+	if d.Verify != nil {
+		if err := d.Verify(partial); err != nil {
+			// don't resume a corrupted download
+			os.Remove(partial)
+			return err
+		}
+	}
+
+	if err := os.Rename(partial, filePath); err != nil {
+		return fmt.Errorf("while moving %s to %s: %s", partial, filePath, err)
+	}
+
+	sylog.Debugf("Download complete\n")
+	return nil
+}
+
+func (d *Downloader) checkResponse(res *http.Response) error {
+	if d.CheckResponse != nil {
+		return d.CheckResponse(res)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("The requested image was not found in the library")
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	return fmt.Errorf("Download did not succeed: %d %s\n\t", res.StatusCode, string(b))
+}
+
+// get sends a request for length bytes at offset off
+func (d *Downloader) get(client *http.Client, url string, off, length int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range d.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", useragent.Value())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+
+	return client.Do(req)
+}
+
+// contentRange returns the first and last byte positions and the complete
+// length from the Content-Range header of a partial response
+func contentRange(res *http.Response) (start, end, size int64, err error) {
+	// Content-Range: bytes <start>-<end>/<size>
+	cr := res.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		return 0, 0, 0, fmt.Errorf("unexpected Content-Range header %q", cr)
+	}
+	if start < 0 || end < start || end >= size {
+		return 0, 0, 0, fmt.Errorf("unexpected Content-Range header %q", cr)
+	}
+	return start, end, size, nil
+}
+
+// instanceDigest returns the hex encoded SHA256 sum of the remote file
+// sent by the server in the Digest header of res (RFC 3230), it's empty
+// if the server doesn't send it
+func instanceDigest(res *http.Response) string {
+	for _, v := range res.Header["Digest"] {
+		for _, d := range strings.Split(v, ",") {
+			i := strings.Index(d, "=")
+			if i < 0 || !strings.EqualFold(strings.TrimSpace(d[:i]), "sha-256") {
+				continue
+			}
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(d[i+1:]))
+			if err != nil {
+				continue
+			}
+			return hex.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// checkDigest verifies that the SHA256 sum of the file at path is digest
+func checkDigest(path, digest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("while computing checksum of %s: %s", path, err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != digest {
+		return fmt.Errorf("checksum mismatch: downloaded file has SHA256 sum %s instead of %s", sum, digest)
+	}
+	return nil
+}
+
+// responseValidator returns the ETag or the Last-Modified header value
+// of res identifying the content of the remote file
+func responseValidator(res *http.Response) string {
+	if v := res.Header.Get("ETag"); v != "" {
+		return v
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// newBar returns a progress bar for size bytes
+func newBar(size int64) *pb.ProgressBar {
+	bar := pb.New64(size).SetUnits(pb.U_BYTES)
+	if sylog.GetLevel() < 0 {
+		bar.NotPrint = true
+	}
+	bar.ShowTimeLeft = true
+	bar.ShowSpeed = true
+	return bar
+}
+
+// downloadStream writes the response body to the partial file
+func (d *Downloader) downloadStream(res *http.Response, partial string) error {
+	// Perms are 777 *prior* to umask
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	bar := newBar(res.ContentLength)
+	bar.Start()
+
+	if _, err := io.Copy(out, bar.NewProxyReader(res.Body)); err != nil {
+		return err
+	}
+
+	bar.Finish()
+	return nil
+}
+
+// loadState returns the state of a previous download of url into the
+// partial file if it can be resumed, or a new state
+func loadState(statePath, partial, url, validator string, size int64) *partialState {
+	s := &partialState{}
+
+	if b, err := ioutil.ReadFile(statePath); err == nil && json.Unmarshal(b, s) == nil {
+		fi, err := os.Stat(partial)
+		if err == nil && validator != "" && s.URL == url && s.Validator == validator &&
+			s.Size == size && s.ChunkSize == chunkSize && fi.Size() == size {
+			return s
+		}
+		sylog.Debugf("Discarding previous partial download of %s", url)
+	}
+
+	return &partialState{
+		URL:       url,
+		Validator: validator,
+		Size:      size,
+		ChunkSize: chunkSize,
+		Done:      make([]bool, (size+chunkSize-1)/chunkSize),
+	}
+}
+
+func (s *partialState) save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// downloadChunks downloads the missing chunks of the partial file with
+// concurrent range requests
+func (d *Downloader) downloadChunks(client *http.Client, url string, res *http.Response, partial, statePath string) error {
+	_, _, size, err := contentRange(res)
+	if err != nil {
+		return fmt.Errorf("unknown size of %s: %s", url, err)
+	}
+
+	validator := responseValidator(res)
+
+	state := loadState(statePath, partial, url, validator, size)
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if state.doneSize() == 0 {
+		flags |= os.O_TRUNC
+	}
+	// Perms are 777 *prior* to umask
+	out, err := os.OpenFile(partial, flags, 0777)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := out.Truncate(size); err != nil {
+		return fmt.Errorf("while allocating %s: %s", partial, err)
+	}
+	if err := state.save(statePath); err != nil {
+		return fmt.Errorf("while saving download state: %s", err)
+	}
+
+	if state.doneSize() > 0 {
+		sylog.Infof("Resuming download of %s", url)
+	}
+
+	parallel := d.Parallel
+	if parallel <= 0 {
+		parallel = Parallel
+	}
+	if parallel <= 0 {
+		parallel = 1
+	}
+	sylog.Debugf("Downloading %d bytes with %d concurrent requests", size, parallel)
+
+	bar := newBar(size)
+	bar.Set64(state.doneSize())
+	bar.Start()
+
+	chunks := make(chan int)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				off, length := state.chunk(c)
+				err := d.downloadChunk(client, url, validator, out, off, length, bar)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil {
+					state.Done[c] = true
+					if err := state.save(statePath); err != nil && firstErr == nil {
+						firstErr = fmt.Errorf("while saving download state: %s", err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for c, done := range state.Done {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		if !done {
+			chunks <- c
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	bar.Finish()
+
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+// chunk returns the offset and length of the chunk c
+func (s *partialState) chunk(c int) (int64, int64) {
+	off := int64(c) * s.ChunkSize
+	length := s.ChunkSize
+	if off+length > s.Size {
+		length = s.Size - off
+	}
+	return off, length
+}
+
+// doneSize returns the number of bytes already downloaded
+func (s *partialState) doneSize() int64 {
+	var n int64
+	for c, done := range s.Done {
+		if done {
+			_, length := s.chunk(c)
+			n += length
+		}
+	}
+	return n
+}
+
+// downloadChunk writes length bytes at offset off of url to out, the
+// request is retried on failure
+func (d *Downloader) downloadChunk(client *http.Client, url, validator string, out *os.File, off, length int64, bar *pb.ProgressBar) (err error) {
+	for i := 0; i < chunkRetries; i++ {
+		if i > 0 {
+			sylog.Debugf("Retrying download of bytes %d-%d: %s", off, off+length-1, err)
+		}
+
+		var n int64
+		n, err = d.fetchChunk(client, url, validator, out, off, length)
+		bar.Add64(n)
+		if err == nil {
+			return nil
+		}
+		// progress of the failed attempt is downloaded again
+		bar.Add64(-n)
+		if err == errChanged {
+			break
+		}
+	}
+	return err
+}
+
+var errChanged = fmt.Errorf("remote file changed during download")
+
+func (d *Downloader) fetchChunk(client *http.Client, url, validator string, out *os.File, off, length int64) (int64, error) {
+	res, err := d.get(client, url, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return 0, d.checkResponse(res)
+	}
+	if validator != "" && validator != res.Header.Get("ETag") && validator != res.Header.Get("Last-Modified") {
+		return 0, errChanged
+	}
+	if start, end, _, err := contentRange(res); err != nil {
+		return 0, err
+	} else if start != off || end != off+length-1 {
+		return 0, fmt.Errorf("server returned bytes %d-%d instead of %d-%d", start, end, off, off+length-1)
+	}
+
+	b := make([]byte, length)
+	n, err := io.ReadFull(res.Body, b)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := out.WriteAt(b, off); err != nil {
+		if err == syscall.ENOSPC {
+			return 0, fmt.Errorf("no space left to write %s", out.Name())
+		}
+		return 0, err
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+func TestDownloader(t *testing.T) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	defer func(size int64) { chunkSize = size }(chunkSize)
+	chunkSize = 1024
+
+	content := make([]byte, 10*chunkSize+100)
+	rand.Read(content)
+	modTime := time.Now()
+	sum := sha256.Sum256(content)
+	badSum := sha256.Sum256(content[1:])
+
+	var (
+		mu       sync.Mutex
+		requests int
+		failFrom = -1
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		fail := failFrom >= 0 && requests > failFrom
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/image.sif":
+			if fail {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			http.ServeContent(w, r, "image.sif", modTime, bytes.NewReader(content))
+		case "/norange.sif":
+			w.Write(content)
+		case "/badrange.sif":
+			// chunks are served from the start of the file
+			if rg := r.Header.Get("Range"); rg != "bytes=0-0" {
+				var start, end int64
+				fmt.Sscanf(rg, "bytes=%d-%d", &start, &end)
+				r.Header.Set("Range", fmt.Sprintf("bytes=0-%d", end-start))
+			}
+			http.ServeContent(w, r, "badrange.sif", modTime, bytes.NewReader(content))
+		case "/digest.sif":
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+			http.ServeContent(w, r, "digest.sif", modTime, bytes.NewReader(content))
+		case "/baddigest.sif":
+			w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(badSum[:]))
+			http.ServeContent(w, r, "baddigest.sif", modTime, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "download-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	check := func(path string) {
+		t.Helper()
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !bytes.Equal(b, content) {
+			t.Errorf("unexpected content of %s", path)
+		}
+		for _, suffix := range []string{".partial", ".partial.json"} {
+			if _, err := os.Stat(path + suffix); !os.IsNotExist(err) {
+				t.Errorf("%s%s not removed", path, suffix)
+			}
+		}
+	}
+
+	d := &Downloader{Parallel: 3}
+
+	// parallel download with range requests
+	dst := filepath.Join(dir, "image.sif")
+	if err := d.Download(dst, srv.URL+"/image.sif"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(dst)
+
+	// single stream download
+	dst = filepath.Join(dir, "norange.sif")
+	if err := d.Download(dst, srv.URL+"/norange.sif"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(dst)
+
+	if err := d.Download(filepath.Join(dir, "missing.sif"), srv.URL+"/missing.sif"); err == nil {
+		t.Errorf("unexpected success with missing image")
+	}
+
+	// interrupted download is resumed
+	dst = filepath.Join(dir, "resumed.sif")
+	d.Parallel = 1
+	requests = 0
+	failFrom = 4
+	if err := d.Download(dst, srv.URL+"/image.sif"); err == nil {
+		t.Fatalf("unexpected success with server error")
+	}
+	if _, err := os.Stat(dst + ".partial.json"); err != nil {
+		t.Fatalf("download state not kept: %s", err)
+	}
+	requests = 0
+	failFrom = -1
+	if err := d.Download(dst, srv.URL+"/image.sif"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(dst)
+	// probe request and the chunks not retrieved by the first download
+	if requests != 1+11-3 {
+		t.Errorf("unexpected %d requests to resume download", requests)
+	}
+
+	// verification failure
+	dst = filepath.Join(dir, "corrupted.sif")
+	d.Verify = func(path string) error {
+		return fmt.Errorf("hash mismatch")
+	}
+	if err := d.Download(dst, srv.URL+"/image.sif"); err == nil {
+		t.Errorf("unexpected success with verification failure")
+	}
+	for _, path := range []string{dst, dst + ".partial"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed after verification failure", path)
+		}
+	}
+	d.Verify = nil
+
+	// remote file identified by its validator
+	validator, err := Validator(srv.URL + "/image.sif")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if validator != modTime.UTC().Format(http.TimeFormat) {
+		t.Errorf("unexpected validator %q", validator)
+	}
+	if validator, err := Validator(srv.URL + "/norange.sif"); err != nil || validator != "" {
+		t.Errorf("unexpected validator %q: %v", validator, err)
+	}
+	if _, err := Validator(srv.URL + "/missing.sif"); err == nil {
+		t.Errorf("unexpected success with missing image")
+	}
+
+	dst = filepath.Join(dir, "validated.sif")
+	d.Validator = validator
+	if err := d.Download(dst, srv.URL+"/image.sif"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(dst)
+
+	dst = filepath.Join(dir, "changed.sif")
+	d.Validator = "changed"
+	if err := d.Download(dst, srv.URL+"/image.sif"); err != errChanged {
+		t.Errorf("unexpected error with changed remote file: %v", err)
+	}
+	d.Validator = ""
+
+	// server returning the wrong ranges
+	dst = filepath.Join(dir, "badrange.sif")
+	if err := d.Download(dst, srv.URL+"/badrange.sif"); err == nil {
+		t.Errorf("unexpected success with wrong ranges")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("%s assembled from wrong ranges", dst)
+	}
+
+	// assembled file checked against the server checksum
+	d.Parallel = 3
+	dst = filepath.Join(dir, "digest.sif")
+	if err := d.Download(dst, srv.URL+"/digest.sif"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	check(dst)
+
+	dst = filepath.Join(dir, "baddigest.sif")
+	if err := d.Download(dst, srv.URL+"/baddigest.sif"); err == nil {
+		t.Errorf("unexpected success with checksum mismatch")
+	}
+	for _, path := range []string{dst, dst + ".partial"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed after checksum mismatch", path)
+		}
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	defer func(size int64) { chunkSize = size }(chunkSize)
+	chunkSize = 10
+
+	dir, err := ioutil.TempDir("", "download-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	partial := filepath.Join(dir, "image.sif.partial")
+	statePath := partial + ".json"
+	const url = "https://example.com/image.sif"
+
+	s := loadState(statePath, partial, url, "v1", 25)
+	if len(s.Done) != 3 || s.doneSize() != 0 {
+		t.Fatalf("unexpected new state %+v", s)
+	}
+	if off, length := s.chunk(2); off != 20 || length != 5 {
+		t.Errorf("unexpected last chunk %d-%d", off, length)
+	}
+
+	s.Done[2] = true
+	if err := s.save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(partial, make([]byte, 25), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := loadState(statePath, partial, url, "v1", 25); s.doneSize() != 5 {
+		t.Errorf("state not resumed")
+	}
+	if s := loadState(statePath, partial, url, "v2", 25); s.doneSize() != 0 {
+		t.Errorf("state resumed with changed validator")
+	}
+	if s := loadState(statePath, partial, url, "", 25); s.doneSize() != 0 {
+		t.Errorf("state resumed without validator")
+	}
+	if s := loadState(statePath, partial, url+"2", "v1", 25); s.doneSize() != 0 {
+		t.Errorf("state resumed with different url")
+	}
+}
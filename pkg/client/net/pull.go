@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,9 +6,7 @@
 package client
 
 import (
-	"bytes"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"regexp"
@@ -17,7 +15,6 @@ import (
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
-	"gopkg.in/cheggaaa/pb.v1"
 )
 
 // Timeout for an image pull in seconds - could be a large download...
@@ -52,68 +49,46 @@ func DownloadImage(filePath string, libraryURL string, Force bool) error {
 		}
 	}
 
-	client := &http.Client{
+	d := &Downloader{
 		Timeout: pullTimeout * time.Second,
 	}
+	return d.Download(filePath, url)
+}
+
+// Validator returns the ETag or Last-Modified header value identifying the
+// content of the remote file at url, it's empty if the server returns none
+func Validator(url string) (string, error) {
+	if !IsNetPullRef(url) {
+		return "", fmt.Errorf("Not a valid url reference: %s", url)
+	}
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
-
 	req.Header.Set("User-Agent", useragent.Value())
+	req.Header.Set("Range", "bytes=0-0")
 
+	client := &http.Client{Timeout: rangeTimeout * time.Second}
 	res, err := client.Do(req)
 	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("The requested image was not found in the library")
-	}
-
-	if res.StatusCode != http.StatusOK {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(res.Body)
-		s := buf.String()
-		return fmt.Errorf("Download did not succeed: %d %s\n\t",
-			res.StatusCode, s)
-	}
-
-	sylog.Debugf("OK response received, beginning body download\n")
-
-	// Perms are 777 *prior* to umask
-	out, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0777)
-	if err != nil {
-		return err
+		return "", err
 	}
-	defer out.Close()
-
-	sylog.Debugf("Created output file: %s\n", filePath)
+	res.Body.Close()
 
-	bodySize := res.ContentLength
-	bar := pb.New(int(bodySize)).SetUnits(pb.U_BYTES)
-	if sylog.GetLevel() < 0 {
-		bar.NotPrint = true
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("unexpected response for %s: %s", url, res.Status)
 	}
-	bar.ShowTimeLeft = true
-	bar.ShowSpeed = true
-	bar.Start()
-
-	// create proxy reader
-	bodyProgress := bar.NewProxyReader(res.Body)
+	return responseValidator(res), nil
+}
 
-	// Write the body to file
-	_, err = io.Copy(out, bodyProgress)
-	if err != nil {
-		return err
+// DownloadValidatedImage retrieves the remote file at url into filePath,
+// the download fails if the remote file isn't identified by validator as
+// returned by Validator anymore
+func DownloadValidatedImage(filePath, url, validator string) error {
+	d := &Downloader{
+		Timeout:   pullTimeout * time.Second,
+		Validator: validator,
 	}
-
-	bar.Finish()
-
-	sylog.Debugf("Download complete\n")
-
-	return nil
-
+	return d.Download(filePath, url)
 }
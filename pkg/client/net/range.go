@@ -0,0 +1,116 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+// Timeout for a range request in seconds
+const rangeTimeout = 300
+
+// RangeReader reads a remote file with HTTP range requests
+type RangeReader struct {
+	// URL is the remote file location
+	URL string
+	// Size is the size of the remote file
+	Size int64
+	// Validator identifies the remote file content, it's the ETag or
+	// the Last-Modified header value returned by the server
+	Validator string
+
+	client *http.Client
+}
+
+// NewRangeReader checks that the server supports range requests for url and
+// returns a RangeReader for it
+func NewRangeReader(url string) (*RangeReader, error) {
+	if !IsNetPullRef(url) {
+		return nil, fmt.Errorf("Not a valid url reference: %s", url)
+	}
+
+	r := &RangeReader{
+		URL: url,
+		client: &http.Client{
+			Timeout: rangeTimeout * time.Second,
+		},
+	}
+
+	res, err := r.get(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("server doesn't support range requests for %s", url)
+	}
+
+	_, _, r.Size, err = contentRange(res)
+	if err != nil {
+		return nil, fmt.Errorf("unknown size of %s: %s", url, err)
+	}
+
+	r.Validator = responseValidator(res)
+
+	return r, nil
+}
+
+// ReadAt reads len(p) bytes at offset off of the remote file with
+// a range request
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.Size {
+		return 0, io.EOF
+	}
+
+	res, err := r.get(off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("range request failed: %s", res.Status)
+	}
+	if r.Validator != "" && r.Validator != res.Header.Get("ETag") && r.Validator != res.Header.Get("Last-Modified") {
+		return 0, fmt.Errorf("remote file %s changed", r.URL)
+	}
+	end := off + int64(len(p)) - 1
+	if end >= r.Size {
+		end = r.Size - 1
+	}
+	if start, last, _, err := contentRange(res); err != nil {
+		return 0, err
+	} else if start != off || last != end {
+		return 0, fmt.Errorf("server returned bytes %d-%d instead of %d-%d", start, last, off, end)
+	}
+
+	n, err := io.ReadFull(res.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// get sends a request for length bytes at offset off
+func (r *RangeReader) get(off, length int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", useragent.Value())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+
+	sylog.Debugf("Requesting bytes %d-%d of %s", off, off+length-1, r.URL)
+
+	return r.client.Do(req)
+}
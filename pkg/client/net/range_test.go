@@ -0,0 +1,95 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+func TestRangeReader(t *testing.T) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	content := []byte("0123456789abcdef")
+	modTime := time.Now()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.sif":
+			http.ServeContent(w, r, "image.sif", modTime, bytes.NewReader(content))
+		case "/norange.sif":
+			w.Write(content)
+		case "/badrange.sif":
+			// ranges are served from the start of the file
+			if rg := r.Header.Get("Range"); rg != "bytes=0-0" {
+				var start, end int64
+				fmt.Sscanf(rg, "bytes=%d-%d", &start, &end)
+				r.Header.Set("Range", fmt.Sprintf("bytes=0-%d", end-start))
+			}
+			http.ServeContent(w, r, "badrange.sif", modTime, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := NewRangeReader("file:///image.sif"); err == nil {
+		t.Errorf("unexpected success with non http url")
+	}
+	if _, err := NewRangeReader(srv.URL + "/norange.sif"); err == nil {
+		t.Errorf("unexpected success with server not supporting range requests")
+	}
+	if _, err := NewRangeReader(srv.URL + "/missing.sif"); err == nil {
+		t.Errorf("unexpected success with missing image")
+	}
+
+	r, err := NewRangeReader(srv.URL + "/image.sif")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.Size != int64(len(content)) {
+		t.Errorf("unexpected size %d", r.Size)
+	}
+	if r.Validator == "" {
+		t.Errorf("empty validator")
+	}
+
+	p := make([]byte, 4)
+	if n, err := r.ReadAt(p, 10); err != nil || n != 4 || string(p) != "abcd" {
+		t.Errorf("unexpected read %q (%d bytes): %v", p, n, err)
+	}
+	if n, err := r.ReadAt(p, 14); err != io.EOF || n != 2 || string(p[:n]) != "ef" {
+		t.Errorf("unexpected read at end %q (%d bytes): %v", p[:n], n, err)
+	}
+	if _, err := r.ReadAt(p, 16); err != io.EOF {
+		t.Errorf("unexpected error for read past end: %v", err)
+	}
+
+	// server returning the wrong range
+	br, err := NewRangeReader(srv.URL + "/badrange.sif")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := br.ReadAt(p, 10); err == nil {
+		t.Errorf("unexpected success with wrong range")
+	}
+	if n, err := br.ReadAt(p, 0); err != nil || n != 4 || string(p) != "0123" {
+		t.Errorf("unexpected read %q (%d bytes): %v", p, n, err)
+	}
+
+	// remote image modified
+	modTime = modTime.Add(time.Hour)
+	if _, err := r.ReadAt(p, 0); err == nil {
+		t.Errorf("unexpected success with modified image")
+	}
+}
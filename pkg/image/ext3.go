@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"unsafe"
 )
@@ -36,8 +37,40 @@ type extFSInfo struct {
 	Rocompat uint32
 }
 
+// extSuperBlock is the beginning of ext2/3/4 super block
+type extSuperBlock struct {
+	InodesCount     uint32
+	BlocksCount     uint32
+	RBlocksCount    uint32
+	FreeBlocksCount uint32
+	FreeInodesCount uint32
+	FirstDataBlock  uint32
+	LogBlockSize    uint32
+}
+
+// extSuperBlockOffset is the offset of super block in ext3 partition
+const extSuperBlockOffset = 1024
+
 type ext3Format struct{}
 
+// GetExt3Usage returns the size and the free space in bytes of
+// the ext3 filesystem located at offset in r
+func GetExt3Usage(r io.ReaderAt, offset uint64) (uint64, uint64, error) {
+	b := make([]byte, unsafe.Sizeof(extSuperBlock{}))
+
+	if _, err := r.ReadAt(b, int64(offset+extSuperBlockOffset)); err != nil {
+		return 0, 0, fmt.Errorf("can't read ext3 super block: %s", err)
+	}
+
+	sb := &extSuperBlock{}
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, sb); err != nil {
+		return 0, 0, fmt.Errorf("can't read ext3 super block: %s", err)
+	}
+
+	blockSize := uint64(extSuperBlockOffset) << sb.LogBlockSize
+	return uint64(sb.BlocksCount) * blockSize, uint64(sb.FreeBlocksCount) * blockSize, nil
+}
+
 // CheckExt3Header checks if byte content contains a valid ext3 header
 // and returns offset where ext3 partition begin
 func CheckExt3Header(b []byte) (uint64, error) {
@@ -0,0 +1,54 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestGetExt3Usage(t *testing.T) {
+	mkfs, err := exec.LookPath("mkfs.ext3")
+	if err != nil {
+		mkfs, err = exec.LookPath("/sbin/mkfs.ext3")
+		if err != nil {
+			t.Skip("mkfs.ext3 not found")
+		}
+	}
+
+	f, err := ioutil.TempFile("", "ext3-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	const size = 16 * 1024 * 1024
+
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(mkfs, "-q", "-F", "-b", "4096", f.Name()).CombinedOutput(); err != nil {
+		t.Fatalf("mkfs.ext3 failed: %s: %s", err, out)
+	}
+
+	total, free, err := GetExt3Usage(f, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if total != size {
+		t.Errorf("unexpected filesystem size %d instead of %d", total, size)
+	}
+	if free == 0 || free >= total {
+		t.Errorf("unexpected free space %d for filesystem size %d", free, total)
+	}
+
+	if _, _, err := GetExt3Usage(f, size); err == nil {
+		t.Errorf("unexpected success while reading beyond image")
+	}
+}
@@ -18,6 +18,16 @@ const (
 	sifMagic = "\x53\x49\x46\x5f\x4d\x41\x47\x49\x43"
 )
 
+// SIF definitions not provided by the SIF library
+const (
+	// SIFFsEncryptedSquashfs is the filesystem type of LUKS2
+	// encrypted squashfs partitions
+	SIFFsEncryptedSquashfs sif.Fstype = sif.FsRaw + 1
+	// SIFDataCryptoMessage is the data type of cryptographic
+	// messages like wrapped encryption keys
+	SIFDataCryptoMessage sif.Datatype = sif.DataGenericJSON + 1
+)
+
 type sifFormat struct{}
 
 func (f *sifFormat) initializer(img *Image, fileinfo os.FileInfo) error {
@@ -53,6 +63,8 @@ func (f *sifFormat) initializer(img *Image, fileinfo os.FileInfo) error {
 		img.Partitions[0].Type = SQUASHFS
 	} else if fstype == sif.FsExt3 {
 		img.Partitions[0].Type = EXT3
+	} else if fstype == SIFFsEncryptedSquashfs {
+		img.Partitions[0].Type = ENCRYPTSQUASHFS
 	} else {
 		return fmt.Errorf("unknown file system type: %v", fstype)
 	}
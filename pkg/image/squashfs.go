@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"unsafe"
 
@@ -88,9 +89,16 @@ func (f *squashfsFormat) initializer(img *Image, fileinfo os.FileInfo) error {
 	if err != nil {
 		return err
 	}
+	size := fileinfo.Size()
+	if fileinfo.Mode()&os.ModeDevice != 0 {
+		// block devices report a zero size
+		if size, err = img.File.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("can't get block device size: %s", err)
+		}
+	}
 	img.Type = SQUASHFS
 	img.Partitions[0].Offset = offset
-	img.Partitions[0].Size = uint64(fileinfo.Size()) - offset
+	img.Partitions[0].Size = uint64(size) - offset
 	img.Partitions[0].Type = SQUASHFS
 	img.Partitions[0].Name = RootFs
 
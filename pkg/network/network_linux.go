@@ -6,6 +6,7 @@
 package network
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -58,6 +59,54 @@ type PortMapEntry struct {
 	HostIP        string `json:"hostIP,omitempty"`
 }
 
+// ParsePortMap parses a port mapping of the form
+// hostPort[:containerPort][/protocol], the container port defaults
+// to the host port and the protocol defaults to tcp
+func ParsePortMap(value string) (*PortMapEntry, error) {
+	pm := &PortMapEntry{Protocol: "tcp"}
+
+	splittedPort := strings.SplitN(value, "/", 2)
+	if len(splittedPort) == 2 {
+		pm.Protocol = splittedPort[1]
+		if pm.Protocol != "tcp" && pm.Protocol != "udp" {
+			return nil, fmt.Errorf("only tcp and udp protocol can be specified")
+		}
+	}
+	ports := strings.Split(splittedPort[0], ":")
+	if len(ports) != 1 && len(ports) != 2 {
+		return nil, fmt.Errorf("badly formatted port mapping '%s', must be of form hostPort:containerPort/protocol", value)
+	}
+
+	hostPort, err := parsePort(ports[0])
+	if err != nil {
+		return nil, fmt.Errorf("can't convert host port '%s': %s", ports[0], err)
+	}
+	pm.HostPort = hostPort
+	pm.ContainerPort = hostPort
+
+	if len(ports) == 2 {
+		containerPort, err := parsePort(ports[1])
+		if err != nil {
+			return nil, fmt.Errorf("can't convert container port '%s': %s", ports[1], err)
+		}
+		pm.ContainerPort = containerPort
+	}
+
+	return pm, nil
+}
+
+// parsePort parses a port number in range [1-65535]
+func parsePort(port string) (int, error) {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("port must be greater than zero")
+	}
+	return int(n), nil
+}
+
 // GetAllNetworkConfigList lists configured networks in configuration path directory
 // provided by cniPath
 func GetAllNetworkConfigList(cniPath *CNIPath) ([]*libcni.NetworkConfigList, error) {
@@ -101,6 +150,114 @@ func GetAllNetworkConfigList(cniPath *CNIPath) ([]*libcni.NetworkConfigList, err
 	return networks, nil
 }
 
+// ConfListConfig describes a network configuration list
+// generated by NewConfList
+type ConfListConfig struct {
+	// Name of the network
+	Name string
+	// Type is the main plugin type: bridge, ptp or macvlan
+	Type string
+	// Subnet allocated to containers in CIDR notation
+	Subnet string
+	// Gateway IP address, optional
+	Gateway string
+	// Bridge interface name for bridge network, optional
+	Bridge string
+	// Master host interface for macvlan network
+	Master string
+}
+
+// NewConfList generates a network configuration list using host-local
+// IP address management, bridge and ptp networks also support port
+// mappings
+func NewConfList(c ConfListConfig) ([]byte, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("network name is required")
+	}
+	if strings.ContainsAny(c.Name, "/ ") {
+		return nil, fmt.Errorf("network name %q contains forbidden characters", c.Name)
+	}
+
+	_, subnet, err := net.ParseCIDR(c.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("bad subnet %q: %s", c.Subnet, err)
+	}
+
+	ipam := map[string]interface{}{
+		"type":   "host-local",
+		"subnet": subnet.String(),
+		"routes": []map[string]string{
+			{"dst": "0.0.0.0/0"},
+		},
+	}
+	if c.Gateway != "" {
+		gateway := net.ParseIP(c.Gateway)
+		if gateway == nil {
+			return nil, fmt.Errorf("bad gateway IP address %q", c.Gateway)
+		}
+		if !subnet.Contains(gateway) {
+			return nil, fmt.Errorf("gateway %s is not in subnet %s", gateway, subnet)
+		}
+		ipam["gateway"] = gateway.String()
+	}
+
+	plugin := map[string]interface{}{
+		"type": c.Type,
+		"ipam": ipam,
+	}
+	portMap := true
+
+	switch c.Type {
+	case "bridge":
+		bridge := c.Bridge
+		if bridge == "" {
+			bridge = "sbr-" + c.Name
+		}
+		// interface names are limited to 15 characters
+		if len(bridge) > 15 {
+			bridge = bridge[:15]
+		}
+		plugin["bridge"] = bridge
+		plugin["isGateway"] = true
+		plugin["ipMasq"] = true
+	case "ptp":
+		plugin["ipMasq"] = true
+	case "macvlan":
+		if c.Master == "" {
+			return nil, fmt.Errorf("macvlan network requires a master interface")
+		}
+		plugin["master"] = c.Master
+		portMap = false
+	default:
+		return nil, fmt.Errorf("unsupported network type %q, must be bridge, ptp or macvlan", c.Type)
+	}
+
+	plugins := []interface{}{plugin}
+	if portMap {
+		plugins = append(plugins, map[string]interface{}{
+			"type":         "portmap",
+			"capabilities": map[string]bool{"portMappings": true},
+			"snat":         true,
+		})
+	}
+
+	conf := map[string]interface{}{
+		"cniVersion": "0.3.1",
+		"name":       c.Name,
+		"plugins":    plugins,
+	}
+
+	data, err := json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := libcni.ConfListFromBytes(data); err != nil {
+		return nil, fmt.Errorf("generated configuration is invalid: %s", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
 // NewSetup creates and returns a network setup to configure, add and remove
 // network interfaces in container
 func NewSetup(networks []string, containerID string, netNS string, cniPath *CNIPath) (*Setup, error) {
@@ -223,6 +380,42 @@ func (m *Setup) SetCapability(network string, capName string, args interface{})
 	return nil
 }
 
+// SetInterfaceName sets the container interface name used for a
+// configured network, by default interfaces are named ethX where X
+// is the network index in the list
+func (m *Setup) SetInterfaceName(network string, ifname string) error {
+	for i := range m.networks {
+		if m.networks[i] == network {
+			m.runtimeConf[i].IfName = ifname
+			return nil
+		}
+	}
+	return fmt.Errorf("network %s is not configured", network)
+}
+
+// SetPortMaps publishes ports on all configured networks, networks
+// must use the portmap plugin to support port publishing
+func (m *Setup) SetPortMaps(ports []*PortMapEntry) error {
+	for i, network := range m.networks {
+		hasPortMap := false
+		for _, plugin := range m.networkConfList[i].Plugins {
+			if plugin.Network.Type == "portmap" {
+				hasPortMap = true
+				break
+			}
+		}
+		if !hasPortMap {
+			return fmt.Errorf("%s network doesn't use portmap plugin, can't publish ports", network)
+		}
+		for _, pm := range ports {
+			if err := m.SetCapability(network, "portMappings", *pm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // SetArgs affects arguments to corresponding network plugins
 func (m *Setup) SetArgs(args []string) error {
 	if len(m.networks) < 1 {
@@ -265,39 +458,9 @@ func (m *Setup) SetArgs(args []string) error {
 			key := kv[0]
 			value := kv[1]
 			if key == "portmap" {
-				pm := &PortMapEntry{}
-
-				splittedPort := strings.SplitN(value, "/", 2)
-				if len(splittedPort) != 2 {
-					return fmt.Errorf("badly formatted portmap argument '%s', must be of form portmap=hostPort:containerPort/protocol", value)
-				}
-				pm.Protocol = splittedPort[1]
-				if pm.Protocol != "tcp" && pm.Protocol != "udp" {
-					return fmt.Errorf("only tcp and udp protocol can be specified")
-				}
-				ports := strings.Split(splittedPort[0], ":")
-				if len(ports) != 1 && len(ports) != 2 {
-					return fmt.Errorf("portmap port argument is badly formatted")
-				}
-				if n, err := strconv.ParseInt(ports[0], 0, 16); err == nil {
-					pm.HostPort = int(n)
-					if pm.HostPort <= 0 {
-						return fmt.Errorf("host port must be greater than zero")
-					}
-				} else {
-					return fmt.Errorf("can't convert host port '%s': %s", ports[0], err)
-				}
-				if len(ports) == 2 {
-					if n, err := strconv.ParseInt(ports[1], 0, 16); err == nil {
-						pm.ContainerPort = int(n)
-						if pm.ContainerPort <= 0 {
-							return fmt.Errorf("container port must be greater than zero")
-						}
-					} else {
-						return fmt.Errorf("can't convert container port '%s': %s", ports[1], err)
-					}
-				} else {
-					pm.ContainerPort = pm.HostPort
+				pm, err := ParsePortMap(value)
+				if err != nil {
+					return err
 				}
 				if err := m.SetCapability(networkName, "portMappings", *pm); err != nil {
 					return err
@@ -277,6 +277,176 @@ func testSetArgs(setup *Setup, t *testing.T) {
 	}
 }
 
+func testSetPortMaps(setup *Setup, t *testing.T) {
+	ports := []*PortMapEntry{
+		{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+	}
+
+	if err := setup.SetPortMaps(ports); err != nil {
+		t.Errorf("unexpected failure while publishing ports: %s", err)
+	}
+
+	noPortMap, err := NewSetup([]string{"test-badbridge"}, "", "/proc/self/net/ns", setup.cniPath)
+	if err != nil {
+		t.Fatalf("unexpected failure for test-badbridge setup: %s", err)
+	}
+	if err := noPortMap.SetPortMaps(ports); err == nil {
+		t.Errorf("unexpected success while publishing ports on network without portmap plugin")
+	}
+}
+
+func TestNewConfList(t *testing.T) {
+	var testConfs = []struct {
+		desc    string
+		config  ConfListConfig
+		plugins []string
+		success bool
+	}{
+		{
+			desc:    "no name",
+			config:  ConfListConfig{Type: "bridge", Subnet: "10.30.0.0/16"},
+			success: false,
+		},
+		{
+			desc:    "bad subnet",
+			config:  ConfListConfig{Name: "test", Type: "bridge", Subnet: "10.30.0.0"},
+			success: false,
+		},
+		{
+			desc:    "gateway outside subnet",
+			config:  ConfListConfig{Name: "test", Type: "bridge", Subnet: "10.30.0.0/16", Gateway: "10.31.0.1"},
+			success: false,
+		},
+		{
+			desc:    "unknown type",
+			config:  ConfListConfig{Name: "test", Type: "vxlan", Subnet: "10.30.0.0/16"},
+			success: false,
+		},
+		{
+			desc:    "macvlan without master",
+			config:  ConfListConfig{Name: "test", Type: "macvlan", Subnet: "10.30.0.0/16"},
+			success: false,
+		},
+		{
+			desc:    "bridge",
+			config:  ConfListConfig{Name: "test", Type: "bridge", Subnet: "10.30.0.0/16", Gateway: "10.30.0.1"},
+			plugins: []string{"bridge", "portmap"},
+			success: true,
+		},
+		{
+			desc:    "ptp",
+			config:  ConfListConfig{Name: "test", Type: "ptp", Subnet: "10.30.0.0/16"},
+			plugins: []string{"ptp", "portmap"},
+			success: true,
+		},
+		{
+			desc:    "macvlan",
+			config:  ConfListConfig{Name: "test", Type: "macvlan", Subnet: "192.168.1.0/24", Master: "eth0"},
+			plugins: []string{"macvlan"},
+			success: true,
+		},
+	}
+
+	for _, c := range testConfs {
+		data, err := NewConfList(c.config)
+		if err != nil && c.success {
+			t.Errorf("unexpected failure for %q test: %s", c.desc, err)
+			continue
+		} else if err == nil && !c.success {
+			t.Errorf("unexpected success for %q test", c.desc)
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		conf, err := libcni.ConfListFromBytes(data)
+		if err != nil {
+			t.Errorf("unexpected failure while parsing %q test configuration: %s", c.desc, err)
+			continue
+		}
+		if conf.Name != c.config.Name {
+			t.Errorf("unexpected network name %s for %q test", conf.Name, c.desc)
+		}
+		plugins := make([]string, len(conf.Plugins))
+		for i, p := range conf.Plugins {
+			plugins[i] = p.Network.Type
+		}
+		if !reflect.DeepEqual(plugins, c.plugins) {
+			t.Errorf("unexpected plugins %v instead of %v for %q test", plugins, c.plugins, c.desc)
+		}
+	}
+}
+
+func TestParsePortMap(t *testing.T) {
+	var testPorts = []struct {
+		desc    string
+		value   string
+		entry   *PortMapEntry
+		success bool
+	}{
+		{
+			desc:    "empty",
+			value:   "",
+			success: false,
+		},
+		{
+			desc:    "host port only",
+			value:   "8080",
+			entry:   &PortMapEntry{HostPort: 8080, ContainerPort: 8080, Protocol: "tcp"},
+			success: true,
+		},
+		{
+			desc:    "host and container ports",
+			value:   "8080:80",
+			entry:   &PortMapEntry{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+			success: true,
+		},
+		{
+			desc:    "udp protocol",
+			value:   "5353:53/udp",
+			entry:   &PortMapEntry{HostPort: 5353, ContainerPort: 53, Protocol: "udp"},
+			success: true,
+		},
+		{
+			desc:    "high port",
+			value:   "65535:40000/tcp",
+			entry:   &PortMapEntry{HostPort: 65535, ContainerPort: 40000, Protocol: "tcp"},
+			success: true,
+		},
+		{
+			desc:    "unknown protocol",
+			value:   "80/icmp",
+			success: false,
+		},
+		{
+			desc:    "port 0",
+			value:   "0:80",
+			success: false,
+		},
+		{
+			desc:    "port out of range",
+			value:   "80:65536",
+			success: false,
+		},
+		{
+			desc:    "too many ports",
+			value:   "80:80:80",
+			success: false,
+		},
+	}
+
+	for _, p := range testPorts {
+		entry, err := ParsePortMap(p.value)
+		if err != nil && p.success {
+			t.Errorf("unexpected failure for %q test: %s", p.desc, err)
+		} else if err == nil && !p.success {
+			t.Errorf("unexpected success for %q test", p.desc)
+		} else if err == nil && !reflect.DeepEqual(entry, p.entry) {
+			t.Errorf("unexpected entry for %q test: %+v instead of %+v", p.desc, entry, p.entry)
+		}
+	}
+}
+
 func TestNewSetup(t *testing.T) {
 	test.EnsurePrivilege(t)
 
@@ -323,6 +493,7 @@ func TestNewSetup(t *testing.T) {
 			nspath:   "/proc/self/net/ns",
 			cniPath:  cniPath,
 			success:  true,
+			subTest:  testSetPortMaps,
 		},
 		{
 			desc:     "good networks",
@@ -0,0 +1,125 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// detachedSignature is a signature block of a detached signature file, it
+// holds the same data as a signature descriptor
+type detachedSignature struct {
+	// Link is the ID of the signed data object, or the signed group ID
+	// with the group mask set
+	Link uint32 `json:"link"`
+	// HashType is the hash type of the signature block
+	HashType sif.Hashtype `json:"hashType"`
+	// Entity is the fingerprint of the signing key or certificate
+	Entity string `json:"entity"`
+	// Data is the ASCII signature block
+	Data string `json:"data"`
+}
+
+// detachedFile is the content of a detached signature file
+type detachedFile struct {
+	// ImageID is the unique identifier of the signed SIF image
+	ImageID string `json:"imageID"`
+	// Signatures are the signature blocks of the image data objects
+	Signatures []detachedSignature `json:"signatures"`
+}
+
+// readDetached reads the detached signature file at path and checks that
+// it holds signatures of fimg
+func readDetached(path string, fimg *sif.FileImage) (*detachedFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &detachedFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("could not parse detached signature file %s: %s", path, err)
+	}
+	if id := fimg.Header.ID.String(); f.ImageID != id {
+		return nil, fmt.Errorf("%s holds signatures of image %s instead of %s", path, f.ImageID, id)
+	}
+	return f, nil
+}
+
+// writeDetached adds the signature blocks of fimg to the detached signature
+// file at path, which is created if it doesn't exist
+func writeDetached(path string, fimg *sif.FileImage, blocks []signatureBlock) error {
+	f, err := readDetached(path, fimg)
+	if os.IsNotExist(err) {
+		f = &detachedFile{ImageID: fimg.Header.ID.String()}
+	} else if err != nil {
+		return err
+	}
+
+	for _, b := range blocks {
+		f.Signatures = append(f.Signatures, detachedSignature{
+			Link:     b.link,
+			HashType: b.hashtype,
+			Entity:   b.entity,
+			Data:     string(b.data),
+		})
+	}
+
+	data, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write detached signature file: %s", err)
+	}
+	return nil
+}
+
+// detachedSignatures returns the signature blocks of the detached signature
+// file of opts for the data object(s) selected by opts
+func detachedSignatures(fimg *sif.FileImage, opts VerifyOptions) ([]signatureBlock, error) {
+	f, err := readDetached(opts.Signature, fimg)
+	if err != nil {
+		return nil, err
+	}
+
+	var link uint32
+	switch {
+	case opts.All:
+	case opts.ID == 0:
+		descr, _, err := fimg.GetPartPrimSys()
+		if err != nil {
+			return nil, fmt.Errorf("no primary partition found")
+		}
+		link = descr.ID
+	case opts.IsGroup:
+		link = opts.ID | sif.DescrGroupMask
+	default:
+		link = opts.ID
+	}
+
+	var blocks []signatureBlock
+	for i, s := range f.Signatures {
+		if !opts.All && s.Link != link {
+			continue
+		}
+		blocks = append(blocks, signatureBlock{
+			id:       uint32(i + 1),
+			link:     s.Link,
+			hashtype: s.HashType,
+			entity:   s.Entity,
+			data:     []byte(s.Data),
+		})
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no signatures found in %s", opts.Signature)
+	}
+	return blocks, nil
+}
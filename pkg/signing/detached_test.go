@@ -0,0 +1,159 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"golang.org/x/crypto/openpgp"
+)
+
+// createContainer creates a SIF file at path with a primary partition and
+// a definition file
+func createContainer(t *testing.T, path string) {
+	part := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Data:     bytes.Repeat([]byte{'p'}, 4096),
+	}
+	part.Size = int64(len(part.Data))
+	if err := part.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatal(err)
+	}
+	def := sif.DescriptorInput{
+		Datatype: sif.DataDeffile,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Data:     []byte("bootstrap: scratch\n"),
+	}
+	def.Size = int64(len(def.Data))
+
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{part, def},
+	}
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+}
+
+// checkBlocks checks the hash and signature of the signature blocks of
+// fimg selected by opts and returns the signed data object IDs
+func checkBlocks(t *testing.T, fimg *sif.FileImage, opts VerifyOptions, kr openpgp.KeyRing) []uint32 {
+	blocks, err := signatureBlocks(fimg, opts)
+	if err != nil {
+		t.Fatalf("failed to get signature blocks: %v", err)
+	}
+	var ids []uint32
+	for _, b := range blocks {
+		descr, _, err := signedObjects(fimg, b.link)
+		if err != nil {
+			t.Fatalf("signature %d: %v", b.id, err)
+		}
+		if err := checkHash(b.data, computeHashStr(fimg, descr)); err != nil {
+			t.Errorf("signature %d: %v", b.id, err)
+		}
+		if _, _, err := checkSignature(b.data, kr); err != nil {
+			t.Errorf("signature %d: %v", b.id, err)
+		}
+		ids = append(ids, b.link)
+	}
+	return ids
+}
+
+func TestSignDetached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detached-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e, err := openpgp.NewEntity("detached", "", "detached@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create entity: %v", err)
+	}
+	kr := openpgp.EntityList{e}
+
+	image := filepath.Join(dir, "image.sif")
+	createContainer(t, image)
+	// the container is on read-only storage
+	if err := os.Chmod(image, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	sigPath := filepath.Join(dir, "image.sig")
+	if err := SignWithEntity(image, e, SignOptions{All: true, Detached: sigPath}); err != nil {
+		t.Fatalf("failed to sign all data objects: %v", err)
+	}
+	if err := SignWithEntity(image, e, SignOptions{Detached: sigPath}); err != nil {
+		t.Fatalf("failed to sign primary partition: %v", err)
+	}
+
+	fimg, err := sif.LoadContainer(image, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fimg.UnloadContainer()
+
+	if _, _, err := fimg.GetFromDescr(sif.Descriptor{Datatype: sif.DataSignature}); err == nil {
+		t.Errorf("unexpected signature descriptor added to the container")
+	}
+
+	opts := VerifyOptions{Signature: sigPath, All: true}
+	if ids := checkBlocks(t, &fimg, opts, kr); len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 1 {
+		t.Errorf("unexpected signed data objects %v", ids)
+	}
+	opts = VerifyOptions{Signature: sigPath}
+	if ids := checkBlocks(t, &fimg, opts, kr); len(ids) != 2 {
+		t.Errorf("unexpected primary partition signatures %v", ids)
+	}
+	opts = VerifyOptions{Signature: sigPath, ID: 2}
+	if ids := checkBlocks(t, &fimg, opts, kr); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("unexpected data object signatures %v", ids)
+	}
+	opts = VerifyOptions{Signature: sigPath, ID: 1, IsGroup: true}
+	if _, err := signatureBlocks(&fimg, opts); err == nil {
+		t.Errorf("unexpected group signatures")
+	}
+
+	// signatures of another image are refused
+	other := filepath.Join(dir, "other.sif")
+	createContainer(t, other)
+	if err := SignWithEntity(other, e, SignOptions{Detached: sigPath}); err == nil {
+		t.Errorf("unexpected success adding signatures of another image")
+	}
+	ofimg, err := sif.LoadContainer(other, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ofimg.UnloadContainer()
+	if _, err := signatureBlocks(&ofimg, VerifyOptions{Signature: sigPath}); err == nil {
+		t.Errorf("unexpected success reading signatures of another image")
+	}
+
+	// embedded signatures of all data objects
+	if err := SignWithEntity(other, e, SignOptions{All: true}); err != nil {
+		t.Fatalf("failed to sign all data objects: %v", err)
+	}
+	ofimg.UnloadContainer()
+	if ofimg, err = sif.LoadContainer(other, true); err != nil {
+		t.Fatal(err)
+	}
+	if ids := checkBlocks(t, &ofimg, VerifyOptions{All: true}, kr); len(ids) != 2 {
+		t.Errorf("unexpected signed data objects %v", ids)
+	}
+}
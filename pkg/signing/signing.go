@@ -8,16 +8,21 @@ package signing
 import (
 	"bytes"
 	"crypto/sha512"
+	"crypto/x509"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/sypgp"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
 )
 
 // computeHashStr generates a hash from data object(s) and generates a string
@@ -33,8 +38,8 @@ func computeHashStr(fimg *sif.FileImage, descr []*sif.Descriptor) string {
 	return fmt.Sprintf("SIFHASH:\n%x", sum)
 }
 
-// sifAddSignature adds a signature block to a SIF file
-func sifAddSignature(fimg *sif.FileImage, groupid, link uint32, fingerprint [20]byte, signature []byte) error {
+// sifAddSignature adds a signature block of hash type hashtype to a SIF file
+func sifAddSignature(fimg *sif.FileImage, groupid, link uint32, hashtype sif.Hashtype, fingerprint [20]byte, signature []byte) error {
 	// data we need to create a signature descriptor
 	siginput := sif.DescriptorInput{
 		Datatype: sif.DataSignature,
@@ -46,7 +51,7 @@ func sifAddSignature(fimg *sif.FileImage, groupid, link uint32, fingerprint [20]
 	siginput.Size = int64(binary.Size(siginput.Data))
 
 	// extra data needed for the creation of a signature descriptor
-	err := siginput.SetSignExtra(sif.HashSHA384, hex.EncodeToString(fingerprint[:]))
+	err := siginput.SetSignExtra(hashtype, hex.EncodeToString(fingerprint[:]))
 	if err != nil {
 		return err
 	}
@@ -87,12 +92,117 @@ func descrToSign(fimg *sif.FileImage, id uint32, isGroup bool) (descr []*sif.Des
 	return
 }
 
+// SignOptions selects the data objects to sign and where signature blocks
+// are stored
+type SignOptions struct {
+	// ID is the data object or group ID to sign, the primary partition if
+	// zero
+	ID uint32
+	// IsGroup is whether ID is a group ID
+	IsGroup bool
+	// All signs each data object of the container
+	All bool
+	// Detached is the path of a detached signature file signature blocks
+	// are written to, the container isn't modified. Signature blocks are
+	// added to the container if empty
+	Detached string
+}
+
+// signer creates the signature block of sifhash, it returns the hash type
+// of the signature block and the fingerprint of the signing key
+type signer func(sifhash string) (sif.Hashtype, [20]byte, []byte, error)
+
+// signTarget is a data object or group to sign, groupid and link are those
+// of its signature descriptor
+type signTarget struct {
+	descr   []*sif.Descriptor
+	groupid uint32
+	link    uint32
+}
+
+// objectsToSign returns the data objects of fimg selected by opts
+func objectsToSign(fimg *sif.FileImage, opts SignOptions) ([]signTarget, error) {
+	if opts.All {
+		var targets []signTarget
+		for i := range fimg.DescrArr {
+			d := &fimg.DescrArr[i]
+			if !d.Used || d.Datatype == sif.DataSignature {
+				continue
+			}
+			targets = append(targets, signTarget{descr: []*sif.Descriptor{d}, groupid: d.Groupid, link: d.ID})
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no data object to sign")
+		}
+		return targets, nil
+	}
+
+	// figure out which descriptor has data to sign
+	descr, err := descrToSign(fimg, opts.ID, opts.IsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("signing requires a primary partition: %s", err)
+	}
+	if opts.IsGroup {
+		return []signTarget{{descr: descr, groupid: sif.DescrUnusedGroup, link: descr[0].Groupid}}, nil
+	}
+	return []signTarget{{descr: descr, groupid: descr[0].Groupid, link: descr[0].ID}}, nil
+}
+
+// signObjects signs the data objects of the container at cpath selected by
+// opts with sign. Signature blocks are added to the container as new data
+// objects or written to the detached signature file of opts
+func signObjects(cpath string, opts SignOptions, sign signer) error {
+	// detached signatures only require to read the container
+	fimg, err := sif.LoadContainer(cpath, opts.Detached != "")
+	if err != nil {
+		return fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	targets, err := objectsToSign(&fimg, opts)
+	if err != nil {
+		return err
+	}
+
+	// sign all data objects before adding signature blocks to the container
+	blocks := make([]signatureBlock, 0, len(targets))
+	fingerprints := make([][20]byte, 0, len(targets))
+	for _, t := range targets {
+		// signature also include data integrity check
+		hashtype, fingerprint, data, err := sign(computeHashStr(&fimg, t.descr))
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, signatureBlock{
+			link:     t.link,
+			hashtype: hashtype,
+			entity:   fmt.Sprintf("%X", fingerprint),
+			data:     data,
+		})
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	if opts.Detached != "" {
+		return writeDetached(opts.Detached, &fimg, blocks)
+	}
+
+	// finally add the signature blocks as new SIF data objects
+	for i, b := range blocks {
+		err := sifAddSignature(&fimg, targets[i].groupid, b.link, b.hashtype, fingerprints[i], b.data)
+		if err != nil {
+			return fmt.Errorf("failed adding signature block to SIF container file: %s", err)
+		}
+	}
+
+	return nil
+}
+
 // Sign takes the path of a container and generates an OpenPGP signature block for
-// its system partition. Sign uses the private keys found in the default
+// the data objects selected by opts. Sign uses the private keys found in the default
 // location if available or helps the user by prompting with key generation
 // configuration options. In its current form, Sign also pushes, when desired,
 // public material to a key server.
-func Sign(cpath, url string, id uint32, isGroup bool, keyIdx int, authToken string) error {
+func Sign(cpath, url string, keyIdx int, authToken string, opts SignOptions) error {
 	elist, err := sypgp.LoadPrivKeyring()
 	if err != nil {
 		return fmt.Errorf("could not load private keyring: %s", err)
@@ -106,7 +216,7 @@ func Sign(cpath, url string, id uint32, isGroup bool, keyIdx int, authToken stri
 			return fmt.Errorf("could not read response: %s", err)
 		}
 		if resp == "" || resp == "y" || resp == "Y" {
-			entity, err = sypgp.GenKeyPair()
+			entity, err = sypgp.GenKeyPair(sypgp.GenKeyPairOptions{})
 			if err != nil {
 				return fmt.Errorf("generating openpgp key pair failed: %s", err)
 			}
@@ -145,51 +255,29 @@ func Sign(cpath, url string, id uint32, isGroup bool, keyIdx int, authToken stri
 		return fmt.Errorf("could not decrypt private key, wrong password?")
 	}
 
-	// load the container
-	fimg, err := sif.LoadContainer(cpath, false)
-	if err != nil {
-		return fmt.Errorf("failed to load SIF container file: %s", err)
-	}
-	defer fimg.UnloadContainer()
-
-	// figure out which descriptor has data to sign
-	descr, err := descrToSign(&fimg, id, isGroup)
-	if err != nil {
-		return fmt.Errorf("signing requires a primary partition: %s", err)
-	}
-
-	// signature also include data integrity check
-	sifhash := computeHashStr(&fimg, descr)
-
-	// create an ascii armored signature block
-	var signedmsg bytes.Buffer
-	plaintext, err := clearsign.Encode(&signedmsg, entity.PrivateKey, nil)
-	if err != nil {
-		return fmt.Errorf("could not build a signature block: %s", err)
-	}
-	_, err = plaintext.Write([]byte(sifhash))
-	if err != nil {
-		return fmt.Errorf("failed writing hash value to signature block: %s", err)
-	}
-	if err = plaintext.Close(); err != nil {
-		return fmt.Errorf("I/O error while wrapping up signature block: %s", err)
-	}
-
-	// finally add the signature block (for descr) as a new SIF data object
-	var groupid, link uint32
-	if isGroup {
-		groupid = sif.DescrUnusedGroup
-		link = descr[0].Groupid
-	} else {
-		groupid = descr[0].Groupid
-		link = descr[0].ID
-	}
-	err = sifAddSignature(&fimg, groupid, link, entity.PrimaryKey.Fingerprint, signedmsg.Bytes())
-	if err != nil {
-		return fmt.Errorf("failed adding signature block to SIF container file: %s", err)
-	}
+	return SignWithEntity(cpath, entity, opts)
+}
 
-	return nil
+// SignWithEntity generates an OpenPGP signature block for the data objects
+// of the container at cpath selected by opts with the decrypted private key
+// of entity
+func SignWithEntity(cpath string, entity *openpgp.Entity, opts SignOptions) error {
+	return signObjects(cpath, opts, func(sifhash string) (sif.Hashtype, [20]byte, []byte, error) {
+		// create an ascii armored signature block
+		var signedmsg bytes.Buffer
+		plaintext, err := clearsign.Encode(&signedmsg, entity.PrivateKey, nil)
+		if err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("could not build a signature block: %s", err)
+		}
+		_, err = plaintext.Write([]byte(sifhash))
+		if err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("failed writing hash value to signature block: %s", err)
+		}
+		if err = plaintext.Close(); err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("I/O error while wrapping up signature block: %s", err)
+		}
+		return sif.HashSHA384, entity.PrimaryKey.Fingerprint, signedmsg.Bytes(), nil
+	})
 }
 
 // return all signatures for the primary partition
@@ -260,95 +348,452 @@ func getSigsForSelection(fimg *sif.FileImage, id uint32, isGroup bool) (sigs []*
 	return getSigsDescr(fimg, id)
 }
 
-// Verify takes a container path and look for a verification block for a
-// specified descriptor. If found, the signature block is used to verify the
-// partition hash against the signer's version. Verify takes care of looking
-// for OpenPGP keys in the default local store or looks it up from a key server
-// if access is enabled.
-func Verify(cpath, url string, id uint32, isGroup bool, authToken string, noPrompt bool) error {
-	fimg, err := sif.LoadContainer(cpath, true)
+var errHashMismatch = fmt.Errorf("hashes differ, data may be corrupted")
+
+// checkHash compares the hash stored in the signature block data with the
+// freshly computed sifhash
+func checkHash(data []byte, sifhash string) error {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return fmt.Errorf("failed to parse signature block")
+	}
+	if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(sifhash)) {
+		return errHashMismatch
+	}
+	return nil
+}
+
+// checkSignature checks the signature of the signature block data with
+// the keys of kr and returns the signer along with the signature time
+func checkSignature(data []byte, kr openpgp.KeyRing) (*openpgp.Entity, time.Time, error) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse signature block")
+	}
+	sig, err := ioutil.ReadAll(block.ArmoredSignature.Body)
 	if err != nil {
-		return fmt.Errorf("failed to load SIF container file: %s", err)
+		return nil, time.Time{}, fmt.Errorf("failed to read signature: %s", err)
 	}
-	defer fimg.UnloadContainer()
 
-	// get all signature blocks (signatures) for ID/GroupID selected (descr) from SIF file
-	signatures, descr, err := getSigsForSelection(&fimg, id, isGroup)
+	var signedAt time.Time
+	p, err := packet.Read(bytes.NewReader(sig))
 	if err != nil {
-		return fmt.Errorf("error while searching for signature blocks: %s", err)
+		return nil, time.Time{}, fmt.Errorf("failed to parse signature: %s", err)
+	}
+	switch s := p.(type) {
+	case *packet.Signature:
+		signedAt = s.CreationTime
+	case *packet.SignatureV3:
+		signedAt = s.CreationTime
+	default:
+		return nil, time.Time{}, fmt.Errorf("unexpected packet in signature block")
 	}
 
-	// the selected data object is hashed for comparison against signature block's
-	sifhash := computeHashStr(&fimg, descr)
+	signer, err := openpgp.CheckDetachedSignature(kr, bytes.NewBuffer(block.Bytes), bytes.NewReader(sig))
+	return signer, signedAt, err
+}
 
-	var author string
+// KeyPolicy is the action taken when a signature is made with a key which
+// was not valid at signature time or is not valid anymore
+type KeyPolicy string
 
-	// compare freshly computed hash with hashes stored in signatures block(s)
-	for _, v := range signatures {
-		// Extract hash string from signature block
-		data := v.GetData(&fimg)
-		block, _ := clearsign.Decode(data)
-		if block == nil {
-			return fmt.Errorf("failed to parse signature block")
+// Key policies, KeyPolicyFail is the default
+const (
+	// KeyPolicyFail rejects the signature
+	KeyPolicyFail KeyPolicy = "fail"
+	// KeyPolicyWarn accepts the signature with a warning
+	KeyPolicyWarn KeyPolicy = "warn"
+)
+
+// ParseKeyPolicy returns the key policy named s
+func ParseKeyPolicy(s string) (KeyPolicy, error) {
+	switch p := KeyPolicy(s); p {
+	case KeyPolicyFail, KeyPolicyWarn:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown key policy %q, expected %q or %q", s, KeyPolicyFail, KeyPolicyWarn)
+}
+
+// VerifyOptions selects the signatures to verify and how to verify them
+type VerifyOptions struct {
+	// KeyServerURL is the key server to fetch unknown keys and
+	// revocations from
+	KeyServerURL string
+	// AuthToken is the key server authentication token
+	AuthToken string
+	// NoPrompt disables the key server authentication prompt
+	NoPrompt bool
+	// ID is the data object or group ID to verify, the primary
+	// partition if zero
+	ID uint32
+	// IsGroup is whether ID is a group ID
+	IsGroup bool
+	// All verifies all signed data objects
+	All bool
+	// KeyPolicy is the action taken on signatures made with expired or
+	// revoked keys
+	KeyPolicy KeyPolicy
+	// Roots are the CA certificates trusted to verify X.509 signatures
+	Roots *x509.CertPool
+	// Signature is the path of a detached signature file to verify
+	// instead of the signature blocks embedded in the container
+	Signature string
+}
+
+// SignatureResult is the verification result of a signature block
+type SignatureResult struct {
+	// SignatureID is the ID of the signature descriptor, or the position
+	// of the signature block in a detached signature file
+	SignatureID uint32 `json:"signatureID"`
+	// ObjectIDs are the IDs of the data objects covered by the signature
+	ObjectIDs []uint32 `json:"objectIDs"`
+	// GroupID is the signed group ID, zero for a single object signature
+	GroupID uint32 `json:"groupID,omitempty"`
+	// HashMatch is whether the data objects match the signed hash
+	HashMatch bool `json:"hashMatch"`
+	// Fingerprint is the fingerprint of the signing key
+	Fingerprint string `json:"fingerprint"`
+	// Signer is the identity of the signing key
+	Signer string `json:"signer,omitempty"`
+	// KeyID is the ID of the signing key
+	KeyID string `json:"keyID,omitempty"`
+	// KeySource is where the signing key was found: local, global or
+	// keyserver, or ca-bundle for X.509 signatures
+	KeySource string `json:"keySource,omitempty"`
+	// SignedAt is the signature time, zero if the signature could not be
+	// read or has no time as X.509 signatures
+	SignedAt time.Time `json:"signedAt"`
+	// KeyExpired is whether the signing key is expired
+	KeyExpired bool `json:"keyExpired"`
+	// KeyRevoked is whether the signing key is revoked
+	KeyRevoked bool `json:"keyRevoked"`
+	// Verified is whether the data objects are authentic and intact
+	Verified bool `json:"verified"`
+	// Error describes why the verification failed
+	Error string `json:"error,omitempty"`
+	// Warning describes why the signing key is not valid when the key
+	// policy only warns about it
+	Warning string `json:"warning,omitempty"`
+
+	err error
+}
+
+// Sources of the keys used to verify signatures
+const (
+	KeySourceLocal     = "local"
+	KeySourceGlobal    = "global"
+	KeySourceKeyServer = "keyserver"
+)
+
+// anyKeyRing is a keyring also returning revoked keys when checking a
+// signature, their validity is checked against the key policy afterwards
+type anyKeyRing struct {
+	openpgp.EntityList
+}
+
+// KeysByIdUsage returns the keys with the given id which can be used for
+// requiredUsage, revoked or not
+func (kr anyKeyRing) KeysByIdUsage(id uint64, requiredUsage byte) (keys []openpgp.Key) {
+	for _, k := range kr.KeysById(id) {
+		if k.SelfSignature.FlagsValid && requiredUsage == packet.KeyFlagSign && !k.SelfSignature.FlagSign {
+			continue
 		}
+		keys = append(keys, k)
+	}
+	return keys
+}
 
-		if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(sifhash)) {
-			sylog.Infof("NOTE: group signatures will fail if new data is added to a group")
-			sylog.Infof("after the group signature is created.")
-			return fmt.Errorf("hashes differ, data may be corrupted")
+// fetchRevocations adds the revocation signatures of the key server copy of
+// the key of e to e. Failures are not fatal as the key server may be
+// unreachable while the key is known locally
+func fetchRevocations(e *openpgp.Entity, url, authToken string) {
+	fingerprint := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+	netlist, err := sypgp.FetchPubkey(fingerprint, url, authToken, true)
+	if err != nil {
+		sylog.Verbosef("Could not check key %s revocation with the key server: %s", fingerprint, err)
+		return
+	}
+	for _, n := range netlist {
+		if n.PrimaryKey.Fingerprint == e.PrimaryKey.Fingerprint {
+			e.Revocations = append(e.Revocations, n.Revocations...)
 		}
+	}
+}
+
+// findSigner checks the signature block data with the keys of the local
+// keyring, then with the keys of the global keyring and finally with the
+// key fetched from the key server. It returns the signer, the source of
+// its key and the signature time. Revocations of local keys are looked up
+// on the key server as well
+func findSigner(data []byte, fingerprint string, opts VerifyOptions) (*openpgp.Entity, string, time.Time, error) {
+	// load the public keys available locally from the cache
+	elist, err := sypgp.LoadPubKeyring()
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("could not load public keyring: %s", err)
+	}
 
-		block, _ = clearsign.Decode(data)
-		if block == nil {
-			return fmt.Errorf("failed to parse signature block")
+	// verify the container with our local keys first
+	if signer, signedAt, err := checkSignature(data, anyKeyRing{elist}); err == nil {
+		fetchRevocations(signer, opts.KeyServerURL, opts.AuthToken)
+		return signer, KeySourceLocal, signedAt, nil
+	}
+
+	// then with the keys of the global keyring
+	globalList, err := sypgp.LoadGlobalPubKeyring()
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("could not load global public keyring: %s", err)
+	}
+	if signer, signedAt, err := checkSignature(data, anyKeyRing{globalList}); err == nil {
+		fetchRevocations(signer, opts.KeyServerURL, opts.AuthToken)
+		return signer, KeySourceGlobal, signedAt, nil
+	}
+
+	// if theres a error, thats proboly becuse we dont have a local key
+
+	// download the key
+	if len(fingerprint) > 24 {
+		sylog.Infof("Downloading key: %s...", fingerprint[24:])
+	}
+	netlist, err := sypgp.FetchPubkey(fingerprint, opts.KeyServerURL, opts.AuthToken, opts.NoPrompt)
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("could not fetch public key from server: %s", err)
+	}
+	sylog.Verbosef("key retrieved successfully!")
+
+	// verify the container
+	signer, signedAt, err := checkSignature(data, anyKeyRing{netlist})
+	if err != nil {
+		return nil, "", time.Time{}, fmt.Errorf("signature verification failed: %s", err)
+	}
+	return signer, KeySourceKeyServer, signedAt, nil
+}
+
+// checkSigningKey checks that the primary key of e was valid at signedAt
+// and is still valid now. The returned error lists the reasons it's not
+func checkSigningKey(e *openpgp.Entity, signedAt time.Time) (expired, revoked bool, err error) {
+	var reasons []string
+
+	// OpenPGP times have a one second resolution
+	if signedAt.Before(e.PrimaryKey.CreationTime.Truncate(time.Second)) {
+		reasons = append(reasons, fmt.Sprintf("was created on %s after the signature", e.PrimaryKey.CreationTime))
+	}
+	if expiry := sypgp.KeyExpiry(e); !expiry.IsZero() {
+		if signedAt.After(expiry) {
+			expired = true
+			reasons = append(reasons, fmt.Sprintf("expired on %s before the signature", expiry))
+		} else if time.Now().After(expiry) {
+			expired = true
+			reasons = append(reasons, fmt.Sprintf("expired on %s", expiry))
 		}
+	}
+	if r := sypgp.KeyRevocation(e); r != nil {
+		revoked = true
+		reason := fmt.Sprintf("was revoked on %s", r.CreationTime)
+		if r.RevocationReasonText != "" {
+			reason += fmt.Sprintf(" (%s)", r.RevocationReasonText)
+		}
+		reasons = append(reasons, reason)
+	}
 
-		// (1) Data integrity is verified, (2) now validate identify of signers
+	if len(reasons) > 0 {
+		err = fmt.Errorf("signing key %X %s", e.PrimaryKey.Fingerprint, strings.Join(reasons, ", "))
+	}
+	return expired, revoked, err
+}
 
-		// get the entity fingerprint for the signature block
-		fingerprint, err := v.GetEntityString()
+// signatureBlock is a signature block embedded in a container as a
+// signature descriptor or read from a detached signature file
+type signatureBlock struct {
+	// id is the ID of the signature descriptor, or the position of the
+	// signature block in a detached signature file
+	id uint32
+	// link is the ID of the signed data object, or the signed group ID
+	// with sif.DescrGroupMask set
+	link     uint32
+	hashtype sif.Hashtype
+	// entity is the fingerprint of the signing key or certificate
+	entity string
+	data   []byte
+	// err is set if the signature descriptor can't be read
+	err error
+}
+
+// embeddedSignature returns the signature block of the signature
+// descriptor sig
+func embeddedSignature(fimg *sif.FileImage, sig *sif.Descriptor) signatureBlock {
+	b := signatureBlock{id: sig.ID, link: sig.Link}
+
+	// get the entity fingerprint for the signature block
+	fingerprint, err := sig.GetEntityString()
+	if err != nil {
+		b.err = fmt.Errorf("could not get the signing entity fingerprint: %s", err)
+		return b
+	}
+	b.entity = fingerprint
+	if b.hashtype, err = sig.GetHashType(); err != nil {
+		b.err = fmt.Errorf("could not get the signature hash type: %s", err)
+		return b
+	}
+	b.data = sig.GetData(fimg)
+	return b
+}
+
+// signatureBlocks returns the signature blocks of the data object(s)
+// selected by opts, embedded in the container or read from the detached
+// signature file of opts
+func signatureBlocks(fimg *sif.FileImage, opts VerifyOptions) ([]signatureBlock, error) {
+	if opts.Signature != "" {
+		return detachedSignatures(fimg, opts)
+	}
+
+	var signatures []*sif.Descriptor
+	var err error
+	if opts.All {
+		signatures, _, err = fimg.GetFromDescr(sif.Descriptor{Datatype: sif.DataSignature})
 		if err != nil {
-			return fmt.Errorf("could not get the signing entity fingerprint: %s", err)
+			return nil, fmt.Errorf("no signatures found")
 		}
+	} else if signatures, _, err = getSigsForSelection(fimg, opts.ID, opts.IsGroup); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]signatureBlock, 0, len(signatures))
+	for _, v := range signatures {
+		blocks = append(blocks, embeddedSignature(fimg, v))
+	}
+	return blocks, nil
+}
 
-		// load the public keys available locally from the cache
-		elist, err := sypgp.LoadPubKeyring()
+// signedObjects returns the data objects covered by a signature block
+// linked to link
+func signedObjects(fimg *sif.FileImage, link uint32) (descr []*sif.Descriptor, groupid uint32, err error) {
+	if link&sif.DescrGroupMask != 0 {
+		groupid = link &^ sif.DescrGroupMask
+		descr, _, err = fimg.GetFromDescr(sif.Descriptor{Groupid: link})
 		if err != nil {
-			return fmt.Errorf("could not load public keyring: %s", err)
+			return nil, 0, fmt.Errorf("no descriptors found for groupid %v", groupid)
 		}
+		return descr, groupid, nil
+	}
 
-		// verify the container with our local keys first
-		signer, err := openpgp.CheckDetachedSignature(elist, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body)
-		if err != nil {
-			// if theres a error, thats proboly becuse we dont have a local key
+	d, _, err := fimg.GetFromDescrID(link)
+	if err != nil {
+		return nil, 0, fmt.Errorf("no descriptor found for id %v", link)
+	}
+	return []*sif.Descriptor{d}, 0, nil
+}
 
-			// download the key
-			sylog.Infof("Downloading key: %s...", fingerprint[24:])
-			netlist, err := sypgp.FetchPubkey(fingerprint, url, authToken, noPrompt)
-			if err != nil {
-				return fmt.Errorf("could not fetch public key from server: %s", err)
-			}
-			sylog.Verbosef("key retrieved successfully!")
+// verifySignature verifies the signature block sig of the data objects
+// descr
+func verifySignature(fimg *sif.FileImage, sig signatureBlock, descr []*sif.Descriptor, groupid uint32, opts VerifyOptions) SignatureResult {
+	res := SignatureResult{SignatureID: sig.id, GroupID: groupid}
+	for _, d := range descr {
+		res.ObjectIDs = append(res.ObjectIDs, d.ID)
+	}
 
-			block, _ := clearsign.Decode(data)
-			if block == nil {
-				return fmt.Errorf("failed to parse signature block")
-			}
+	fail := func(err error) SignatureResult {
+		res.err = err
+		res.Error = err.Error()
+		return res
+	}
 
-			// verify the container
-			signer, err = openpgp.CheckDetachedSignature(netlist, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body)
-			if err != nil {
-				return fmt.Errorf("signature verification failed: %s", err)
-			}
+	if sig.err != nil {
+		return fail(sig.err)
+	}
+	res.Fingerprint = sig.entity
+
+	// X.509 signatures are verified with the CA bundle
+	if sig.hashtype == HashX509SHA384 {
+		if err := verifyX509Signature(&res, sig.data, computeHashStr(fimg, descr), opts); err != nil {
+			return fail(err)
+		}
+		return res
+	}
+
+	// (1) Data integrity is verified, (2) now validate identify of signers
+	if err := checkHash(sig.data, computeHashStr(fimg, descr)); err != nil {
+		return fail(err)
+	}
+	res.HashMatch = true
+
+	signer, source, signedAt, err := findSigner(sig.data, sig.entity, opts)
+	if err != nil {
+		return fail(err)
+	}
+	res.SignedAt = signedAt
+	// Get first Identity data for convenience
+	for _, i := range signer.Identities {
+		res.Signer = i.Name
+		break
+	}
+	res.Fingerprint = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	res.KeyID = fmt.Sprintf("%X", signer.PrimaryKey.KeyId)
+	res.KeySource = source
+
+	res.KeyExpired, res.KeyRevoked, err = checkSigningKey(signer, signedAt)
+	if err != nil {
+		if opts.KeyPolicy != KeyPolicyWarn {
+			return fail(err)
 		}
+		sylog.Warningf("%s", err)
+		res.Warning = err.Error()
+	}
+	res.Verified = true
 
-		// Get first Identity data for convenience
-		var name string
-		for _, i := range signer.Identities {
-			name = i.Name
-			break
+	return res
+}
+
+// VerifyResults verifies the signature blocks of the container at cpath
+// for the data object(s) selected by opts, embedded in the container or
+// read from the detached signature file of opts. Unlike Verify, it checks
+// all signature blocks and returns a result per signature block
+func VerifyResults(cpath string, opts VerifyOptions) ([]SignatureResult, error) {
+	fimg, err := sif.LoadContainer(cpath, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	signatures, err := signatureBlocks(&fimg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error while searching for signature blocks: %s", err)
+	}
+
+	var results []SignatureResult
+	for _, v := range signatures {
+		descr, groupid, err := signedObjects(&fimg, v.link)
+		if err != nil {
+			res := SignatureResult{SignatureID: v.id, err: err, Error: err.Error()}
+			results = append(results, res)
+			continue
 		}
-		author += fmt.Sprintf("\t%s, KeyID %X\n", name, signer.PrimaryKey.KeyId)
+		results = append(results, verifySignature(&fimg, v, descr, groupid, opts))
+	}
+	return results, nil
+}
+
+// Verify takes a container path and look for a verification block for a
+// specified descriptor. If found, the signature block is used to verify the
+// partition hash against the signer's version. Verify takes care of looking
+// for OpenPGP keys in the default local store or looks it up from a key server
+// if access is enabled. Signatures made with expired or revoked keys are
+// handled according to the key policy of opts
+func Verify(cpath string, opts VerifyOptions) error {
+	results, err := VerifyResults(cpath, opts)
+	if err != nil {
+		return err
+	}
+
+	var author string
+	for _, res := range results {
+		if res.err == errHashMismatch {
+			sylog.Infof("NOTE: group signatures will fail if new data is added to a group")
+			sylog.Infof("after the group signature is created.")
+		}
+		if res.err != nil {
+			return res.err
+		}
+		author += fmt.Sprintf("\t%s, KeyID %s\n", res.Signer, res.KeyID)
 	}
 	fmt.Printf("Data integrity checked, authentic and signed by:\n%v", author)
 
@@ -385,6 +830,57 @@ func GetSignEntities(cpath string) ([]string, error) {
 	return getSignEntities(&fimg)
 }
 
+// GetVerifiedEntitiesFp returns the fingerprints of the entities with a valid
+// signature of the primary partition of an opened container, along with the
+// fingerprints stored in all its signature blocks. A signature is valid if
+// the hash of the partition matches and the signature is made with a key
+// of kr which was valid at signature time and is neither expired nor
+// revoked, keys are never fetched from a key server
+func GetVerifiedEntitiesFp(fp *os.File, kr openpgp.KeyRing) (verified []string, claimed []string, err error) {
+	fimg, err := sif.LoadContainerFp(fp, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signatures, descr, err := getSigsPrimPart(&fimg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sifhash := computeHashStr(&fimg, descr)
+
+	for _, v := range signatures {
+		fingerprint, err := v.GetEntityString()
+		if err != nil {
+			return nil, nil, err
+		}
+		claimed = append(claimed, fingerprint)
+
+		// X.509 signatures are checked by GetVerifiedCertificatesFp
+		if ht, err := v.GetHashType(); err == nil && ht == HashX509SHA384 {
+			continue
+		}
+
+		data := v.GetData(&fimg)
+		if err := checkHash(data, sifhash); err != nil {
+			sylog.Debugf("Signature of %s: %s", fingerprint, err)
+			continue
+		}
+		signer, signedAt, err := checkSignature(data, kr)
+		if err != nil {
+			sylog.Debugf("Signature of %s: %s", fingerprint, err)
+			continue
+		}
+		if _, _, err := checkSigningKey(signer, signedAt); err != nil {
+			sylog.Debugf("Signature of %s: %s", fingerprint, err)
+			continue
+		}
+		verified = append(verified, fmt.Sprintf("%0X", signer.PrimaryKey.Fingerprint[:]))
+	}
+
+	return verified, claimed, nil
+}
+
 // GetSignEntitiesFp returns all signing entities for an ID/Groupid
 func GetSignEntitiesFp(fp *os.File) ([]string, error) {
 	fimg, err := sif.LoadContainerFp(fp, true)
@@ -0,0 +1,370 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+)
+
+// HashX509SHA384 is the hash type of the signature descriptors holding an
+// X.509 signature of the SIF hash, distinct from the hash types of OpenPGP
+// signature blocks
+const HashX509SHA384 = sif.Hashtype(0x100) | sif.HashSHA384
+
+// KeySourceCA is the source of the keys of X.509 signatures, verified with
+// a CA bundle
+const KeySourceCA = "ca-bundle"
+
+// PEM block types of X.509 signature blocks
+const (
+	pemSignature   = "SIF SIGNATURE"
+	pemCertificate = "CERTIFICATE"
+)
+
+// x509Signature is a decoded X.509 signature block. The block holds the
+// signed SIF hash in clear text, followed by the PEM encoded signature and
+// certificate chain, leaf certificate first
+type x509Signature struct {
+	sifhash   string
+	algorithm x509.SignatureAlgorithm
+	signature []byte
+	chain     []*x509.Certificate
+}
+
+// signatureAlgorithms are the supported X.509 signature algorithms
+var signatureAlgorithms = []x509.SignatureAlgorithm{
+	x509.SHA384WithRSA,
+	x509.ECDSAWithSHA384,
+}
+
+// encodeX509Signature returns the X.509 signature block of s
+func encodeX509Signature(s *x509Signature) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(s.sifhash + "\n")
+	block := &pem.Block{
+		Type:    pemSignature,
+		Headers: map[string]string{"Algorithm": s.algorithm.String()},
+		Bytes:   s.signature,
+	}
+	if err := pem.Encode(&buf, block); err != nil {
+		return nil, err
+	}
+	for _, c := range s.chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: pemCertificate, Bytes: c.Raw}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeX509Signature decodes the X.509 signature block data
+func decodeX509Signature(data []byte) (*x509Signature, error) {
+	i := bytes.Index(data, []byte("-----BEGIN "))
+	if i < 0 {
+		return nil, fmt.Errorf("failed to parse signature block")
+	}
+	s := &x509Signature{sifhash: string(bytes.TrimRight(data[:i], "\n"))}
+
+	block, rest := pem.Decode(data[i:])
+	if block == nil || block.Type != pemSignature {
+		return nil, fmt.Errorf("failed to parse signature block: no signature")
+	}
+	s.signature = block.Bytes
+	for _, a := range signatureAlgorithms {
+		if a.String() == block.Headers["Algorithm"] {
+			s.algorithm = a
+		}
+	}
+	if s.algorithm == x509.UnknownSignatureAlgorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", block.Headers["Algorithm"])
+	}
+
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemCertificate {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signature block certificate: %s", err)
+		}
+		s.chain = append(s.chain, c)
+	}
+	if len(s.chain) == 0 {
+		return nil, fmt.Errorf("failed to parse signature block: no certificate")
+	}
+
+	return s, nil
+}
+
+// certFingerprint returns the SHA1 fingerprint of the certificate c, stored
+// as signing entity in the signature descriptors
+func certFingerprint(c *x509.Certificate) [20]byte {
+	return sha1.Sum(c.Raw)
+}
+
+// LoadCertificates returns the certificates of the PEM file at path
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != pemCertificate {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate of %s: %s", path, err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return certs, nil
+}
+
+// LoadCABundle returns a pool of the CA certificates of the PEM file at
+// path, used to verify X.509 signatures
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	certs, err := LoadCertificates(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, c := range certs {
+		pool.AddCert(c)
+	}
+	return pool, nil
+}
+
+// loadPrivateKey returns the RSA or ECDSA private key of the PEM file at
+// path. Encrypted keys are decrypted with the passphrase, see
+// sypgp.GetPassphrase
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found in %s", path)
+	}
+
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		pass, err := sypgp.GetPassphrase("Enter key passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if der, err = x509.DecryptPEMBlock(block, []byte(pass)); err != nil {
+			return nil, fmt.Errorf("could not decrypt private key: %s", err)
+		}
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %s", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("only RSA and ECDSA private keys are supported")
+}
+
+// SignX509 generates an X.509 signature block for the data objects of the
+// container at cpath selected by opts with the private key at keyPath and
+// the matching certificate at certPath. Intermediate certificates following
+// the signing certificate in certPath are stored in the block
+func SignX509(cpath, certPath, keyPath string, opts SignOptions) error {
+	chain, err := LoadCertificates(certPath)
+	if err != nil {
+		return fmt.Errorf("could not load certificate: %s", err)
+	}
+	key, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("could not load private key: %s", err)
+	}
+
+	var algorithm x509.SignatureAlgorithm
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = x509.SHA384WithRSA
+	case *ecdsa.PrivateKey:
+		algorithm = x509.ECDSAWithSHA384
+	}
+
+	return signObjects(cpath, opts, func(sifhash string) (sif.Hashtype, [20]byte, []byte, error) {
+		// the signature covers the same data integrity check as OpenPGP
+		s := &x509Signature{sifhash: sifhash, algorithm: algorithm, chain: chain}
+		digest := sha512.Sum384([]byte(s.sifhash))
+		var err error
+		if s.signature, err = key.Sign(rand.Reader, digest[:], crypto.SHA384); err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("could not sign: %s", err)
+		}
+		if err := chain[0].CheckSignature(s.algorithm, []byte(s.sifhash), s.signature); err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("private key doesn't match certificate %s", chain[0].Subject)
+		}
+
+		data, err := encodeX509Signature(s)
+		if err != nil {
+			return 0, [20]byte{}, nil, fmt.Errorf("could not build a signature block: %s", err)
+		}
+		return HashX509SHA384, certFingerprint(chain[0]), data, nil
+	})
+}
+
+// checkX509Signature checks the X.509 signature block s with the CA
+// certificates of roots at time t and returns the signing certificate
+func checkX509Signature(s *x509Signature, roots *x509.CertPool, t time.Time) (*x509.Certificate, error) {
+	leaf := s.chain[0]
+	if err := leaf.CheckSignature(s.algorithm, []byte(s.sifhash), s.signature); err != nil {
+		return nil, fmt.Errorf("signature verification failed: %s", err)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range s.chain[1:] {
+		intermediates.AddCert(c)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return nil, fmt.Errorf("certificate %s not trusted: %s", leaf.Subject, err)
+	}
+	return leaf, nil
+}
+
+// verifyX509Signature verifies the X.509 signature block data of sifhash
+// and fills res. Expired certificates are handled according to the key
+// policy of opts
+func verifyX509Signature(res *SignatureResult, data []byte, sifhash string, opts VerifyOptions) error {
+	s, err := decodeX509Signature(data)
+	if err != nil {
+		return err
+	}
+	if s.sifhash != sifhash {
+		return errHashMismatch
+	}
+	res.HashMatch = true
+
+	leaf := s.chain[0]
+	res.Fingerprint = fmt.Sprintf("%X", certFingerprint(leaf))
+	res.Signer = leaf.Subject.String()
+	res.KeyID = fmt.Sprintf("%X", leaf.SubjectKeyId)
+	res.KeySource = KeySourceCA
+	if opts.Roots == nil {
+		return fmt.Errorf("X.509 signature of %s requires a CA bundle", res.Signer)
+	}
+
+	now := time.Now()
+	res.KeyExpired = now.After(leaf.NotAfter)
+	_, err = checkX509Signature(s, opts.Roots, now)
+	if err != nil && res.KeyExpired && opts.KeyPolicy == KeyPolicyWarn {
+		// the certificate chain was valid until the signing certificate expired
+		if _, verr := checkX509Signature(s, opts.Roots, leaf.NotAfter); verr == nil {
+			err = fmt.Errorf("signing certificate %s expired on %s", res.Signer, leaf.NotAfter)
+			sylog.Warningf("%s", err)
+			res.Warning = err.Error()
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	res.Verified = true
+	return nil
+}
+
+// GetVerifiedCertificatesFp returns the signing certificates of the valid
+// X.509 signatures of the primary partition of an opened container. A
+// signature is valid if the hash of the partition matches and the signing
+// certificate is trusted by the CA certificates of roots
+func GetVerifiedCertificatesFp(fp *os.File, roots *x509.CertPool) ([]*x509.Certificate, error) {
+	fimg, err := sif.LoadContainerFp(fp, true)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, descr, err := getSigsPrimPart(&fimg)
+	if err != nil {
+		return nil, err
+	}
+
+	sifhash := computeHashStr(&fimg, descr)
+
+	var certs []*x509.Certificate
+	for _, v := range signatures {
+		if ht, err := v.GetHashType(); err != nil || ht != HashX509SHA384 {
+			continue
+		}
+		s, err := decodeX509Signature(v.GetData(&fimg))
+		if err != nil {
+			sylog.Debugf("Signature %d: %s", v.ID, err)
+			continue
+		}
+		if s.sifhash != sifhash {
+			sylog.Debugf("Signature %d: %s", v.ID, errHashMismatch)
+			continue
+		}
+		leaf, err := checkX509Signature(s, roots, time.Now())
+		if err != nil {
+			sylog.Debugf("Signature %d: %s", v.ID, err)
+			continue
+		}
+		certs = append(certs, leaf)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,455 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// OpenPGP packet tags of signatures and primary keys
+const (
+	tagSignature  = 2
+	tagPrivateKey = 5
+	tagPublicKey  = 6
+)
+
+// keyringEntity is an entity of a binary keyring in its serialized form.
+// Packets are kept unmodified so encrypted private keys can be moved
+// between keyrings without being decrypted
+type keyringEntity struct {
+	fingerprint string // upper case hex fingerprint of the primary key
+	private     bool   // whether the entity holds a private key
+	data        []byte // entity packets
+}
+
+// nextPacket returns the tag, the header length and the total length in
+// bytes of the first packet of data
+func nextPacket(data []byte) (tag byte, hdr, n int, err error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, 0, 0, fmt.Errorf("invalid packet header")
+	}
+
+	var length int
+	if data[0]&0x40 != 0 {
+		// new format packet
+		tag = data[0] & 0x3f
+		switch l := data[1]; {
+		case l < 192:
+			hdr, length = 2, int(l)
+		case l < 224:
+			if len(data) < 3 {
+				return 0, 0, 0, fmt.Errorf("truncated packet header")
+			}
+			hdr, length = 3, (int(l)-192)<<8+int(data[2])+192
+		case l == 255:
+			if len(data) < 6 {
+				return 0, 0, 0, fmt.Errorf("truncated packet header")
+			}
+			hdr, length = 6, int(binary.BigEndian.Uint32(data[2:6]))
+		default:
+			return 0, 0, 0, fmt.Errorf("partial length packets are not supported in keyrings")
+		}
+	} else {
+		// old format packet
+		tag = (data[0] & 0x3f) >> 2
+		switch data[0] & 3 {
+		case 0:
+			hdr, length = 2, int(data[1])
+		case 1:
+			if len(data) < 3 {
+				return 0, 0, 0, fmt.Errorf("truncated packet header")
+			}
+			hdr, length = 3, int(binary.BigEndian.Uint16(data[1:3]))
+		case 2:
+			if len(data) < 5 {
+				return 0, 0, 0, fmt.Errorf("truncated packet header")
+			}
+			hdr, length = 5, int(binary.BigEndian.Uint32(data[1:5]))
+		default:
+			hdr, length = 1, len(data)-1
+		}
+	}
+
+	if length < 0 || hdr+length > len(data) {
+		return 0, 0, 0, fmt.Errorf("truncated packet")
+	}
+	return tag, hdr, hdr + length, nil
+}
+
+// splitKeyring returns the entities of the binary keyring data
+func splitKeyring(data []byte) ([]keyringEntity, error) {
+	var entities []keyringEntity
+
+	for len(data) > 0 {
+		tag, _, n, err := nextPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		pkt := data[:n]
+		data = data[n:]
+
+		if tag == tagPrivateKey || tag == tagPublicKey {
+			p, err := packet.Read(bytes.NewReader(pkt))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse primary key: %s", err)
+			}
+			var fingerprint [20]byte
+			switch k := p.(type) {
+			case *packet.PrivateKey:
+				fingerprint = k.Fingerprint
+			case *packet.PublicKey:
+				fingerprint = k.Fingerprint
+			default:
+				return nil, fmt.Errorf("unsupported primary key version")
+			}
+			entities = append(entities, keyringEntity{
+				fingerprint: fmt.Sprintf("%X", fingerprint),
+				private:     tag == tagPrivateKey,
+			})
+		} else if len(entities) == 0 {
+			return nil, fmt.Errorf("keyring doesn't start with a primary key")
+		}
+
+		e := &entities[len(entities)-1]
+		e.data = append(e.data, pkt...)
+	}
+
+	return entities, nil
+}
+
+// readKeyringFile returns the entities of the armored or binary keyring
+// stored at path
+func readKeyringFile(path string) ([]keyringEntity, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, err := armor.Decode(bytes.NewReader(data)); err == nil {
+		if block.Type != openpgp.PublicKeyType && block.Type != openpgp.PrivateKeyType {
+			return nil, fmt.Errorf("expected public or private key block, got: %s", block.Type)
+		}
+		if data, err = ioutil.ReadAll(block.Body); err != nil {
+			return nil, fmt.Errorf("could not decode key block: %s", err)
+		}
+	}
+
+	return splitKeyring(data)
+}
+
+// findKey returns the entity matching fingerprint in the keyring at path
+func findKey(path, fingerprint string) (*keyringEntity, error) {
+	entities, err := readKeyringFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		if strings.EqualFold(e.fingerprint, fingerprint) {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("no key matching %s in %s", fingerprint, path)
+}
+
+// appendKey appends the entity e to the keyring at path if not present
+// already, it returns false if the keyring already holds the entity
+func appendKey(path string, e keyringEntity, perm os.FileMode) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		if _, err := findKey(path, e.fingerprint); err == nil {
+			return false, nil
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(e.data); err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// replaceKey replaces the entity matching fingerprint in the keyring at
+// path with data, or removes it if data is empty. The keyring is replaced
+// atomically with a file of mode perm
+func replaceKey(path, fingerprint string, data []byte, perm os.FileMode) error {
+	entities, err := readKeyringFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to list keyring: %v", err)
+	}
+
+	var keyring []byte
+	found := false
+	for _, e := range entities {
+		if strings.EqualFold(e.fingerprint, fingerprint) {
+			found = true
+			keyring = append(keyring, data...)
+			continue
+		}
+		keyring = append(keyring, e.data...)
+	}
+	if !found {
+		return fmt.Errorf("no key matching %s in %s", fingerprint, path)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return fmt.Errorf("unable to create keyring: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write(keyring); err != nil {
+		return fmt.Errorf("could not store keyring: %v", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// removeKey removes the entity matching fingerprint from the keyring at
+// path. The keyring is replaced atomically with a file of mode perm
+func removeKey(path, fingerprint string, perm os.FileMode) error {
+	return replaceKey(path, fingerprint, nil, perm)
+}
+
+// revocationPackets returns the key revocation signature packets of the
+// entity data
+func revocationPackets(data []byte) ([][]byte, error) {
+	var revocations [][]byte
+
+	for len(data) > 0 {
+		tag, _, n, err := nextPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		pkt := data[:n]
+		data = data[n:]
+
+		if tag != tagSignature {
+			continue
+		}
+		p, err := packet.Read(bytes.NewReader(pkt))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse signature: %s", err)
+		}
+		if sig, ok := p.(*packet.Signature); ok && sig.SigType == packet.SigTypeKeyRevocation {
+			revocations = append(revocations, pkt)
+		}
+	}
+
+	return revocations, nil
+}
+
+// addRevocations inserts the revocation signature packets revocations
+// right after the primary key packet of the entity data
+func addRevocations(data, revocations []byte) ([]byte, error) {
+	_, _, n, err := nextPacket(data)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make([]byte, 0, len(data)+len(revocations))
+	revoked = append(revoked, data[:n]...)
+	revoked = append(revoked, revocations...)
+	revoked = append(revoked, data[n:]...)
+
+	// revocations signed by another key make the whole keyring unreadable
+	if _, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(revoked))); err != nil {
+		return nil, fmt.Errorf("invalid revocation signature: %s", err)
+	}
+	return revoked, nil
+}
+
+// mergeRevocations adds the revocation signatures of the public key e to
+// the matching key of the keyring at path. It returns false if the keyring
+// doesn't hold the key or already holds all its revocation signatures
+func mergeRevocations(path string, e keyringEntity, perm os.FileMode) (bool, error) {
+	if e.private {
+		return false, nil
+	}
+	revocations, err := revocationPackets(e.data)
+	if err != nil || len(revocations) == 0 {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return false, nil
+	}
+	old, err := findKey(path, e.fingerprint)
+	if err != nil {
+		return false, nil
+	}
+	known, err := revocationPackets(old.data)
+	if err != nil {
+		return false, err
+	}
+
+	var missing []byte
+next:
+	for _, r := range revocations {
+		for _, k := range known {
+			if bytes.Equal(r, k) {
+				continue next
+			}
+		}
+		missing = append(missing, r...)
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	data, err := addRevocations(old.data, missing)
+	if err != nil {
+		return false, err
+	}
+	return true, replaceKey(path, e.fingerprint, data, perm)
+}
+
+// importKey adds the entity e to the keyring at path, or adds its
+// revocation signatures to the entity already present, and reports it
+// using name for the keyring
+func importKey(path, name string, e keyringEntity, perm os.FileMode) error {
+	revoked, err := mergeRevocations(path, e, perm)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		fmt.Printf("Key with fingerprint %s revoked in the %s\n", e.fingerprint, name)
+		return nil
+	}
+
+	added, err := appendKey(path, e, perm)
+	if err != nil {
+		return err
+	}
+	if added {
+		fmt.Printf("Key with fingerprint %s added succesfully to the %s\n", e.fingerprint, name)
+	} else {
+		fmt.Printf("The key you want to add with fingerprint %s already belongs to the %s\n", e.fingerprint, name)
+	}
+	return nil
+}
+
+// ImportKeys imports the keys of the armored or binary keyring at path,
+// private keys into the local secret keyring and public keys into the
+// local public keyring
+func ImportKeys(path string) error {
+	if err := PathsCheck(); err != nil {
+		return err
+	}
+
+	entities, err := readKeyringFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		keyring := PublicPath()
+		if e.private {
+			keyring = SecretPath()
+		}
+		if err := importKey(keyring, "keystore", e, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportGlobalKeys imports the public keys of the armored or binary
+// keyring at path into the global keyring
+func ImportGlobalKeys(path string) error {
+	entities, err := readKeyringFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		if e.private {
+			return fmt.Errorf("only public keys can be imported into the global keyring")
+		}
+	}
+
+	for _, e := range entities {
+		if err := importKey(GlobalPublicPath(), "global keyring", e, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportKey writes the key matching fingerprint to a new file at path, the
+// private key from the local secret keyring if secret is set, otherwise
+// the public key from the local public keyring. The key is ASCII armored
+// if armored is set. Private keys are exported encrypted as stored
+func ExportKey(fingerprint, path string, secret, armored bool) error {
+	keyring, blockType, perm := PublicPath(), openpgp.PublicKeyType, os.FileMode(0644)
+	if secret {
+		keyring, blockType, perm = SecretPath(), openpgp.PrivateKeyType, 0600
+	}
+
+	if err := PathsCheck(); err != nil {
+		return err
+	}
+	e, err := findKey(keyring, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var aw io.WriteCloser
+	if armored {
+		if aw, err = armor.Encode(f, blockType, nil); err != nil {
+			return err
+		}
+		w = aw
+	}
+	if _, err := w.Write(e.data); err != nil {
+		return err
+	}
+	if aw != nil {
+		if err := aw.Close(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// RemovePrivKey removes the private key matching fingerprint from the
+// local secret keyring
+func RemovePrivKey(fingerprint string) error {
+	if err := PathsCheck(); err != nil {
+		return err
+	}
+	sylog.Infof("Updating local keyring: %v", SecretPath())
+	return removeKey(SecretPath(), fingerprint, 0600)
+}
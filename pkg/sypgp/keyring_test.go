@@ -0,0 +1,147 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// newTestEntity returns a new entity with a private key encrypted by pass
+func newTestEntity(t *testing.T, name, pass string) *openpgp.Entity {
+	e, err := openpgp.NewEntity(name, testComment, testEmail, &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("failed to create entity: %v", err)
+	}
+	if err := EncryptKey(e, pass); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	return e
+}
+
+func fingerprint(e *openpgp.Entity) string {
+	return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+}
+
+func TestKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e1 := newTestEntity(t, "First", "pass1")
+	e2 := newTestEntity(t, "Second", "pass2")
+
+	var secret, public bytes.Buffer
+	for _, e := range []*openpgp.Entity{e1, e2} {
+		if err := e.SerializePrivate(&secret, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Serialize(&public); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entities, err := splitKeyring(secret.Bytes())
+	if err != nil {
+		t.Fatalf("failed to split keyring: %v", err)
+	}
+	if len(entities) != 2 || !entities[0].private || entities[0].fingerprint != fingerprint(e1) || entities[1].fingerprint != fingerprint(e2) {
+		t.Fatalf("unexpected secret keyring entities %+v", entities)
+	}
+	entities, err = splitKeyring(public.Bytes())
+	if err != nil {
+		t.Fatalf("failed to split keyring: %v", err)
+	}
+	if len(entities) != 2 || entities[0].private {
+		t.Fatalf("unexpected public keyring entities %+v", entities)
+	}
+	if _, err := splitKeyring([]byte("not a keyring")); err == nil {
+		t.Errorf("unexpected success splitting an invalid keyring")
+	}
+
+	secretPath := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretPath, secret.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// armored export of the second private key
+	e, err := findKey(secretPath, fingerprint(e2))
+	if err != nil {
+		t.Fatalf("failed to find key: %v", err)
+	}
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(e.data)
+	w.Close()
+	armoredPath := filepath.Join(dir, "key.asc")
+	if err := ioutil.WriteFile(armoredPath, armored.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// removal keeps the other encrypted private key usable
+	if err := removeKey(secretPath, fingerprint(e2), 0600); err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+	if err := removeKey(secretPath, fingerprint(e2), 0600); err == nil {
+		t.Errorf("unexpected success removing a missing key")
+	}
+	el := readKeyring(t, secretPath)
+	if len(el) != 1 || el[0].PrimaryKey.KeyId != e1.PrimaryKey.KeyId {
+		t.Fatalf("unexpected keys left in keyring")
+	}
+	if err := el[0].PrivateKey.Decrypt([]byte("pass1")); err != nil {
+		t.Errorf("failed to decrypt remaining key: %v", err)
+	}
+
+	// import of the armored private key
+	imported, err := readKeyringFile(armoredPath)
+	if err != nil {
+		t.Fatalf("failed to read armored keyring: %v", err)
+	}
+	if len(imported) != 1 || !imported[0].private {
+		t.Fatalf("unexpected armored keyring entities %+v", imported)
+	}
+	if added, err := appendKey(secretPath, imported[0], 0600); err != nil || !added {
+		t.Fatalf("failed to append key: %v", err)
+	}
+	if added, err := appendKey(secretPath, imported[0], 0600); err != nil || added {
+		t.Errorf("key appended twice: %v", err)
+	}
+	el = readKeyring(t, secretPath)
+	if len(el) != 2 || el[1].PrimaryKey.KeyId != e2.PrimaryKey.KeyId {
+		t.Fatalf("unexpected keys in keyring")
+	}
+	if err := el[1].PrivateKey.Decrypt([]byte("pass2")); err != nil {
+		t.Errorf("failed to decrypt imported key: %v", err)
+	}
+}
+
+func readKeyring(t *testing.T, path string) openpgp.EntityList {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	el, err := openpgp.ReadKeyRing(f)
+	if err != nil {
+		t.Fatalf("failed to read keyring: %v", err)
+	}
+	return el
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase of
+// private keys
+const PassphraseEnv = "SINGULARITY_KEY_PASSPHRASE"
+
+var (
+	// PassphraseFd is a file descriptor to read the passphrase of private
+	// keys from, -1 if unset
+	PassphraseFd = -1
+	// PassphraseFile is a file holding the passphrase of private keys
+	PassphraseFile string
+)
+
+// passphrase caches the passphrase read from PassphraseFd, which can
+// only be read once
+var passphrase *string
+
+// readPassphrase reads the first line of r
+func readPassphrase(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// lookupPassphrase returns the passphrase of private keys from, in order,
+// PassphraseFd, PassphraseFile or the PassphraseEnv environment variable.
+// It returns false if none of them is set
+func lookupPassphrase() (string, bool, error) {
+	if passphrase != nil {
+		return *passphrase, true, nil
+	}
+
+	if PassphraseFd >= 0 {
+		f := os.NewFile(uintptr(PassphraseFd), "passphrase")
+		if f == nil {
+			return "", false, fmt.Errorf("invalid passphrase file descriptor %d", PassphraseFd)
+		}
+		defer f.Close()
+
+		pass, err := readPassphrase(f)
+		if err != nil {
+			return "", false, fmt.Errorf("could not read passphrase from file descriptor %d: %s", PassphraseFd, err)
+		}
+		passphrase = &pass
+		return pass, true, nil
+	}
+
+	if PassphraseFile != "" {
+		f, err := os.Open(PassphraseFile)
+		if err != nil {
+			return "", false, fmt.Errorf("could not open passphrase file: %s", err)
+		}
+		defer f.Close()
+
+		pass, err := readPassphrase(f)
+		if err != nil {
+			return "", false, fmt.Errorf("could not read passphrase file: %s", err)
+		}
+		return pass, true, nil
+	}
+
+	if pass, ok := os.LookupEnv(PassphraseEnv); ok {
+		return pass, true, nil
+	}
+
+	return "", false, nil
+}
+
+// GetPassphrase returns the passphrase of private keys from a passphrase
+// file descriptor, file or environment variable if set, otherwise the user
+// is prompted for it on the terminal
+func GetPassphrase(format string, a ...interface{}) (string, error) {
+	pass, ok, err := lookupPassphrase()
+	if err != nil || ok {
+		return pass, err
+	}
+	return AskQuestionNoEcho(format, a...)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetPassphrase clears all passphrase sources
+func resetPassphrase() {
+	PassphraseFd = -1
+	PassphraseFile = ""
+	passphrase = nil
+	os.Unsetenv(PassphraseEnv)
+}
+
+func TestLookupPassphrase(t *testing.T) {
+	defer resetPassphrase()
+
+	dir, err := ioutil.TempDir("", "passphrase-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(file, []byte("from file\r\nsecond line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// no source set
+	resetPassphrase()
+	if _, ok, err := lookupPassphrase(); ok || err != nil {
+		t.Errorf("unexpected passphrase found: %v", err)
+	}
+
+	// environment variable
+	os.Setenv(PassphraseEnv, "from env\n")
+	if pass, ok, err := lookupPassphrase(); !ok || err != nil || pass != "from env\n" {
+		t.Errorf("unexpected passphrase %q from environment: %v", pass, err)
+	}
+
+	// file takes precedence over the environment
+	PassphraseFile = file
+	if pass, ok, err := lookupPassphrase(); !ok || err != nil || pass != "from file" {
+		t.Errorf("unexpected passphrase %q from file: %v", pass, err)
+	}
+
+	PassphraseFile = filepath.Join(dir, "missing")
+	if _, _, err := lookupPassphrase(); err == nil {
+		t.Errorf("unexpected success with missing passphrase file")
+	}
+
+	// file descriptor takes precedence over the file and is read once
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("from fd")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	PassphraseFile = file
+	PassphraseFd = int(r.Fd())
+	for i := 0; i < 2; i++ {
+		if pass, ok, err := lookupPassphrase(); !ok || err != nil || pass != "from fd" {
+			t.Errorf("unexpected passphrase %q from file descriptor: %v", pass, err)
+		}
+	}
+}
+
+func TestNewKeyPair(t *testing.T) {
+	defer resetPassphrase()
+	resetPassphrase()
+
+	os.Setenv(PassphraseEnv, "passphrase")
+
+	opts := GenKeyPairOptions{
+		Name:      testName,
+		Email:     testEmail,
+		Comment:   testComment,
+		KeyLength: 1024,
+	}
+	e, err := NewKeyPair(opts)
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+
+	if _, ok := e.Identities["Test Name (blah) <test@test.com>"]; !ok {
+		t.Errorf("unexpected identities %v", e.Identities)
+	}
+	if bits, _ := e.PrimaryKey.BitLength(); bits != 1024 {
+		t.Errorf("unexpected key length %d", bits)
+	}
+	if !e.PrivateKey.Encrypted {
+		t.Fatalf("private key not encrypted")
+	}
+
+	os.Setenv(PassphraseEnv, "wrong")
+	if err := DecryptKey(e); err == nil {
+		t.Errorf("unexpected success decrypting with a wrong passphrase")
+	}
+
+	os.Setenv(PassphraseEnv, "passphrase")
+	if err := DecryptKey(e); err != nil {
+		t.Errorf("failed to decrypt key: %v", err)
+	}
+	if e.PrivateKey.Encrypted {
+		t.Errorf("private key still encrypted")
+	}
+}
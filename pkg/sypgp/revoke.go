@@ -0,0 +1,160 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// KeyExpiry returns the expiry time of the primary key of e set by its
+// latest identity self-signature, or the zero time if the key never expires
+func KeyExpiry(e *openpgp.Entity) time.Time {
+	var selfSig *packet.Signature
+	for _, i := range e.Identities {
+		if i.SelfSignature == nil {
+			continue
+		}
+		if selfSig == nil || i.SelfSignature.CreationTime.After(selfSig.CreationTime) {
+			selfSig = i.SelfSignature
+		}
+	}
+	if selfSig == nil || selfSig.KeyLifetimeSecs == nil || *selfSig.KeyLifetimeSecs == 0 {
+		return time.Time{}
+	}
+	return e.PrimaryKey.CreationTime.Add(time.Duration(*selfSig.KeyLifetimeSecs) * time.Second)
+}
+
+// KeyRevocation returns the earliest revocation signature of the primary
+// key of e, or nil if the key isn't revoked. Revocation signatures are
+// checked against the primary key when the entity is read
+func KeyRevocation(e *openpgp.Entity) *packet.Signature {
+	var revocation *packet.Signature
+	for _, r := range e.Revocations {
+		if revocation == nil || r.CreationTime.Before(revocation.CreationTime) {
+			revocation = r
+		}
+	}
+	return revocation
+}
+
+// newRevocation returns a revocation signature of the primary key of e
+// made with its decrypted private key
+func newRevocation(e *openpgp.Entity) ([]byte, error) {
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeKeyRevocation,
+		PubKeyAlgo:   e.PrivateKey.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: time.Now(),
+		IssuerKeyId:  &e.PrimaryKey.KeyId,
+	}
+
+	// RFC 4880, section 5.2.4: a key revocation signature is computed
+	// over the body of the primary key packet
+	var pub bytes.Buffer
+	if err := e.PrimaryKey.Serialize(&pub); err != nil {
+		return nil, err
+	}
+	_, hdr, _, err := nextPacket(pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	h := sig.Hash.New()
+	e.PrimaryKey.SerializeSignaturePrefix(h)
+	h.Write(pub.Bytes()[hdr:])
+
+	if err := sig.Sign(h, e.PrivateKey, nil); err != nil {
+		return nil, fmt.Errorf("could not sign revocation: %s", err)
+	}
+	if err := e.PrimaryKey.VerifyRevocationSignature(sig); err != nil {
+		return nil, fmt.Errorf("could not verify revocation: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := sig.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RevokeKey revokes the key matching fingerprint with its private key from
+// the local secret keyring. The revoked public key is stored in the local
+// public keyring and returned as an ASCII armored revocation certificate,
+// which can be imported or pushed to a key server
+func RevokeKey(fingerprint string) (string, error) {
+	if err := PathsCheck(); err != nil {
+		return "", err
+	}
+
+	el, err := LoadPrivKeyring()
+	if err != nil {
+		return "", fmt.Errorf("could not load private keyring: %s", err)
+	}
+	var entity *openpgp.Entity
+	for _, e := range el {
+		if strings.EqualFold(fmt.Sprintf("%X", e.PrimaryKey.Fingerprint), fingerprint) {
+			entity = e
+			break
+		}
+	}
+	if entity == nil {
+		return "", fmt.Errorf("no private key matching %s in local keyring", fingerprint)
+	}
+	fingerprint = fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+
+	if err := DecryptKey(entity); err != nil {
+		return "", err
+	}
+	revocation, err := newRevocation(entity)
+	if err != nil {
+		return "", err
+	}
+
+	// keep the signatures of the local public key if present
+	var pub bytes.Buffer
+	stored := false
+	if e, err := findKey(PublicPath(), fingerprint); err == nil {
+		pub.Write(e.data)
+		stored = true
+	} else if err := entity.Serialize(&pub); err != nil {
+		return "", err
+	}
+	data, err := addRevocations(pub.Bytes(), revocation)
+	if err != nil {
+		return "", err
+	}
+
+	sylog.Infof("Updating local keyring: %v", PublicPath())
+	if stored {
+		err = replaceKey(PublicPath(), fingerprint, data, 0600)
+	} else {
+		_, err = appendKey(PublicPath(), keyringEntity{fingerprint: fingerprint, data: data}, 0600)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not store revoked key: %s", err)
+	}
+
+	var cert bytes.Buffer
+	w, err := armor.Encode(&cert, openpgp.PublicKeyType, map[string]string{
+		"Comment": "revocation certificate of " + fingerprint,
+	})
+	if err != nil {
+		return "", err
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return cert.String(), nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestKeyExpiry(t *testing.T) {
+	e, err := openpgp.NewEntity(testName, testComment, testEmail, &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("failed to create entity: %v", err)
+	}
+	if expiry := KeyExpiry(e); !expiry.IsZero() {
+		t.Errorf("unexpected expiry %v of a key without lifetime", expiry)
+	}
+
+	lifetime := uint32(3600)
+	for name, id := range e.Identities {
+		id.SelfSignature.KeyLifetimeSecs = &lifetime
+		if err := id.SelfSignature.SignUserId(name, e.PrimaryKey, e.PrivateKey, nil); err != nil {
+			t.Fatalf("failed to sign identity: %v", err)
+		}
+	}
+	want := e.PrimaryKey.CreationTime.Add(time.Hour)
+	if expiry := KeyExpiry(e); !expiry.Equal(want) {
+		t.Errorf("unexpected expiry %v, expected %v", expiry, want)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "revoke-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var entities []*openpgp.Entity
+	for _, name := range []string{"First", "Second"} {
+		e, err := openpgp.NewEntity(name, testComment, testEmail, &packet.Config{RSABits: 1024})
+		if err != nil {
+			t.Fatalf("failed to create entity: %v", err)
+		}
+		entities = append(entities, e)
+	}
+	e := entities[0]
+
+	var pub bytes.Buffer
+	if err := e.Serialize(&pub); err != nil {
+		t.Fatal(err)
+	}
+	revocation, err := newRevocation(e)
+	if err != nil {
+		t.Fatalf("failed to create revocation: %v", err)
+	}
+	other, err := newRevocation(entities[1])
+	if err != nil {
+		t.Fatalf("failed to create revocation: %v", err)
+	}
+
+	if _, err := addRevocations(pub.Bytes(), other); err == nil {
+		t.Errorf("unexpected success adding a revocation signed by another key")
+	}
+	revoked, err := addRevocations(pub.Bytes(), revocation)
+	if err != nil {
+		t.Fatalf("failed to add revocation: %v", err)
+	}
+	re, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(revoked)))
+	if err != nil {
+		t.Fatalf("failed to read revoked key: %v", err)
+	}
+	if r := KeyRevocation(re); r == nil || r.SigType != packet.SigTypeKeyRevocation {
+		t.Errorf("key not revoked")
+	}
+
+	// revocations are merged into the known key of a keyring
+	path := filepath.Join(dir, "pgp-public")
+	if err := ioutil.WriteFile(path, pub.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ke := keyringEntity{fingerprint: fingerprint(e), data: revoked}
+	if merged, err := mergeRevocations(path, ke, 0600); err != nil || !merged {
+		t.Fatalf("failed to merge revocation: %v", err)
+	}
+	if merged, err := mergeRevocations(path, ke, 0600); err != nil || merged {
+		t.Errorf("revocation merged twice: %v", err)
+	}
+	el := readKeyring(t, path)
+	if len(el) != 1 || KeyRevocation(el[0]) == nil {
+		t.Errorf("key not revoked in keyring")
+	}
+
+	ke = keyringEntity{fingerprint: fingerprint(entities[1]), data: revoked}
+	if merged, err := mergeRevocations(path, ke, 0600); err != nil || merged {
+		t.Errorf("unexpected revocation merge of an unknown key: %v", err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	jsonresp "github.com/sylabs/json-resp"
 	"github.com/sylabs/scs-key-client/client"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	"golang.org/x/crypto/openpgp"
@@ -206,6 +207,52 @@ func LoadPubKeyring() (openpgp.EntityList, error) {
 	return openpgp.ReadKeyRing(f)
 }
 
+// globalKeyring is the path of the global public keyring
+var globalKeyring = buildcfg.GLOBAL_KEYRING
+
+// GlobalPublicPath returns a string describing the path to the system
+// global public keyring, managed by the administrator
+func GlobalPublicPath() string {
+	return globalKeyring
+}
+
+// LoadGlobalPubKeyring loads the public keys from the global keyring into
+// an EntityList, an empty list is returned if the keyring doesn't exist
+func LoadGlobalPubKeyring() (openpgp.EntityList, error) {
+	f, err := os.Open(GlobalPublicPath())
+	if os.IsNotExist(err) {
+		return openpgp.EntityList{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return openpgp.ReadKeyRing(f)
+}
+
+// StoreGlobalPubKey stores a public key entity into the global keyring,
+// readable by all users
+func StoreGlobalPubKey(e *openpgp.Entity) (err error) {
+	f, err := os.OpenFile(GlobalPublicPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = e.Serialize(f); err != nil {
+		return
+	}
+	return
+}
+
+// RemoveGlobalPubKey removes the public key matching fingerprint from the
+// global keyring. The keyring is replaced atomically so containers being
+// verified always see a complete keyring
+func RemoveGlobalPubKey(fingerprint string) error {
+	sylog.Infof("Updating global keyring: %v", GlobalPublicPath())
+	return removeKey(GlobalPublicPath(), fingerprint, 0644)
+}
+
 // PrintEntity pretty prints an entity entry
 func PrintEntity(index int, e *openpgp.Entity) {
 	for _, v := range e.Identities {
@@ -215,6 +262,12 @@ func PrintEntity(index int, e *openpgp.Entity) {
 	fmt.Printf("   F: %0X\n", e.PrimaryKey.Fingerprint)
 	bits, _ := e.PrimaryKey.BitLength()
 	fmt.Printf("   L: %v\n", bits)
+	if expiry := KeyExpiry(e); !expiry.IsZero() {
+		fmt.Printf("   E: %v\n", expiry)
+	}
+	if r := KeyRevocation(e); r != nil {
+		fmt.Printf("   R: %v\n", r.CreationTime)
+	}
 }
 
 // PrintPubKeyring prints the public keyring read from the public local store
@@ -233,6 +286,22 @@ func PrintPubKeyring() (err error) {
 	return
 }
 
+// PrintGlobalPubKeyring prints the public keyring read from the global store
+func PrintGlobalPubKeyring() (err error) {
+	var pubEntlist openpgp.EntityList
+
+	if pubEntlist, err = LoadGlobalPubKeyring(); err != nil {
+		return
+	}
+
+	for i, e := range pubEntlist {
+		PrintEntity(i, e)
+		fmt.Println("   --------")
+	}
+
+	return
+}
+
 // PrintPrivKeyring prints the secret keyring read from the public local store
 func PrintPrivKeyring() (err error) {
 	var privEntlist openpgp.EntityList
@@ -349,38 +418,56 @@ func RemovePubKey(toDelete string) error {
 	return nil
 }
 
-// GenKeyPair generates an OpenPGP key pair and store them in the sypgp home folder
-func GenKeyPair() (entity *openpgp.Entity, err error) {
-	conf := &packet.Config{RSABits: 4096, DefaultHash: crypto.SHA384}
+// DefaultKeyLength is the default length in bits of generated RSA keys
+const DefaultKeyLength = 4096
+
+// GenKeyPairOptions holds the identity and parameters of a new key pair
+type GenKeyPairOptions struct {
+	// Name is the name of the key owner, the identity is asked
+	// interactively if empty
+	Name string
+	// Email is the email address of the key owner
+	Email string
+	// Comment is an optional comment of the identity
+	Comment string
+	// KeyLength is the RSA key length in bits, DefaultKeyLength if zero
+	KeyLength int
+}
 
-	if err = PathsCheck(); err != nil {
-		return
+// NewKeyPair generates an OpenPGP key pair with the private key encrypted
+// by a passphrase, see GetPassphrase
+func NewKeyPair(opts GenKeyPairOptions) (entity *openpgp.Entity, err error) {
+	if opts.KeyLength == 0 {
+		opts.KeyLength = DefaultKeyLength
 	}
+	conf := &packet.Config{RSABits: opts.KeyLength, DefaultHash: crypto.SHA384}
 
-	name, err := AskQuestion("Enter your name (e.g., John Doe) : ")
-	if err != nil {
-		return
-	}
+	if opts.Name == "" {
+		opts.Name, err = AskQuestion("Enter your name (e.g., John Doe) : ")
+		if err != nil {
+			return
+		}
 
-	email, err := AskQuestion("Enter your email address (e.g., john.doe@example.com) : ")
-	if err != nil {
-		return
-	}
+		opts.Email, err = AskQuestion("Enter your email address (e.g., john.doe@example.com) : ")
+		if err != nil {
+			return
+		}
 
-	comment, err := AskQuestion("Enter optional comment (e.g., development keys) : ")
-	if err != nil {
-		return
+		opts.Comment, err = AskQuestion("Enter optional comment (e.g., development keys) : ")
+		if err != nil {
+			return
+		}
 	}
 
 	fmt.Print("Generating Entity and OpenPGP Key Pair... ")
-	entity, err = openpgp.NewEntity(name, comment, email, conf)
+	entity, err = openpgp.NewEntity(opts.Name, opts.Comment, opts.Email, conf)
 	if err != nil {
 		return
 	}
 	fmt.Println("Done")
 
 	// encrypt private key
-	pass, err := AskQuestionNoEcho("Enter encryption passphrase : ")
+	pass, err := GetPassphrase("Enter encryption passphrase : ")
 	if err != nil {
 		return
 	}
@@ -388,6 +475,19 @@ func GenKeyPair() (entity *openpgp.Entity, err error) {
 		return
 	}
 
+	return
+}
+
+// GenKeyPair generates an OpenPGP key pair and store them in the sypgp home folder
+func GenKeyPair(opts GenKeyPairOptions) (entity *openpgp.Entity, err error) {
+	if err = PathsCheck(); err != nil {
+		return
+	}
+
+	if entity, err = NewKeyPair(opts); err != nil {
+		return
+	}
+
 	// Store key parts in local key caches
 	if err = StorePrivKey(entity); err != nil {
 		return
@@ -399,10 +499,10 @@ func GenKeyPair() (entity *openpgp.Entity, err error) {
 	return
 }
 
-// DecryptKey decrypts a private key provided a pass phrase
+// DecryptKey decrypts a private key provided a pass phrase, see GetPassphrase
 func DecryptKey(k *openpgp.Entity) error {
 	if k.PrivateKey.Encrypted == true {
-		pass, err := AskQuestionNoEcho("Enter key passphrase: ")
+		pass, err := GetPassphrase("Enter key passphrase: ")
 		if err != nil {
 			return err
 		}
@@ -578,7 +678,8 @@ func FetchPubkey(fingerprint, keyserverURI, authToken string, noPrompt bool) (op
 	// Pull key from Key Service.
 	keyText, err := c.GetKey(context.TODO(), fp)
 	if err != nil {
-		if err, ok := err.(*jsonresp.Error); ok && err.Code == http.StatusUnauthorized {
+		jerr, ok := err.(*jsonresp.Error)
+		if ok && jerr.Code == http.StatusUnauthorized && !noPrompt {
 
 			// The request failed with HTTP code unauthorized. Guide user to fix that.
 			authToken, err := helpAuthentication()
@@ -591,7 +692,7 @@ func FetchPubkey(fingerprint, keyserverURI, authToken string, noPrompt bool) (op
 			if keyText, err = c.GetKey(context.TODO(), fp); err != nil {
 				return nil, err
 			}
-		} else if err.Code == http.StatusNotFound {
+		} else if ok && jerr.Code == http.StatusNotFound {
 			return nil, fmt.Errorf("no matching keys found for fingerprint")
 		} else {
 			return nil, fmt.Errorf("failed to get key: %v", err)
@@ -634,7 +735,11 @@ func PushPubkey(e *openpgp.Entity, keyserverURI, authToken string) error {
 	if err != nil {
 		return err
 	}
+	return PushArmoredPubkey(keyText, keyserverURI, authToken)
+}
 
+// PushArmoredPubkey pushes an ASCII armored public key to the Key Service.
+func PushArmoredPubkey(keyText, keyserverURI, authToken string) error {
 	// Get a Key Service client.
 	c, err := client.NewClient(&client.Config{
 		BaseURL:   keyserverURI,
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -7,10 +7,13 @@ package sypgp
 
 import (
 	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
@@ -190,6 +193,71 @@ func TestPushPubkey(t *testing.T) {
 	}
 }
 
+func TestGlobalKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sypgp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(path string) { globalKeyring = path }(globalKeyring)
+	globalKeyring = filepath.Join(dir, "global-pgp-public")
+
+	// missing global keyring is empty
+	el, err := LoadGlobalPubKeyring()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(el) != 0 {
+		t.Fatalf("unexpected %d keys in missing keyring", len(el))
+	}
+
+	e, err := openpgp.NewEntity("Other Name", testComment, testEmail, nil)
+	if err != nil {
+		t.Fatalf("failed to create entity: %v", err)
+	}
+	for _, entity := range []*openpgp.Entity{testEntity, e} {
+		if err := StoreGlobalPubKey(entity); err != nil {
+			t.Fatalf("failed to store key: %v", err)
+		}
+	}
+
+	fi, err := os.Stat(globalKeyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("unexpected global keyring mode %v", fi.Mode())
+	}
+
+	el, err = LoadGlobalPubKeyring()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(el) != 2 {
+		t.Fatalf("unexpected %d keys in keyring", len(el))
+	}
+	if el[0].PrivateKey != nil {
+		t.Errorf("private key stored in global keyring")
+	}
+
+	fingerprint := fmt.Sprintf("%x", testEntity.PrimaryKey.Fingerprint)
+	if err := RemoveGlobalPubKey(fingerprint); err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+	if err := RemoveGlobalPubKey(fingerprint); err == nil {
+		t.Errorf("unexpected success removing a missing key")
+	}
+
+	el, err = LoadGlobalPubKeyring()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(el) != 1 || el[0].PrimaryKey.KeyId != e.PrimaryKey.KeyId {
+		t.Errorf("unexpected keys left in global keyring")
+	}
+}
+
 func TestMain(m *testing.M) {
 	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
 
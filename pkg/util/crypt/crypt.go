@@ -0,0 +1,39 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Device describes a dm-crypt device managed with cryptsetup
+type Device struct {
+	Cryptsetup string
+}
+
+// cryptsetupDirs are the standard locations of cryptsetup, PATH
+// is not searched since cryptsetup may be run with privileges
+var cryptsetupDirs = []string{"/usr/local/sbin", "/usr/sbin", "/sbin", "/usr/local/bin", "/usr/bin", "/bin"}
+
+// FindCryptsetup returns the path of cryptsetup, path is either
+// empty, the cryptsetup program or the directory containing it
+func FindCryptsetup(path string) (string, error) {
+	if path != "" {
+		if !strings.HasSuffix(path, "cryptsetup") {
+			path = filepath.Join(path, "cryptsetup")
+		}
+		return exec.LookPath(path)
+	}
+	for _, dir := range cryptsetupDirs {
+		if p, err := exec.LookPath(filepath.Join(dir, "cryptsetup")); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("cryptsetup not found in %s", strings.Join(cryptsetupDirs, ", "))
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+const (
+	// luksHeaderSize is the default LUKS2 header size preceding data
+	luksHeaderSize = 16 << 20
+	// sectorSize is the alignment of encrypted data
+	sectorSize = 4096
+	// devMapperDir is the location of device-mapper devices
+	devMapperDir = "/dev/mapper"
+)
+
+// run executes cryptsetup with arguments, the key is passed on
+// standard input
+func (d *Device) run(key []byte, args ...string) error {
+	sylog.Debugf("Running %s %s", d.Cryptsetup, strings.Join(args, " "))
+
+	cmd := exec.Command(d.Cryptsetup, args...)
+	cmd.Stdin = bytes.NewReader(key)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cryptsetup %s failed: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// newDeviceName returns a random device-mapper device name
+func newDeviceName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", fmt.Errorf("while generating device name: %s", err)
+	}
+	return "singularity_crypt_" + hex.EncodeToString(b), nil
+}
+
+// EncryptFilesystem copies the filesystem image at path into a new
+// LUKS2 encrypted file and returns the path of the encrypted file
+func (d *Device) EncryptFilesystem(path string, key []byte) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("while opening filesystem image: %s", err)
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("while getting filesystem image size: %s", err)
+	}
+	size := fi.Size()
+	if r := size % sectorSize; r != 0 {
+		size += sectorSize - r
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), "crypt-")
+	if err != nil {
+		return "", fmt.Errorf("while creating encrypted file: %s", err)
+	}
+	encrypted := f.Name()
+	err = f.Truncate(size + luksHeaderSize)
+	f.Close()
+	if err != nil {
+		os.Remove(encrypted)
+		return "", fmt.Errorf("while allocating encrypted file: %s", err)
+	}
+
+	if err := d.encrypt(src, encrypted, key); err != nil {
+		os.Remove(encrypted)
+		return "", err
+	}
+	return encrypted, nil
+}
+
+func (d *Device) encrypt(src io.Reader, path string, key []byte) error {
+	if err := d.run(key, "luksFormat", "--batch-mode", "--type", "luks2", "--key-file", "-", path); err != nil {
+		return err
+	}
+
+	name, err := newDeviceName()
+	if err != nil {
+		return err
+	}
+	if err := d.run(key, "open", "--type", "luks2", "--key-file", "-", path, name); err != nil {
+		return err
+	}
+	device := filepath.Join(devMapperDir, name)
+	defer d.Close(device, false)
+
+	dst, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", device, err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("while copying filesystem to %s: %s", device, err)
+	}
+	return dst.Sync()
+}
+
+// Open unlocks the LUKS2 device at path in read-only mode and
+// returns the path of the device-mapper device
+func (d *Device) Open(key []byte, path string) (string, error) {
+	name, err := newDeviceName()
+	if err != nil {
+		return "", err
+	}
+	if err := d.run(key, "open", "--type", "luks2", "--readonly", "--key-file", "-", path, name); err != nil {
+		return "", err
+	}
+	return filepath.Join(devMapperDir, name), nil
+}
+
+// Close removes the device-mapper device, if deferred is true the
+// device is removed once it's not used anymore
+func (d *Device) Close(device string, deferred bool) error {
+	args := []string{"close"}
+	if deferred {
+		args = append(args, "--deferred")
+	}
+	return d.run(nil, append(args, filepath.Base(device))...)
+}
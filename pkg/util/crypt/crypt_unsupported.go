@@ -0,0 +1,30 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build !linux
+
+package crypt
+
+import (
+	"fmt"
+)
+
+// EncryptFilesystem copies the filesystem image at path into a new
+// LUKS2 encrypted file and returns the path of the encrypted file
+func (d *Device) EncryptFilesystem(path string, key []byte) (string, error) {
+	return "", fmt.Errorf("unsupported on this platform")
+}
+
+// Open unlocks the LUKS2 device at path and returns the path of
+// the device-mapper device
+func (d *Device) Open(key []byte, path string) (string, error) {
+	return "", fmt.Errorf("unsupported on this platform")
+}
+
+// Close removes the device-mapper device, if deferred is true the
+// device is removed once it's not used anymore
+func (d *Device) Close(device string, deferred bool) error {
+	return fmt.Errorf("unsupported on this platform")
+}
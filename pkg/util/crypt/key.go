@@ -0,0 +1,202 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+// KeyFormat identifies the format of the encryption key material
+type KeyFormat int
+
+const (
+	// Unknown is used when no encryption key material is provided
+	Unknown KeyFormat = iota
+	// Passphrase is a passphrase directly used as encryption key
+	Passphrase
+	// PEM is a PEM encoded RSA key file wrapping a random encryption key
+	PEM
+)
+
+// plaintextKeySize is the size of random encryption keys
+const plaintextKeySize = 64
+
+// ErrNoEncryptedKey is returned when an image doesn't contain
+// an encryption key wrapped with a PEM public key
+var ErrNoEncryptedKey = errors.New("no encrypted key found in image")
+
+// KeyInfo describes the encryption key material, Material is
+// either a passphrase or the path of a PEM file
+type KeyInfo struct {
+	Format   KeyFormat
+	Material string
+}
+
+// NewPlaintextKey returns a new encryption key: the passphrase
+// itself or a random key for PEM key material
+func NewPlaintextKey(k KeyInfo) ([]byte, error) {
+	switch k.Format {
+	case Passphrase:
+		if k.Material == "" {
+			return nil, fmt.Errorf("empty encryption passphrase")
+		}
+		return []byte(k.Material), nil
+	case PEM:
+		key := make([]byte, plaintextKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("while generating random key: %s", err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown encryption key format")
+}
+
+// EncryptKey wraps the plaintext key with the RSA public key
+// of the PEM file using RSA-OAEP
+func EncryptKey(k KeyInfo, plaintext []byte) ([]byte, error) {
+	if k.Format != PEM {
+		return nil, fmt.Errorf("encryption key can only be wrapped with a PEM public key")
+	}
+	pub, err := loadPublicKey(k.Material)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, plaintext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while wrapping encryption key: %s", err)
+	}
+	return ciphertext, nil
+}
+
+// DecryptKey unwraps the encrypted key with the RSA private key
+// of the PEM file
+func DecryptKey(k KeyInfo, ciphertext []byte) ([]byte, error) {
+	if k.Format != PEM {
+		return nil, fmt.Errorf("encryption key can only be unwrapped with a PEM private key")
+	}
+	priv, err := loadPrivateKey(k.Material)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while unwrapping encryption key: %s", err)
+	}
+	return plaintext, nil
+}
+
+// PlaintextKey returns the encryption key of the SIF image at path:
+// the passphrase itself or the key stored in the image unwrapped
+// with the PEM private key
+func PlaintextKey(k KeyInfo, path string) ([]byte, error) {
+	switch k.Format {
+	case Passphrase:
+		return NewPlaintextKey(k)
+	case PEM:
+		ciphertext, err := encryptedKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return DecryptKey(k, ciphertext)
+	}
+	return nil, fmt.Errorf("unknown encryption key format")
+}
+
+// encryptedKey returns the wrapped encryption key of the primary
+// partition of the SIF image at path
+func encryptedKey(path string) ([]byte, error) {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return nil, fmt.Errorf("while looking for primary partition: %s", err)
+	}
+
+	for _, d := range fimg.DescrArr {
+		if !d.Used || d.Datatype != image.SIFDataCryptoMessage || d.Link != part.ID {
+			continue
+		}
+		b := make([]byte, d.Filelen)
+		if _, err := fimg.Fp.ReadAt(b, d.Fileoff); err != nil {
+			return nil, fmt.Errorf("while reading encrypted key: %s", err)
+		}
+		return b, nil
+	}
+	return nil, ErrNoEncryptedKey
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading PEM file: %s", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+// loadPublicKey returns the RSA public key of a PEM file
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pub, ok := key.(*rsa.PublicKey); ok {
+			return pub, nil
+		}
+		return nil, fmt.Errorf("%s doesn't contain a RSA public key", path)
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q in %s", block.Type, path)
+}
+
+// loadPrivateKey returns the RSA private key of a PEM file
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if priv, ok := key.(*rsa.PrivateKey); ok {
+			return priv, nil
+		}
+		return nil, fmt.Errorf("%s doesn't contain a RSA private key", path)
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q in %s", block.Type, path)
+}
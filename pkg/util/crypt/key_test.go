@@ -0,0 +1,158 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+func writePEM(t *testing.T, path, blockType string, b []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createEncryptedSIF(t *testing.T, path string, ciphertext []byte) {
+	part := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Data:     make([]byte, 4096),
+		Size:     4096,
+	}
+	if err := part.SetPartExtra(image.SIFFsEncryptedSquashfs, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatal(err)
+	}
+	inputs := []sif.DescriptorInput{part}
+
+	if ciphertext != nil {
+		inputs = append(inputs, sif.DescriptorInput{
+			Datatype: image.SIFDataCryptoMessage,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     1,
+			Data:     ciphertext,
+			Size:     int64(len(ciphertext)),
+		})
+	}
+
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	}
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypt-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(dir, "public.pem")
+	privPath := filepath.Join(dir, "private.pem")
+	pkcs1PubPath := filepath.Join(dir, "public-pkcs1.pem")
+	pkix, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, pubPath, "PUBLIC KEY", pkix)
+	writePEM(t, pkcs1PubPath, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&priv.PublicKey))
+	writePEM(t, privPath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+
+	// passphrase
+	passphrase := KeyInfo{Format: Passphrase, Material: "secret"}
+	if key, err := NewPlaintextKey(passphrase); err != nil || string(key) != "secret" {
+		t.Errorf("unexpected passphrase key %q: %v", key, err)
+	}
+	if _, err := NewPlaintextKey(KeyInfo{Format: Passphrase}); err == nil {
+		t.Errorf("unexpected success with empty passphrase")
+	}
+	if _, err := NewPlaintextKey(KeyInfo{}); err == nil {
+		t.Errorf("unexpected success with unknown key format")
+	}
+	if _, err := EncryptKey(passphrase, []byte("key")); err == nil {
+		t.Errorf("unexpected success while wrapping key with passphrase")
+	}
+
+	// PEM wrapped random key
+	key, err := NewPlaintextKey(KeyInfo{Format: PEM, Material: pubPath})
+	if err != nil {
+		t.Fatalf("unexpected error while generating key: %s", err)
+	}
+	if len(key) != plaintextKeySize {
+		t.Errorf("unexpected key size %d", len(key))
+	}
+
+	for _, pub := range []string{pubPath, pkcs1PubPath} {
+		ciphertext, err := EncryptKey(KeyInfo{Format: PEM, Material: pub}, key)
+		if err != nil {
+			t.Fatalf("unexpected error while wrapping key with %s: %s", pub, err)
+		}
+		plaintext, err := DecryptKey(KeyInfo{Format: PEM, Material: privPath}, ciphertext)
+		if err != nil {
+			t.Fatalf("unexpected error while unwrapping key: %s", err)
+		}
+		if !bytes.Equal(key, plaintext) {
+			t.Errorf("unwrapped key doesn't match")
+		}
+	}
+
+	if _, err := EncryptKey(KeyInfo{Format: PEM, Material: privPath}, key); err == nil {
+		t.Errorf("unexpected success while wrapping key with private key")
+	}
+	if _, err := DecryptKey(KeyInfo{Format: PEM, Material: pubPath}, key); err == nil {
+		t.Errorf("unexpected success while unwrapping key with public key")
+	}
+
+	// key stored in SIF image
+	ciphertext, err := EncryptKey(KeyInfo{Format: PEM, Material: pubPath}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sifPath := filepath.Join(dir, "encrypted.sif")
+	createEncryptedSIF(t, sifPath, ciphertext)
+
+	plaintext, err := PlaintextKey(KeyInfo{Format: PEM, Material: privPath}, sifPath)
+	if err != nil {
+		t.Fatalf("unexpected error while retrieving image key: %s", err)
+	}
+	if !bytes.Equal(key, plaintext) {
+		t.Errorf("image key doesn't match")
+	}
+	if plaintext, err := PlaintextKey(passphrase, sifPath); err != nil || string(plaintext) != "secret" {
+		t.Errorf("unexpected passphrase image key %q: %v", plaintext, err)
+	}
+
+	noKeyPath := filepath.Join(dir, "nokey.sif")
+	createEncryptedSIF(t, noKeyPath, nil)
+	if _, err := PlaintextKey(KeyInfo{Format: PEM, Material: privPath}, noKeyPath); err != ErrNoEncryptedKey {
+		t.Errorf("unexpected error for image without key: %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -24,6 +24,37 @@ func Exclusive(path string) (fd int, err error) {
 	return fd, nil
 }
 
+// TryExclusive applies an exclusive lock on path without waiting, it
+// returns syscall.EWOULDBLOCK if a lock is already held on path
+func TryExclusive(path string) (fd int, err error) {
+	fd, err = syscall.Open(path, os.O_RDONLY, 0)
+	if err != nil {
+		return fd, err
+	}
+	err = syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		syscall.Close(fd)
+		return fd, err
+	}
+	return fd, nil
+}
+
+// Shared applies a shared lock on path, as for Exclusive the returned
+// file descriptor is inherited by child processes which keep holding
+// the lock until the last of them closes it
+func Shared(path string) (fd int, err error) {
+	fd, err = syscall.Open(path, os.O_RDONLY, 0)
+	if err != nil {
+		return fd, err
+	}
+	err = syscall.Flock(fd, syscall.LOCK_SH)
+	if err != nil {
+		syscall.Close(fd)
+		return fd, err
+	}
+	return fd, nil
+}
+
 // Release removes a lock on path referenced by fd
 func Release(fd int) error {
 	defer syscall.Close(fd)
@@ -6,6 +6,9 @@
 package lock
 
 import (
+	"io/ioutil"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 
@@ -42,3 +45,82 @@ func TestLock(t *testing.T) {
 		t.Errorf("lock acquired")
 	}
 }
+
+func TestSharedLock(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	if _, err := Shared(""); err == nil {
+		t.Errorf("unexpected success with empty path")
+	}
+
+	f, err := ioutil.TempFile("", "lock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	fd1, err := Shared(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd2, err := Shared(f.Name())
+	if err != nil {
+		t.Fatalf("shared lock not acquired twice: %s", err)
+	}
+
+	ch := make(chan bool, 1)
+
+	go func() {
+		fd, _ := Exclusive(f.Name())
+		Release(fd)
+		ch <- true
+	}()
+
+	Release(fd1)
+
+	select {
+	case <-time.After(1 * time.Second):
+		Release(fd2)
+	case <-ch:
+		t.Errorf("exclusive lock acquired while shared lock is held")
+	}
+
+	select {
+	case <-time.After(1 * time.Second):
+		t.Errorf("exclusive lock not acquired after shared locks release")
+	case <-ch:
+	}
+}
+
+func TestTryExclusiveLock(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	if _, err := TryExclusive(""); err == nil {
+		t.Errorf("unexpected success with empty path")
+	}
+
+	f, err := ioutil.TempFile("", "lock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	fd, err := Shared(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryExclusive(f.Name()); err != syscall.EWOULDBLOCK {
+		t.Errorf("unexpected error while shared lock is held: %v", err)
+	}
+	Release(fd)
+
+	fd, err = TryExclusive(f.Name())
+	if err != nil {
+		t.Fatalf("exclusive lock not acquired: %s", err)
+	}
+	Release(fd)
+}
@@ -48,6 +48,13 @@ const (
 	CmdSetDirectIO = 0x4C08
 )
 
+// Loop control device IOCTL commands
+const (
+	CmdCtlAdd     = 0x4C80
+	CmdCtlRemove  = 0x4C81
+	CmdCtlGetFree = 0x4C82
+)
+
 // Info64 contains information about a loop device.
 type Info64 struct {
 	Device         uint64
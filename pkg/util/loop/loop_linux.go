@@ -7,19 +7,38 @@ package loop
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/util/fs/lock"
 )
 
-// AttachFromFile finds a free loop device, opens it, and stores file descriptor
-// provided by image file pointer
-func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error {
-	var path string
-	var loopFd int
+const (
+	// controlPath is the path of the loop control device
+	controlPath = "/dev/loop-control"
+	// attachRetries is the number of attempts to attach a free loop
+	// device while other processes allocate loop devices
+	attachRetries = 5
+	// attachDelay is the delay between two attach attempts
+	attachDelay = 100 * time.Millisecond
+)
+
+// sysBlockPath is the sysfs directory listing block devices
+var sysBlockPath = "/sys/block"
 
+// AttachFromFile attaches image to a free loop device, or to a loop device
+// already associated with the same image area if Shared is set, and stores
+// the loop device number in number. Loop devices are always attached with
+// the autoclear flag so they are released along with their last user
+func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error {
 	if image == nil {
 		return fmt.Errorf("empty file pointer")
 	}
@@ -29,8 +48,12 @@ func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error
 		return err
 	}
 	st := fi.Sys().(*syscall.Stat_t)
-	imageIno := st.Ino
-	imageDev := st.Dev
+
+	info := &Info64{}
+	if loop.Info != nil {
+		*info = *loop.Info
+	}
+	info.Flags |= FlagsAutoClear
 
 	fd, err := lock.Exclusive("/dev")
 	if err != nil {
@@ -38,74 +61,36 @@ func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error
 	}
 	defer lock.Release(fd)
 
-	freeDevice := -1
-
-	for device := 0; device <= loop.MaxLoopDevices; device++ {
-		*number = device
-
-		if device == loop.MaxLoopDevices {
-			if loop.Shared {
-				loop.Shared = false
-				if freeDevice != -1 {
-					device = freeDevice
-					continue
-				}
-			}
-			return fmt.Errorf("no loop devices available")
+	if loop.Shared {
+		if device, ok := loop.findShared(uint64(st.Dev), uint64(st.Ino), mode, info); ok {
+			*number = device
+			sylog.Debugf("Sharing loop device /dev/loop%d for %s (%s)", device, image.Name(), usage())
+			return nil
 		}
+	}
 
-		path = fmt.Sprintf("/dev/loop%d", device)
-		if fi, err := os.Stat(path); err != nil {
-			dev := int((7 << 8) | (device & 0xff) | ((device & 0xfff00) << 12))
-			esys := syscall.Mknod(path, syscall.S_IFBLK|0660, dev)
-			if errno, ok := esys.(syscall.Errno); ok {
-				if errno != syscall.EEXIST {
-					return esys
-				}
-			}
-		} else if fi.Mode()&os.ModeDevice == 0 {
-			return fmt.Errorf("%s is not a block device", path)
+	for i := 0; i < attachRetries; i++ {
+		if i > 0 {
+			time.Sleep(attachDelay)
 		}
 
-		if loopFd, err = syscall.Open(path, mode, 0600); err != nil {
-			continue
+		device, err := loop.getFree()
+		if err != nil {
+			return err
 		}
-		if loop.Shared {
-			status, err := GetStatusFromFd(uintptr(loopFd))
-			syscall.Close(loopFd)
-			if err != nil {
-				return err
-			}
-			// there is no associated image with loop device, save indice so second loop
-			// iteration will start from this device
-			if status.Inode == 0 && freeDevice == -1 {
-				freeDevice = device
-				continue
-			}
-			if status.Inode == imageIno && status.Device == imageDev &&
-				status.Flags&FlagsReadOnly == loop.Info.Flags&FlagsReadOnly &&
-				status.Offset == loop.Info.Offset && status.SizeLimit == loop.Info.SizeLimit {
-				return nil
-			}
-		} else {
-			_, _, esys := syscall.Syscall(syscall.SYS_IOCTL, uintptr(loopFd), CmdSetFd, image.Fd())
-			if esys != 0 {
-				syscall.Close(loopFd)
-				continue
-			}
-			break
-		}
-	}
 
-	if _, _, err := syscall.Syscall(syscall.SYS_FCNTL, uintptr(loopFd), syscall.F_SETFD, syscall.FD_CLOEXEC); err != 0 {
-		return fmt.Errorf("failed to set close-on-exec on loop device %s: %s", path, err.Error())
-	}
-
-	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, uintptr(loopFd), CmdSetStatus64, uintptr(unsafe.Pointer(loop.Info))); err != 0 {
-		return fmt.Errorf("Failed to set loop flags on loop device: %s", syscall.Errno(err))
+		err = attach(device, image, mode, info)
+		if err == nil {
+			*number = device
+			sylog.Debugf("Attached %s to loop device /dev/loop%d (%s)", image.Name(), device, usage())
+			return nil
+		} else if err != syscall.EBUSY && err != syscall.ENXIO {
+			return fmt.Errorf("failed to attach loop device /dev/loop%d: %s", device, err)
+		}
+		sylog.Debugf("Loop device /dev/loop%d taken by another process, retrying", device)
 	}
 
-	return nil
+	return fmt.Errorf("no loop devices available after %d attempts", attachRetries)
 }
 
 // AttachFromPath finds a free loop device, opens it, and stores file descriptor
@@ -136,3 +121,172 @@ func GetStatusFromPath(path string) (*Info64, error) {
 	}
 	return GetStatusFromFd(loop.Fd())
 }
+
+// attach associates image with the loop device and sets its status, the
+// loop device file descriptor is kept open with the close-on-exec flag so
+// the loop device isn't released before being used by the caller
+func attach(device int, image *os.File, mode int, info *Info64) error {
+	loopFd, err := openDevice(device, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, _, esys := syscall.Syscall(syscall.SYS_IOCTL, uintptr(loopFd), CmdSetFd, image.Fd()); esys != 0 {
+		syscall.Close(loopFd)
+		return esys
+	}
+
+	if _, _, esys := syscall.Syscall(syscall.SYS_IOCTL, uintptr(loopFd), CmdSetStatus64, uintptr(unsafe.Pointer(info))); esys != 0 {
+		syscall.Syscall(syscall.SYS_IOCTL, uintptr(loopFd), CmdClrFd, 0)
+		syscall.Close(loopFd)
+		return fmt.Errorf("failed to set loop flags on loop device: %s", esys)
+	}
+
+	return nil
+}
+
+// openDevice opens the loop device, its device node is created if missing
+func openDevice(device int, mode int) (int, error) {
+	path := fmt.Sprintf("/dev/loop%d", device)
+
+	if fi, err := os.Stat(path); err != nil {
+		dev := int((7 << 8) | (device & 0xff) | ((device & 0xfff00) << 12))
+		if err := syscall.Mknod(path, syscall.S_IFBLK|0660, dev); err != nil && err != syscall.EEXIST {
+			return -1, err
+		}
+	} else if fi.Mode()&os.ModeDevice == 0 {
+		return -1, fmt.Errorf("%s is not a block device", path)
+	}
+
+	return syscall.Open(path, mode|syscall.O_CLOEXEC, 0600)
+}
+
+// getFree returns the number of a free loop device, it's requested to the
+// loop control device which allocates a new loop device if none is free
+func (loop *Device) getFree() (int, error) {
+	device, err := getFreeFromControl()
+	if err != nil {
+		sylog.Debugf("Could not get free loop device from %s: %s", controlPath, err)
+		if device, err = loop.scanFree(); err != nil {
+			return -1, err
+		}
+	}
+	if device >= loop.MaxLoopDevices {
+		return -1, fmt.Errorf("no loop devices available, limit of %d loop devices reached", loop.MaxLoopDevices)
+	}
+	return device, nil
+}
+
+// getFreeFromControl returns a free loop device number with LOOP_CTL_GET_FREE
+func getFreeFromControl() (int, error) {
+	fd, err := syscall.Open(controlPath, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	defer syscall.Close(fd)
+
+	device, _, esys := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), CmdCtlGetFree, 0)
+	if esys != 0 {
+		return -1, esys
+	}
+	return int(device), nil
+}
+
+// scanFree returns the first loop device not associated with a file,
+// used when the loop control device is not available
+func (loop *Device) scanFree() (int, error) {
+	for device := 0; device < loop.MaxLoopDevices; device++ {
+		fd, err := openDevice(device, os.O_RDONLY)
+		if err != nil {
+			continue
+		}
+		status, err := GetStatusFromFd(uintptr(fd))
+		syscall.Close(fd)
+		if err == nil && status.Inode == 0 && status.Device == 0 {
+			return device, nil
+		}
+	}
+	return -1, fmt.Errorf("no loop devices available")
+}
+
+// findShared returns the number of a loop device associated with the same
+// image area in the same read-only mode, its file descriptor is kept open
+func (loop *Device) findShared(dev, ino uint64, mode int, info *Info64) (int, bool) {
+	devices, err := listDevices()
+	if err != nil {
+		// without sysfs, check all loop devices below the limit
+		devices = make(map[int]bool)
+		for device := 0; device < loop.MaxLoopDevices; device++ {
+			devices[device] = true
+		}
+	}
+
+	numbers := make([]int, 0, len(devices))
+	for device, attached := range devices {
+		if attached && device < loop.MaxLoopDevices {
+			numbers = append(numbers, device)
+		}
+	}
+	sort.Ints(numbers)
+
+	for _, device := range numbers {
+		fd, err := openDevice(device, mode)
+		if err != nil {
+			continue
+		}
+		status, err := GetStatusFromFd(uintptr(fd))
+		if err == nil && matchStatus(status, dev, ino, info) {
+			return device, true
+		}
+		syscall.Close(fd)
+	}
+	return -1, false
+}
+
+// matchStatus returns if the loop device status corresponds to the image
+// identified by its device and inode numbers and to the requested status
+func matchStatus(status *Info64, dev, ino uint64, info *Info64) bool {
+	return status.Inode != 0 &&
+		status.Device == dev && status.Inode == ino &&
+		status.Offset == info.Offset && status.SizeLimit == info.SizeLimit &&
+		status.Flags&FlagsReadOnly == info.Flags&FlagsReadOnly
+}
+
+// listDevices returns the loop device numbers found in sysfs along with
+// their association status
+func listDevices() (map[int]bool, error) {
+	entries, err := ioutil.ReadDir(sysBlockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make(map[int]bool)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "loop") {
+			continue
+		}
+		device, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "loop"))
+		if err != nil {
+			continue
+		}
+		// loop attributes directory is only present for associated devices
+		_, err = os.Stat(filepath.Join(sysBlockPath, e.Name(), "loop"))
+		devices[device] = err == nil
+	}
+	return devices, nil
+}
+
+// usage returns a loop devices usage summary for debug output
+func usage() string {
+	devices, err := listDevices()
+	if err != nil {
+		return "loop devices usage unknown"
+	}
+	used := 0
+	for _, attached := range devices {
+		if attached {
+			used++
+		}
+	}
+	return fmt.Sprintf("%d/%d loop devices in use", used, len(devices))
+}
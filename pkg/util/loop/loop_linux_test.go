@@ -7,7 +7,10 @@ package loop
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"syscall"
 	"testing"
 
@@ -101,3 +104,63 @@ func TestLoop(t *testing.T) {
 		t.Errorf("unexpected success with MaxLoopDevices = 0")
 	}
 }
+
+func TestMatchStatus(t *testing.T) {
+	info := &Info64{Offset: 4096, SizeLimit: 8192, Flags: FlagsAutoClear | FlagsReadOnly}
+
+	tests := []struct {
+		name   string
+		status Info64
+		match  bool
+	}{
+		{"same", Info64{Device: 1, Inode: 2, Offset: 4096, SizeLimit: 8192, Flags: FlagsReadOnly}, true},
+		{"device", Info64{Device: 3, Inode: 2, Offset: 4096, SizeLimit: 8192, Flags: FlagsReadOnly}, false},
+		{"inode", Info64{Device: 1, Inode: 3, Offset: 4096, SizeLimit: 8192, Flags: FlagsReadOnly}, false},
+		{"offset", Info64{Device: 1, Inode: 2, Offset: 0, SizeLimit: 8192, Flags: FlagsReadOnly}, false},
+		{"size", Info64{Device: 1, Inode: 2, Offset: 4096, SizeLimit: 0, Flags: FlagsReadOnly}, false},
+		{"writable", Info64{Device: 1, Inode: 2, Offset: 4096, SizeLimit: 8192}, false},
+		{"free", Info64{}, false},
+	}
+
+	for _, tt := range tests {
+		if match := matchStatus(&tt.status, 1, 2, info); match != tt.match {
+			t.Errorf("%s: got match %v instead of %v", tt.name, match, tt.match)
+		}
+	}
+}
+
+func TestListDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysblock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"loop0/loop", "loop1", "loop12/loop", "loopx/loop", "sda"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(path string) {
+		sysBlockPath = path
+	}(sysBlockPath)
+	sysBlockPath = dir
+
+	devices, err := listDevices()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[int]bool{0: true, 1: false, 12: true}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("got devices %v instead of %v", devices, expected)
+	}
+	if u := usage(); u != "2/3 loop devices in use" {
+		t.Errorf("unexpected usage %q", u)
+	}
+
+	sysBlockPath = filepath.Join(dir, "missing")
+	if _, err := listDevices(); err == nil {
+		t.Errorf("unexpected success with missing sysfs directory")
+	}
+}
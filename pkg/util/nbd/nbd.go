@@ -0,0 +1,145 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package nbd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// Device describes a network block device served by the current
+// process from a read-only data source
+type Device struct {
+	// Path is the path of the block device, e.g. /dev/nbd0
+	Path string
+	// Size is the size in bytes of the block device
+	Size int64
+
+	fd     int
+	sock   int
+	served chan error
+	done   chan error
+}
+
+// Network block device IOCTL commands
+const (
+	CmdSetSock       = 0xAB00
+	CmdSetBlkSize    = 0xAB01
+	CmdSetSize       = 0xAB02
+	CmdDoIt          = 0xAB03
+	CmdClearSock     = 0xAB04
+	CmdClearQue      = 0xAB05
+	CmdSetSizeBlocks = 0xAB07
+	CmdDisconnect    = 0xAB08
+	CmdSetTimeout    = 0xAB09
+	CmdSetFlags      = 0xAB0A
+)
+
+// Network block device flags values
+const (
+	FlagHasFlags = 1 << 0
+	FlagReadOnly = 1 << 1
+)
+
+// Network block device protocol values
+const (
+	RequestMagic = 0x25609513
+	ReplyMagic   = 0x67446698
+
+	CmdRead  = 0
+	CmdWrite = 1
+	CmdDisc  = 2
+	CmdFlush = 3
+	CmdTrim  = 4
+)
+
+// BlockSize is the block size of network block devices
+const BlockSize = 4096
+
+// Request is a request sent by the kernel to the server
+type Request struct {
+	Magic  uint32
+	Type   uint32
+	Handle [8]byte
+	From   uint64
+	Len    uint32
+}
+
+// Reply is a reply sent by the server to the kernel, read replies
+// are followed by the data read
+type Reply struct {
+	Magic  uint32
+	Error  uint32
+	Handle [8]byte
+}
+
+// Serve answers requests read from conn with data read from r until a
+// disconnect request is received, size is the size of the device. Write
+// requests are rejected as devices are read-only
+func Serve(conn io.ReadWriter, r io.ReaderAt, size int64) error {
+	var req Request
+
+	for {
+		if err := binary.Read(conn, binary.BigEndian, &req); err != nil {
+			return fmt.Errorf("while reading request: %s", err)
+		}
+		if req.Magic != RequestMagic {
+			return fmt.Errorf("bad request magic 0x%x", req.Magic)
+		}
+
+		reply := Reply{Magic: ReplyMagic, Handle: req.Handle}
+		var data []byte
+
+		switch req.Type & 0xffff {
+		case CmdRead:
+			data = make([]byte, req.Len)
+			if err := readAt(r, data, int64(req.From), size); err != nil {
+				sylog.Debugf("Failed to read %d bytes at offset %d: %s", req.Len, req.From, err)
+				reply.Error = uint32(syscall.EIO)
+				data = nil
+			}
+		case CmdWrite:
+			if _, err := io.CopyN(ioutil.Discard, conn, int64(req.Len)); err != nil {
+				return fmt.Errorf("while reading write request data: %s", err)
+			}
+			reply.Error = uint32(syscall.EPERM)
+		case CmdDisc:
+			return nil
+		case CmdFlush, CmdTrim:
+		default:
+			reply.Error = uint32(syscall.EINVAL)
+		}
+
+		b := new(bytes.Buffer)
+		binary.Write(b, binary.BigEndian, &reply)
+		b.Write(data)
+		if _, err := conn.Write(b.Bytes()); err != nil {
+			return fmt.Errorf("while sending reply: %s", err)
+		}
+	}
+}
+
+// readAt fills p with data read from r at offset off, data past
+// size are zeroed
+func readAt(r io.ReaderAt, p []byte, off int64, size int64) error {
+	if off >= size {
+		return nil
+	}
+	if end := off + int64(len(p)); end > size {
+		p = p[:size-off]
+	}
+	_, err := r.ReadAt(p, off)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
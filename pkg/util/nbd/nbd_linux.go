@@ -0,0 +1,168 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package nbd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+)
+
+const (
+	// sysBlockPath is the sysfs directory listing block devices
+	sysBlockPath = "/sys/block"
+	// readyTimeout is the time to wait for the device to be connected
+	readyTimeout = 5 * time.Second
+)
+
+// Attach serves the size first bytes of r through a free network block
+// device, the device is read-only and served until Detach is called
+func Attach(r io.ReaderAt, size int64) (*Device, error) {
+	fd, err := lock.Exclusive("/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release(fd)
+
+	for i := 0; ; i++ {
+		path := fmt.Sprintf("/dev/nbd%d", i)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if i == 0 {
+				return nil, fmt.Errorf("no network block device found, nbd kernel module may not be loaded")
+			}
+			return nil, fmt.Errorf("no free network block device available")
+		}
+		if connected(path) {
+			continue
+		}
+
+		d, err := attach(path, r, size)
+		if err == syscall.EBUSY {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to attach %s: %s", path, err)
+		}
+		sylog.Debugf("Attached network block device %s", path)
+		return d, nil
+	}
+}
+
+// Detach disconnects the network block device and stops serving it
+func (d *Device) Detach() error {
+	defer syscall.Close(d.fd)
+
+	if err := ioctl(d.fd, CmdDisconnect, 0); err != nil {
+		return fmt.Errorf("failed to disconnect %s: %s", d.Path, err)
+	}
+	err := <-d.done
+	if serr := <-d.served; serr != nil {
+		sylog.Debugf("Network block device %s server: %s", d.Path, serr)
+	}
+	return err
+}
+
+// connected returns if a server is already connected to the device
+func connected(path string) bool {
+	_, err := os.Stat(filepath.Join(sysBlockPath, filepath.Base(path), "pid"))
+	return err == nil
+}
+
+func ioctl(fd int, cmd uintptr, arg uintptr) error {
+	if _, _, esys := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), cmd, arg); esys != 0 {
+		return esys
+	}
+	return nil
+}
+
+func attach(path string, r io.ReaderAt, size int64) (*Device, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	socks, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	blocks := (size + BlockSize - 1) / BlockSize
+
+	for _, c := range []struct {
+		cmd uintptr
+		arg uintptr
+	}{
+		{CmdSetBlkSize, BlockSize},
+		{CmdSetSizeBlocks, uintptr(blocks)},
+		{CmdClearSock, 0},
+		{CmdSetFlags, FlagHasFlags | FlagReadOnly},
+		{CmdSetSock, uintptr(socks[0])},
+	} {
+		if err := ioctl(fd, c.cmd, c.arg); err != nil {
+			syscall.Close(socks[0])
+			syscall.Close(socks[1])
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+
+	d := &Device{
+		Path:   path,
+		Size:   blocks * BlockSize,
+		fd:     fd,
+		sock:   socks[1],
+		served: make(chan error, 1),
+		done:   make(chan error, 1),
+	}
+
+	go func() {
+		conn := os.NewFile(uintptr(d.sock), path)
+		d.served <- Serve(conn, r, size)
+		conn.Close()
+	}()
+
+	go func() {
+		// NBD_DO_IT blocks until the device is disconnected
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		err := ioctl(fd, CmdDoIt, 0)
+		ioctl(fd, CmdClearQue, 0)
+		ioctl(fd, CmdClearSock, 0)
+		syscall.Close(socks[0])
+		if err == syscall.EPIPE || err == syscall.ECONNRESET {
+			// returned after a disconnect request
+			err = nil
+		}
+		d.done <- err
+	}()
+
+	for start := time.Now(); !connected(path); {
+		select {
+		case err := <-d.done:
+			syscall.Close(fd)
+			if err == nil {
+				err = fmt.Errorf("device disconnected")
+			}
+			return nil, err
+		default:
+		}
+		if time.Since(start) > readyTimeout {
+			d.Detach()
+			return nil, fmt.Errorf("timeout while waiting device connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return d, nil
+}
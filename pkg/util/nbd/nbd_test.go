@@ -0,0 +1,102 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package nbd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func request(t *testing.T, conn net.Conn, typ uint32, from uint64, length uint32, handle byte) {
+	req := Request{Magic: RequestMagic, Type: typ, From: from, Len: length}
+	req.Handle[0] = handle
+	if err := binary.Write(conn, binary.BigEndian, &req); err != nil {
+		t.Fatalf("while sending request: %s", err)
+	}
+}
+
+func reply(t *testing.T, conn net.Conn, handle byte, errno syscall.Errno, length int) []byte {
+	var rep Reply
+	if err := binary.Read(conn, binary.BigEndian, &rep); err != nil {
+		t.Fatalf("while reading reply: %s", err)
+	}
+	if rep.Magic != ReplyMagic {
+		t.Errorf("unexpected reply magic 0x%x", rep.Magic)
+	}
+	if rep.Handle[0] != handle {
+		t.Errorf("unexpected reply handle %d", rep.Handle[0])
+	}
+	if syscall.Errno(rep.Error) != errno {
+		t.Errorf("unexpected reply error %d instead of %d", rep.Error, errno)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		t.Fatalf("while reading reply data: %s", err)
+	}
+	return data
+}
+
+func TestServe(t *testing.T) {
+	content := []byte("0123456789")
+	client, server := net.Pipe()
+	defer client.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(server, bytes.NewReader(content), int64(len(content)))
+		server.Close()
+	}()
+
+	// read within device data
+	request(t, client, CmdRead, 2, 4, 1)
+	if data := reply(t, client, 1, 0, 4); string(data) != "2345" {
+		t.Errorf("unexpected data %q", data)
+	}
+
+	// read past device data is zeroed
+	request(t, client, CmdRead, 8, 4, 2)
+	if data := reply(t, client, 2, 0, 4); !bytes.Equal(data, []byte("89\x00\x00")) {
+		t.Errorf("unexpected data %q", data)
+	}
+
+	// write requests are rejected
+	request(t, client, CmdWrite, 0, 2, 3)
+	client.Write([]byte("xx"))
+	reply(t, client, 3, syscall.EPERM, 0)
+
+	request(t, client, CmdFlush, 0, 0, 4)
+	reply(t, client, 4, 0, 0)
+
+	request(t, client, 42, 0, 0, 5)
+	reply(t, client, 5, syscall.EINVAL, 0)
+
+	request(t, client, CmdDisc, 0, 0, 6)
+	if err := <-served; err != nil {
+		t.Errorf("unexpected error after disconnect: %s", err)
+	}
+}
+
+func TestServeBadMagic(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(server, bytes.NewReader(nil), 0)
+		server.Close()
+	}()
+
+	req := Request{Magic: ReplyMagic}
+	binary.Write(client, binary.BigEndian, &req)
+
+	if err := <-served; err == nil {
+		t.Errorf("unexpected success with bad request magic")
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build !linux
+
+package nbd
+
+import (
+	"fmt"
+	"io"
+)
+
+// Attach serves the size first bytes of r through a free network block
+// device, the device is read-only and served until Detach is called
+func Attach(r io.ReaderAt, size int64) (*Device, error) {
+	return nil, fmt.Errorf("unsupported on this platform")
+}
+
+// Detach disconnects the network block device and stops serving it
+func (d *Device) Detach() error {
+	return fmt.Errorf("unsupported on this platform")
+}